@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// verified name of the caller: the unix user on the unix socket, or the
+// common name of a client certificate checked against the client CA.
+// false when the caller is anonymous and only the claimed user is known.
+func Caller(ctx context.Context) (string, bool) {
+	if user, ok := PeerUser(ctx); ok {
+		return user, true
+	}
+	return CertName(ctx)
+}
+
+// common name of the caller's client certificate, checked against the client CA
+func CertName(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 {
+		return "", false
+	}
+	if cn := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName; cn != "" {
+		return cn, true
+	}
+	return "", false
+}
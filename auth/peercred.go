@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os/user"
+	"strconv"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// auth info of a connection accepted on a unix socket, filled from SO_PEERCRED
+// so it is verified by the kernel rather than claimed by the client
+type PeerCredInfo struct {
+	credentials.CommonAuthInfo
+	UID uint32
+	GID uint32
+	PID int32
+}
+
+func (PeerCredInfo) AuthType() string {
+	return "peercred"
+}
+
+// server side transport credentials for unix socket listeners
+// the connection is not encrypted, it never leaves the host
+type peerCredentials struct{}
+
+func NewPeerCredentials() credentials.TransportCredentials {
+	return peerCredentials{}
+}
+
+func (peerCredentials) ClientHandshake(context.Context, string, net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("peercred: client handshake is not supported")
+}
+
+func (peerCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, nil, errors.New("peercred: not a unix socket connection")
+	}
+	info, err := readPeerCred(unixConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	info.SecurityLevel = credentials.PrivacyAndIntegrity
+	return conn, info, nil
+}
+
+func (peerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "peercred"}
+}
+
+func (c peerCredentials) Clone() credentials.TransportCredentials {
+	return c
+}
+
+func (peerCredentials) OverrideServerName(string) error {
+	return nil
+}
+
+// kernel verified credentials of the caller, false for tcp callers
+func PeerCred(ctx context.Context) (PeerCredInfo, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return PeerCredInfo{}, false
+	}
+	info, ok := p.AuthInfo.(PeerCredInfo)
+	return info, ok
+}
+
+// user name of a unix socket caller, the numeric uid if it has no passwd entry
+func PeerUser(ctx context.Context) (string, bool) {
+	info, ok := PeerCred(ctx)
+	if !ok {
+		return "", false
+	}
+	uid := strconv.FormatUint(uint64(info.UID), 10)
+	if u, err := user.LookupId(uid); err == nil {
+		return u.Username, true
+	}
+	return uid, true
+}
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"net"
+	"syscall"
+)
+
+func readPeerCred(conn *net.UnixConn) (PeerCredInfo, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return PeerCredInfo{}, err
+	}
+	var ucred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		ucred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return PeerCredInfo{}, err
+	}
+	if credErr != nil {
+		return PeerCredInfo{}, credErr
+	}
+	return PeerCredInfo{UID: ucred.Uid, GID: ucred.Gid, PID: ucred.Pid}, nil
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// accept one connection on a unix socket and hand back both ends
+func unixPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "js.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	client, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return client, server
+}
+
+func TestServerHandshake(t *testing.T) {
+	_, server := unixPair(t)
+	conn, info, err := NewPeerCredentials().ServerHandshake(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, ok := info.(PeerCredInfo)
+	if conn != server || !ok || cred.UID != uint32(os.Getuid()) || cred.PID != int32(os.Getpid()) {
+		t.Errorf("handshake = %v, want this process's credentials", info)
+	}
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	c, err := net.Dial("tcp", tcp.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, _, err := NewPeerCredentials().ServerHandshake(c); err == nil {
+		t.Error("handshake accepted a tcp connection")
+	}
+}
+
+func TestCaller(t *testing.T) {
+	uid := os.Getuid()
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: PeerCredInfo{UID: uint32(uid)}})
+	want := strconv.Itoa(uid)
+	if u, err := user.LookupId(want); err == nil {
+		want = u.Username
+	}
+	if name, ok := Caller(ctx); name != want || !ok {
+		t.Errorf("Caller = %q, %v, want %q", name, ok, want)
+	}
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}
+	tlsInfo := credentials.TLSInfo{}
+	tlsInfo.State.VerifiedChains = [][]*x509.Certificate{{cert}}
+	ctx = peer.NewContext(context.Background(), &peer.Peer{AuthInfo: tlsInfo})
+	if name, ok := Caller(ctx); name != "alice" || !ok {
+		t.Errorf("Caller = %q, %v, want the certificate's name", name, ok)
+	}
+
+	// a certificate the server did not verify names nobody
+	ctx = peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}})
+	if name, ok := Caller(ctx); ok {
+		t.Errorf("Caller = %q for an unverified certificate", name)
+	}
+	if _, ok := Caller(context.Background()); ok {
+		t.Error("Caller is known without a peer")
+	}
+}
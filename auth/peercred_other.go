@@ -0,0 +1,12 @@
+//go:build !linux
+
+package auth
+
+import (
+	"errors"
+	"net"
+)
+
+func readPeerCred(*net.UnixConn) (PeerCredInfo, error) {
+	return PeerCredInfo{}, errors.New("peercred: SO_PEERCRED is only supported on linux")
+}
@@ -0,0 +1,57 @@
+package cluster
+
+import (
+	"context"
+	"log/slog"
+	pb "main/proto"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// how long a worker waits before trying to reach the coordinator again
+const retryInterval = 2 * time.Second
+
+// register with the coordinator and send heartbeats until ctx is done
+// info is called for every report so it carries the worker's current load and
+// jobs, revoke is called with the jobs the worker lost its lease on
+func RunAgent(ctx context.Context, client pb.CoordinatorClient, info func() *pb.WorkerInfo, revoke func(jobIds []string)) {
+	registered := false
+	interval := retryInterval
+	for {
+		callCtx, cancel := context.WithTimeout(ctx, interval)
+		if !registered {
+			res, err := client.Register(callCtx, info())
+			if err != nil {
+				slog.Warn("failed to register with the coordinator", "err", err)
+			} else {
+				registered = true
+				interval = time.Duration(res.HeartbeatIntervalMillis) * time.Millisecond
+				if interval <= 0 {
+					interval = retryInterval
+				}
+				slog.Info("registered with the coordinator", "heartbeat_interval", interval)
+			}
+		} else if res, err := client.Heartbeat(callCtx, info()); status.Code(err) == codes.NotFound {
+			// the coordinator restarted and forgot us
+			slog.Warn("coordinator does not know this worker, registering again")
+			registered = false
+			interval = retryInterval
+			cancel()
+			continue
+		} else if err != nil {
+			slog.Warn("failed to send a heartbeat to the coordinator", "err", err)
+		} else if len(res.Revoked) > 0 {
+			// the coordinator gave up on these jobs, they may already run elsewhere
+			slog.Warn("coordinator revoked job leases", "job_ids", res.Revoked)
+			revoke(res.Revoked)
+		}
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"main/core"
+	pb "main/proto"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// what the coordinator does with a job whose worker stops sending heartbeats
+const (
+	RestartNever  = "never"   // report the job as lost
+	RestartOnLost = "on-lost" // start it again on another worker, up to its max restarts
+)
+
+// restarts allowed by on-lost when the job does not say
+const defaultMaxRestarts = 3
+
+// state of a job whose worker stopped sending heartbeats
+const Lost = "lost"
+
+var ErrJobLost = errors.New("job was lost with its worker")
+
+func ValidateRestartPolicy(policy string, maxRestarts int32) error {
+	switch policy {
+	case "", RestartNever, RestartOnLost:
+	default:
+		return fmt.Errorf("%w: restart policy %q, expected %s or %s", core.ErrInvalidJob, policy, RestartNever, RestartOnLost)
+	}
+	if maxRestarts < 0 {
+		return fmt.Errorf("%w: negative max restarts", core.ErrInvalidJob)
+	}
+	return nil
+}
+
+// a worker's claim on a job, renewed by the worker's heartbeats; when it runs
+// out the job counts as lost
+type lease struct {
+	job      *pb.Job // as it was started
+	caller   string  // verified caller that started it, empty when anonymous
+	worker   string
+	granted  time.Time
+	expires  time.Time
+	restarts int32
+	lost     *pb.JobStatus // last known state while the job is lost
+}
+
+func (l *lease) maxRestarts() int32 {
+	if l.job.MaxRestarts > 0 {
+		return l.job.MaxRestarts
+	}
+	return defaultMaxRestarts
+}
+
+// hand the job to w, caller holds the lock
+func (r *Registry) grant(l *lease, w *Worker) {
+	l.worker = w.ID
+	l.granted = time.Now()
+	l.expires = l.granted.Add(r.leaseDuration())
+	w.leases[l.job.ID] = l
+}
+
+// grant the worker a lease on a job it just accepted
+func (r *Registry) Assign(job *pb.Job, w *Worker, caller string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	l := &lease{job: proto.Clone(job).(*pb.Job), caller: caller}
+	r.leases[job.ID] = l
+	delete(r.pending, job.ID)
+	r.grant(l, w)
+}
+
+// give a lost job to the worker that started it again, false when the restart
+// was cancelled in the meantime and the worker should stop it
+func (r *Registry) Restarted(jobId string, w *Worker) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	l := r.pending[jobId]
+	if l == nil {
+		return false
+	}
+	delete(r.pending, jobId)
+	l.restarts++
+	l.lost = nil
+	r.grant(l, w)
+	return true
+}
+
+// mark the jobs whose lease ran out as lost and return them
+// the ones whose restart policy allows it are queued for Pending
+func (r *Registry) Expire() []*pb.JobStatus {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := time.Now()
+	var lost []*pb.JobStatus
+	for _, w := range r.workers {
+		for id, l := range w.leases {
+			if now.Before(l.expires) {
+				continue
+			}
+			delete(w.leases, id)
+			job := proto.Clone(l.job).(*pb.Job)
+			job.State = Lost
+			job.Worker = w.ID
+			job.Restarts = l.restarts
+			l.lost = &pb.JobStatus{
+				Job:          job,
+				ExitCode:     -1,
+				ErrorMessage: fmt.Sprintf("lost: worker %s stopped sending heartbeats", w.ID),
+			}
+			lost = append(lost, l.lost)
+			// jobs adopted after a coordinator restart have no command to start again
+			if l.job.RestartPolicy == RestartOnLost && l.job.Cmd != "" && l.restarts < l.maxRestarts() {
+				r.pending[id] = l
+			}
+		}
+	}
+	return lost
+}
+
+// lost jobs waiting to be started again, oldest first
+func (r *Registry) Pending() []*pb.Job {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	jobs := make([]*pb.Job, 0, len(r.pending))
+	leases := make([]*lease, 0, len(r.pending))
+	for _, l := range r.pending {
+		leases = append(leases, l)
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i].granted.Before(leases[j].granted) })
+	for _, l := range leases {
+		jobs = append(jobs, proto.Clone(l.job).(*pb.Job))
+	}
+	return jobs
+}
+
+// verified caller that started a job, so a restart uses the same secrets
+func (r *Registry) Caller(jobId string) string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if l := r.leases[jobId]; l != nil {
+		return l.caller
+	}
+	return ""
+}
+
+// where a job is according to the coordinator
+type Placement struct {
+	Worker   *Worker       // nil while the job is lost
+	Lost     *pb.JobStatus // the job's last known state while it is lost
+	Restarts int32
+}
+
+// false when the coordinator doesn't know the job
+func (r *Registry) Locate(jobId string) (Placement, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	l := r.leases[jobId]
+	if l == nil {
+		return Placement{}, false
+	}
+	if l.lost != nil {
+		return Placement{Lost: proto.Clone(l.lost).(*pb.JobStatus), Restarts: l.restarts}, true
+	}
+	w := r.workers[l.worker]
+	return Placement{Worker: w, Restarts: l.restarts}, w != nil
+}
+
+// remember which worker runs a job the coordinator found by asking the workers
+func (r *Registry) Adopt(jobId string, w *Worker) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	// no lease yet, the job may have finished; the worker's next report grants one if it is still running
+	if r.leases[jobId] == nil {
+		r.leases[jobId] = &lease{job: &pb.Job{ID: jobId}, worker: w.ID}
+	}
+}
+
+// the lost jobs with labels matching sel
+func (r *Registry) LostJobs(sel core.Selector) []*pb.JobStatus {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	var list []*pb.JobStatus
+	for _, l := range r.leases {
+		if l.lost != nil && sel.Matches(l.job.Labels) {
+			list = append(list, proto.Clone(l.lost).(*pb.JobStatus))
+		}
+	}
+	return list
+}
+
+// stop a lost job from being started again, ErrJobLost when it wasn't going to be
+func (r *Registry) CancelRestart(jobId string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.pending[jobId]; !ok {
+		return fmt.Errorf("%w: %s", ErrJobLost, jobId)
+	}
+	delete(r.pending, jobId)
+	return nil
+}
+
+// drop a deleted job, its restart is cancelled if it was lost
+func (r *Registry) Forget(jobId string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if l := r.leases[jobId]; l != nil {
+		if w := r.workers[l.worker]; w != nil {
+			delete(w.leases, jobId)
+		}
+	}
+	delete(r.leases, jobId)
+	delete(r.pending, jobId)
+}
@@ -0,0 +1,162 @@
+package cluster
+
+import (
+	"errors"
+	"main/core"
+	pb "main/proto"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func newTestRegistry(t *testing.T, workers ...string) *Registry {
+	t.Helper()
+	r := NewRegistry(time.Hour, insecure.NewCredentials())
+	t.Cleanup(r.Close)
+	for _, id := range workers {
+		if err := r.Register(&pb.WorkerInfo{Id: id, Address: "127.0.0.1:1", Incarnation: "1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+// let the lease of a job run out as if its worker had gone silent
+func expireLease(r *Registry, jobId string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.leases[jobId].expires = time.Now().Add(-time.Millisecond)
+}
+
+func TestValidateRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy      string
+		maxRestarts int32
+		err         bool
+	}{
+		{policy: ""},
+		{policy: RestartNever},
+		{policy: RestartOnLost, maxRestarts: 5},
+		{policy: "always", err: true},
+		{policy: RestartOnLost, maxRestarts: -1, err: true},
+	}
+	for _, tt := range tests {
+		err := ValidateRestartPolicy(tt.policy, tt.maxRestarts)
+		if got := errors.Is(err, core.ErrInvalidJob); got != tt.err {
+			t.Errorf("ValidateRestartPolicy(%q, %d) = %v, want error %v", tt.policy, tt.maxRestarts, err, tt.err)
+		}
+	}
+}
+
+func TestExpireQueuesRestarts(t *testing.T) {
+	tests := []struct {
+		name     string
+		job      *pb.Job
+		restarts int32
+		pending  bool
+	}{
+		{name: "never", job: &pb.Job{ID: "a", Cmd: "true", RestartPolicy: RestartNever}},
+		{name: "default policy", job: &pb.Job{ID: "a", Cmd: "true"}},
+		{name: "on-lost", job: &pb.Job{ID: "a", Cmd: "true", RestartPolicy: RestartOnLost}, pending: true},
+		{name: "on-lost below default max", job: &pb.Job{ID: "a", Cmd: "true", RestartPolicy: RestartOnLost}, restarts: defaultMaxRestarts - 1, pending: true},
+		{name: "on-lost at default max", job: &pb.Job{ID: "a", Cmd: "true", RestartPolicy: RestartOnLost}, restarts: defaultMaxRestarts},
+		{name: "on-lost at own max", job: &pb.Job{ID: "a", Cmd: "true", RestartPolicy: RestartOnLost, MaxRestarts: 1}, restarts: 1},
+		{name: "adopted without command", job: &pb.Job{ID: "a", RestartPolicy: RestartOnLost}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistry(t, "w1")
+			w := r.workers["w1"]
+			r.Assign(tt.job, w, "alice")
+			r.leases["a"].restarts = tt.restarts
+			if lost := r.Expire(); len(lost) != 0 {
+				t.Fatalf("lost %d jobs before the lease ran out", len(lost))
+			}
+			expireLease(r, "a")
+			lost := r.Expire()
+			if len(lost) != 1 || lost[0].Job.State != Lost || lost[0].Job.Worker != "w1" || lost[0].Job.Restarts != tt.restarts {
+				t.Fatalf("Expire() = %v, want job a lost on w1", lost)
+			}
+			if pending := r.Pending(); (len(pending) == 1) != tt.pending {
+				t.Errorf("Pending() = %v, want pending %v", pending, tt.pending)
+			}
+			placement, ok := r.Locate("a")
+			if !ok || placement.Worker != nil || placement.Lost == nil {
+				t.Errorf("Locate(a) = %+v, %v, want the lost job", placement, ok)
+			}
+			if lost := r.Expire(); len(lost) != 0 {
+				t.Errorf("a lost job was lost again: %v", lost)
+			}
+		})
+	}
+}
+
+func TestRestartLostJob(t *testing.T) {
+	r := newTestRegistry(t, "w1", "w2")
+	r.Assign(&pb.Job{ID: "a", Cmd: "true", RestartPolicy: RestartOnLost, MaxRestarts: 1}, r.workers["w1"], "alice")
+
+	// a heartbeat listing the job renews its lease
+	expireLease(r, "a")
+	if _, err := r.Heartbeat(&pb.WorkerInfo{Id: "w1", Address: "127.0.0.1:1", Incarnation: "1", Jobs: []string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if lost := r.Expire(); len(lost) != 0 {
+		t.Fatalf("renewed job was lost: %v", lost)
+	}
+
+	expireLease(r, "a")
+	r.Expire()
+	if !r.Restarted("a", r.workers["w2"]) {
+		t.Fatal("Restarted(a) = false for a pending job")
+	}
+	placement, ok := r.Locate("a")
+	if !ok || placement.Worker == nil || placement.Worker.ID != "w2" || placement.Restarts != 1 {
+		t.Fatalf("Locate(a) = %+v, %v, want w2 after one restart", placement, ok)
+	}
+	if caller := r.Caller("a"); caller != "alice" {
+		t.Errorf("Caller(a) = %q after the restart, want alice", caller)
+	}
+	// the old worker comes back with its copy, it has to stop it
+	revoked, err := r.Heartbeat(&pb.WorkerInfo{Id: "w1", Address: "127.0.0.1:1", Incarnation: "1", Jobs: []string{"a"}})
+	if err != nil || len(revoked) != 1 || revoked[0] != "a" {
+		t.Errorf("Heartbeat of the old worker = %v, %v, want a revoked", revoked, err)
+	}
+
+	// out of restarts now
+	expireLease(r, "a")
+	r.Expire()
+	if pending := r.Pending(); len(pending) != 0 {
+		t.Errorf("Pending() = %v past max restarts", pending)
+	}
+	if err := r.CancelRestart("a"); !errors.Is(err, ErrJobLost) {
+		t.Errorf("CancelRestart(a) = %v, want ErrJobLost", err)
+	}
+	if r.Restarted("a", r.workers["w1"]) {
+		t.Error("Restarted(a) = true for a job that wasn't pending")
+	}
+}
+
+func TestCancelRestart(t *testing.T) {
+	r := newTestRegistry(t, "w1")
+	r.Assign(&pb.Job{ID: "a", Cmd: "true", RestartPolicy: RestartOnLost}, r.workers["w1"], "")
+	expireLease(r, "a")
+	r.Expire()
+	if err := r.CancelRestart("a"); err != nil {
+		t.Fatalf("CancelRestart(a) = %v", err)
+	}
+	if pending := r.Pending(); len(pending) != 0 {
+		t.Errorf("Pending() = %v after CancelRestart", pending)
+	}
+}
+
+func TestRegisterNewIncarnationLosesJobs(t *testing.T) {
+	r := newTestRegistry(t, "w1")
+	r.Assign(&pb.Job{ID: "a", Cmd: "true"}, r.workers["w1"], "")
+	if err := r.Register(&pb.WorkerInfo{Id: "w1", Address: "127.0.0.1:1", Incarnation: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if lost := r.Expire(); len(lost) != 1 || lost[0].Job.ID != "a" {
+		t.Errorf("Expire() = %v after the worker restarted, want a lost", lost)
+	}
+}
@@ -0,0 +1,236 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"main/core"
+	pb "main/proto"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	ErrInvalidWorker = errors.New("invalid worker")
+	ErrUnknownWorker = errors.New("worker is not registered")
+	ErrNoWorker      = errors.New("no live worker matches")
+)
+
+// a worker that missed this many heartbeats gets no new jobs
+const missedHeartbeats = 3
+
+// a registered worker and the connection to its JobManager
+type Worker struct {
+	ID     string
+	Client pb.JobManagerClient
+
+	address  string
+	labels   map[string]string
+	capacity int32
+	active   int32
+	lastSeen time.Time
+	conn     *grpc.ClientConn
+	// the process the worker runs as, its jobs die when it changes
+	incarnation string
+	leases      map[string]*lease // by job ID, of the jobs it is running
+}
+
+// the coordinator's view of its workers and of which worker runs which job
+type Registry struct {
+	lock     sync.RWMutex
+	interval time.Duration
+	creds    credentials.TransportCredentials
+	workers  map[string]*Worker
+	leases   map[string]*lease // by job ID, of every job started through the coordinator
+	pending  map[string]*lease // lost jobs waiting to be started again
+}
+
+// interval is how often workers have to report, creds are used to call them
+func NewRegistry(interval time.Duration, creds credentials.TransportCredentials) *Registry {
+	return &Registry{
+		interval: interval,
+		creds:    creds,
+		workers:  make(map[string]*Worker),
+		leases:   make(map[string]*lease),
+		pending:  make(map[string]*lease),
+	}
+}
+
+func (r *Registry) HeartbeatInterval() time.Duration {
+	return r.interval
+}
+
+// add a worker, or replace it when it registers again e.g. after a restart
+func (r *Registry) Register(info *pb.WorkerInfo) error {
+	if info.Id == "" || info.Address == "" {
+		return fmt.Errorf("%w: id and address are required", ErrInvalidWorker)
+	}
+	if info.Capacity < 0 || info.Active < 0 {
+		return fmt.Errorf("%w: negative capacity or load", ErrInvalidWorker)
+	}
+	if err := core.ValidateLabels(info.Labels); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWorker, err)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	w := r.workers[info.Id]
+	if w == nil || w.address != info.Address {
+		// the client connects lazily, an unreachable worker fails the calls routed to it
+		conn, err := grpc.NewClient(info.Address, grpc.WithTransportCredentials(r.creds))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidWorker, err)
+		}
+		leases := make(map[string]*lease)
+		if w != nil {
+			w.conn.Close()
+			leases = w.leases
+		}
+		w = &Worker{ID: info.Id, Client: pb.NewJobManagerClient(conn), address: info.Address, conn: conn, leases: leases}
+		r.workers[info.Id] = w
+	}
+	if w.incarnation != info.Incarnation {
+		// the worker restarted, whatever it ran before is gone
+		for _, l := range w.leases {
+			l.expires = time.Now()
+		}
+		w.incarnation = info.Incarnation
+	}
+	w.labels = info.Labels
+	r.report(w, info)
+	return nil
+}
+
+// record that a worker is alive, how busy it is and which jobs it still runs
+// returns the jobs the worker no longer holds a lease for
+func (r *Registry) Heartbeat(info *pb.WorkerInfo) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	w := r.workers[info.Id]
+	if w == nil || w.address != info.Address || w.incarnation != info.Incarnation {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownWorker, info.Id)
+	}
+	return r.report(w, info), nil
+}
+
+// apply a worker's report, caller holds the lock
+func (r *Registry) report(w *Worker, info *pb.WorkerInfo) []string {
+	now := time.Now()
+	w.capacity = info.Capacity
+	w.active = info.Active
+	w.lastSeen = now
+	var revoked []string
+	listed := make(map[string]bool, len(info.Jobs))
+	for _, id := range info.Jobs {
+		listed[id] = true
+		l := r.leases[id]
+		switch {
+		case l == nil:
+			// started before the coordinator restarted, it can't be started again without its spec
+			l = &lease{job: &pb.Job{ID: id}}
+			r.leases[id] = l
+			r.grant(l, w)
+		case l.worker == w.ID && l.lost == nil:
+			l.expires = now.Add(r.leaseDuration())
+			w.leases[id] = l
+		default:
+			// declared lost or moved to another worker, this copy must not keep running
+			revoked = append(revoked, id)
+		}
+	}
+	for id, l := range w.leases {
+		// a job missing from the report has finished, unless it was granted after the report was put together
+		if !listed[id] && now.Sub(l.granted) > r.interval && now.Before(l.expires) {
+			delete(w.leases, id)
+		}
+	}
+	return revoked
+}
+
+func (r *Registry) alive(w *Worker) bool {
+	return time.Since(w.lastSeen) <= r.leaseDuration()
+}
+
+// how long a job stays with its worker without hearing from it
+func (r *Registry) leaseDuration() time.Duration {
+	return missedHeartbeats * r.interval
+}
+
+// slots left on w, workers without a limit count as having plenty
+func (w *Worker) free() int64 {
+	if w.capacity == 0 {
+		return math.MaxInt32 - int64(w.active)
+	}
+	return int64(w.capacity) - int64(w.active)
+}
+
+// choose the live worker matching sel with the most free slots for a new job
+// when every match is full the least loaded one is chosen, the job queues there
+func (r *Registry) Pick(sel core.Selector) (*Worker, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var best *Worker
+	for _, w := range r.workers {
+		if !r.alive(w) || !sel.Matches(w.labels) {
+			continue
+		}
+		if best == nil || w.free() > best.free() || w.free() == best.free() && w.ID < best.ID {
+			best = w
+		}
+	}
+	if best == nil {
+		return nil, ErrNoWorker
+	}
+	// count the job right away so a burst of starts is spread before the next heartbeat
+	best.active++
+	return best, nil
+}
+
+// the workers heard from recently, sorted by ID
+func (r *Registry) Alive() []*Worker {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	var workers []*Worker
+	for _, w := range r.workers {
+		if r.alive(w) {
+			workers = append(workers, w)
+		}
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].ID < workers[j].ID })
+	return workers
+}
+
+// every registered worker sorted by ID
+func (r *Registry) List() []*pb.WorkerStatus {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	list := make([]*pb.WorkerStatus, 0, len(r.workers))
+	for _, w := range r.workers {
+		list = append(list, &pb.WorkerStatus{
+			Worker: &pb.WorkerInfo{
+				Id:       w.ID,
+				Address:  w.address,
+				Labels:   w.labels,
+				Capacity: w.capacity,
+				Active:   w.active,
+			},
+			LastHeartbeat: timestamppb.New(w.lastSeen),
+			Alive:         r.alive(w),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Worker.Id < list[j].Worker.Id })
+	return list
+}
+
+// close the connections to every worker
+func (r *Registry) Close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, w := range r.workers {
+		w.conn.Close()
+	}
+}
@@ -0,0 +1,97 @@
+package command
+
+import (
+	"errors"
+	"io"
+	pb "main/proto"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func ArtifactCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "artifact",
+		Short: "List and download files collected from finished jobs",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list <job-id>",
+		Short: "List the artifacts of a job",
+		Args:  usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			res, err := client.ListArtifacts(ctx, &pb.JobID{Id: args[0]})
+			if err != nil {
+				return err
+			}
+			return printArtifacts(cmd, res.Artifacts)
+		},
+	})
+	cmd.AddCommand(artifactGetCommand())
+	return cmd
+}
+
+func artifactGetCommand() *cobra.Command {
+	var outFile string
+	var resume bool
+	cmd := &cobra.Command{
+		Use:   "get <job-id> <path>",
+		Short: "Download an artifact, to stdout unless --out-file is given",
+		Args:  usageArgs(cobra.ExactArgs(2)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if resume && outFile == "" {
+				return usageError(errors.New("--continue needs --out-file"))
+			}
+			out := cmd.OutOrStdout()
+			req := &pb.ArtifactRequest{Id: args[0], Path: args[1]}
+			if outFile != "" {
+				flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+				if resume {
+					flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+				}
+				f, err := os.OpenFile(outFile, flags, 0o644)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				if resume {
+					info, err := f.Stat()
+					if err != nil {
+						return err
+					}
+					req.Offset = info.Size()
+				}
+				out = f
+			}
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			stream, err := client.DownloadArtifact(cmd.Context(), req)
+			if err != nil {
+				return err
+			}
+			for {
+				chunk, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				if _, err := out.Write(chunk.Data); err != nil {
+					return err
+				}
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&outFile, "out-file", "O", "", "write the artifact to this file")
+	cmd.Flags().BoolVarP(&resume, "continue", "c", false, "append to a partial --out-file from an earlier download")
+	return cmd
+}
@@ -0,0 +1,142 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"main/config"
+	pb "main/proto"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// exit status of the cli
+const (
+	ExitOK          = 0
+	ExitFailure     = 1   // the request failed on the server
+	ExitUsage       = 2   // invalid flags, arguments or configuration
+	ExitUnavailable = 3   // server unreachable, draining or the request timed out
+	ExitNotFound    = 4   // the job does not exist
+	ExitTimeout     = 124 // wait gave up before the jobs finished, as timeout(1) does
+)
+
+// settings shared by every subcommand, completed by Configure before any command runs
+var clientConfig = config.DefaultClientConfig()
+
+// error carrying the exit status the cli should end with
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+func usageError(err error) error {
+	return &ExitError{Code: ExitUsage, Err: err}
+}
+
+// end the cli with the exit code of a job, without printing an error
+func jobExitError(code int32) error {
+	if code == 0 {
+		return nil
+	}
+	if code < 0 || code > 255 {
+		code = ExitFailure
+	}
+	return &ExitError{Code: int(code)}
+}
+
+// exit status for an error returned by a command
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.InvalidArgument:
+			return ExitUsage
+		case codes.NotFound:
+			return ExitNotFound
+		case codes.Unavailable, codes.DeadlineExceeded:
+			return ExitUnavailable
+		}
+	}
+	return ExitFailure
+}
+
+// add the connection flags to the root command and load the client config before any subcommand runs
+func Configure(root *cobra.Command) {
+	clientConfig.RegisterFlags(root.PersistentFlags())
+	root.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputFormat, "output format: table, json, yaml or template=<go template> (applied to each job)")
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := clientConfig.Load(cmd.Root().PersistentFlags()); err != nil {
+			return usageError(err)
+		}
+		if err := parseOutputFormat(); err != nil {
+			return usageError(err)
+		}
+		return nil
+	}
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError(err)
+	})
+	root.SilenceUsage = true
+	root.SilenceErrors = true
+}
+
+// wrap a cobra argument check so a mismatch exits with ExitUsage
+func usageArgs(check cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := check(cmd, args); err != nil {
+			return usageError(err)
+		}
+		return nil
+	}
+}
+
+// connect to the configured server, the caller closes the connection
+func dial() (pb.JobManagerClient, *grpc.ClientConn, error) {
+	creds, err := clientConfig.TLS.Credentials()
+	if err != nil {
+		return nil, nil, usageError(fmt.Errorf("loading tls credentials: %w", err))
+	}
+	conn, err := grpc.NewClient(clientConfig.Server, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, nil, usageError(fmt.Errorf("connecting to %s: %w", clientConfig.Server, err))
+	}
+	return pb.NewJobManagerClient(conn), conn, nil
+}
+
+// context of a unary call, bounded by --timeout
+// streaming calls use cmd.Context() directly, which main cancels on SIGINT/SIGTERM
+func callContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(cmd.Context(), clientConfig.Timeout)
+}
+
+// error text for the user, without the rpc error prefix, empty when nothing should be printed
+func ErrorMessage(err error) string {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) && exitErr.Err == nil {
+		return ""
+	}
+	if st, ok := status.FromError(err); ok {
+		return st.Message()
+	}
+	return err.Error()
+}
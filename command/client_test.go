@@ -0,0 +1,185 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"main/config"
+	pb "main/proto"
+	"net"
+	"os/exec"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// a server answering the calls a test sets up, the others are unimplemented
+type fakeServer struct {
+	pb.UnimplementedJobManagerServer
+	mu     sync.Mutex
+	jobs   []*pb.Job // Start requests
+	start  func(*pb.Job) (*pb.Job, error)
+	query  func(*pb.JobID) (*pb.JobStatus, error)
+	list   func() (*pb.JobStatusList, error)
+	wait   func(*pb.WaitRequest) (*pb.WaitResponse, error)
+	output func(*pb.OutputRequest, pb.JobManager_StreamOutputServer) error
+}
+
+func (f *fakeServer) Start(_ context.Context, in *pb.Job) (*pb.Job, error) {
+	f.mu.Lock()
+	f.jobs = append(f.jobs, in)
+	f.mu.Unlock()
+	return f.start(in)
+}
+
+func (f *fakeServer) Query(_ context.Context, in *pb.JobID) (*pb.JobStatus, error) {
+	return f.query(in)
+}
+
+func (f *fakeServer) List(context.Context, *pb.ListRequest) (*pb.JobStatusList, error) {
+	return f.list()
+}
+
+func (f *fakeServer) Wait(_ context.Context, in *pb.WaitRequest) (*pb.WaitResponse, error) {
+	return f.wait(in)
+}
+
+func (f *fakeServer) StreamOutput(in *pb.OutputRequest, stream pb.JobManager_StreamOutputServer) error {
+	return f.output(in, stream)
+}
+
+// serve f on a local port and return its address
+func serveFake(t *testing.T, f *fakeServer) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	pb.RegisterJobManagerServer(s, f)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	return l.Addr().String()
+}
+
+// run the cli against the server at addr, returning what it printed and its exit status
+func runCLI(t *testing.T, addr string, args ...string) (string, string, int) {
+	t.Helper()
+	clientConfig = config.DefaultClientConfig()
+	outputFormat, outputTemplate = "table", nil
+	root := &cobra.Command{Use: "jobs"}
+	Configure(root)
+	root.AddCommand(StartCommand(), QueryCommand(), ListCommand(), LogsCommand(), WaitCommand())
+	var stdout, stderr bytes.Buffer
+	root.SetOut(&stdout)
+	root.SetErr(&stderr)
+	root.SetArgs(append([]string{"--server", addr}, args...))
+	err := root.ExecuteContext(context.Background())
+	if msg := ErrorMessage(err); msg != "" {
+		stderr.WriteString("Error: " + msg + "\n")
+	}
+	return stdout.String(), stderr.String(), ExitCode(err)
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{err: nil, code: ExitOK},
+		{err: errors.New("boom"), code: ExitFailure},
+		{err: usageError(errors.New("bad flag")), code: ExitUsage},
+		{err: jobExitError(3), code: 3},
+		{err: jobExitError(-1), code: ExitFailure},
+		{err: jobExitError(300), code: ExitFailure},
+		{err: status.Error(codes.InvalidArgument, "x"), code: ExitUsage},
+		{err: status.Error(codes.NotFound, "x"), code: ExitNotFound},
+		{err: status.Error(codes.Unavailable, "x"), code: ExitUnavailable},
+		{err: status.Error(codes.DeadlineExceeded, "x"), code: ExitUnavailable},
+		{err: status.Error(codes.PermissionDenied, "x"), code: ExitFailure},
+	}
+	for _, tt := range tests {
+		if code := ExitCode(tt.err); code != tt.code {
+			t.Errorf("ExitCode(%v) = %d, want %d", tt.err, code, tt.code)
+		}
+	}
+	if jobExitError(0) != nil {
+		t.Error("jobExitError(0) is an error")
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"make && make test"}, want: "make && make test"},
+		{args: []string{"printf", "%s|", "two words", "it's", "$HOME"}, want: "two words|it's|$HOME|"},
+	}
+	for _, tt := range tests {
+		line := commandLine(tt.args)
+		if len(tt.args) == 1 {
+			if line != tt.want {
+				t.Errorf("commandLine(%q) = %q, want it unchanged", tt.args, line)
+			}
+			continue
+		}
+		// every argument reaches the command as it is
+		out, err := exec.Command("sh", "-c", line).Output()
+		if err != nil || string(out) != tt.want {
+			t.Errorf("commandLine(%q) = %q printing %q, %v, want %q", tt.args, line, out, err, tt.want)
+		}
+	}
+}
+
+func TestStartCommand(t *testing.T) {
+	f := &fakeServer{start: func(in *pb.Job) (*pb.Job, error) {
+		if in.Cmd == "missing" {
+			return nil, status.Error(codes.NotFound, "no such thing")
+		}
+		return &pb.Job{ID: "0f8fad5b-d9cb-469f-a165-70867728950e"}, nil
+	}}
+	addr := serveFake(t, f)
+	stdout, stderr, code := runCLI(t, addr, "start", "-l", "team=a", "-e", "X=1", "-w", "/tmp", "--job-timeout", "1m", "--", "echo", "a b", "-n")
+	if code != ExitOK || stdout != "0f8fad5b-d9cb-469f-a165-70867728950e\n" {
+		t.Fatalf("start = %q, %q, exit %d", stdout, stderr, code)
+	}
+	job := f.jobs[0]
+	want := &pb.Job{Cmd: "'echo' 'a b' '-n'", Labels: map[string]string{"team": "a"}, Env: map[string]string{"X": "1"}, Workdir: "/tmp", TimeoutSeconds: 60}
+	if job.Cmd != want.Cmd || !reflect.DeepEqual(job.Labels, want.Labels) || !reflect.DeepEqual(job.Env, want.Env) || job.Workdir != want.Workdir || job.TimeoutSeconds != want.TimeoutSeconds {
+		t.Errorf("started %v, want %v", job, want)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{name: "no command", args: []string{"start"}, code: ExitUsage},
+		{name: "fractional timeout", args: []string{"start", "--job-timeout", "1.5s", "--", "true"}, code: ExitUsage},
+		{name: "param without template", args: []string{"start", "-p", "x=1", "--", "true"}, code: ExitUsage},
+		{name: "template with command", args: []string{"start", "-t", "build", "--", "true"}, code: ExitUsage},
+		{name: "unknown flag", args: []string{"start", "--bogus", "--", "true"}, code: ExitUsage},
+		{name: "server error", args: []string{"start", "missing"}, code: ExitNotFound},
+	}
+	for _, tt := range tests {
+		if _, stderr, code := runCLI(t, addr, tt.args...); code != tt.code || stderr == "" {
+			t.Errorf("%s: exit %d with %q, want %d and an error", tt.name, code, stderr, tt.code)
+		}
+	}
+
+	// nothing listens there any more
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if _, _, code := runCLI(t, l.Addr().String(), "start", "true"); code != ExitUnavailable {
+		t.Errorf("start without a server: exit %d, want %d", code, ExitUnavailable)
+	}
+}
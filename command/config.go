@@ -0,0 +1,23 @@
+package command
+
+import (
+	"main/config"
+
+	"github.com/spf13/cobra"
+)
+
+func ConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the client configuration",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "print",
+		Short: "Print the effective configuration from flags, environment and config file",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return config.Print(clientConfig)
+		},
+	})
+	return cmd
+}
@@ -0,0 +1,26 @@
+package command
+
+import (
+	pb "main/proto"
+
+	"github.com/spf13/cobra"
+)
+
+func DeleteCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <job-id>",
+		Short: "Delete a finished job and its output",
+		Args:  usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			_, err = client.Delete(ctx, &pb.JobID{Id: args[0]})
+			return err
+		},
+	}
+}
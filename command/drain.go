@@ -0,0 +1,43 @@
+package command
+
+import (
+	"fmt"
+	"main/core"
+	pb "main/proto"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func DrainCommand() *cobra.Command {
+	var policy string
+	var wait time.Duration
+	cmd := &cobra.Command{
+		Use:   "drain",
+		Short: "Stop the server from accepting new jobs",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			// waiting for jobs may take longer than a normal request
+			ctx, cancel := callContext(cmd)
+			if policy == core.DrainWait {
+				cancel()
+				ctx = cmd.Context()
+			}
+			defer cancel()
+			res, err := client.Drain(ctx, &pb.DrainRequest{Policy: policy, TimeoutSeconds: int32(wait.Seconds())})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "active jobs: %d\n", res.ActiveJobs)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&policy, "policy", core.DrainWait, "what to do with running jobs: wait, stop or detach")
+	cmd.Flags().DurationVar(&wait, "wait-timeout", 0, "with --policy wait, give up after this long, 0 means no limit")
+	return cmd
+}
@@ -1,22 +1,37 @@
 package command
 
 import (
-	"main/core"
+	pb "main/proto"
 
 	"github.com/spf13/cobra"
 )
 
 func ListCommand() *cobra.Command {
-	return &cobra.Command{
+	var selector string
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List all jobs",
-		Args:  cobra.NoArgs,
-		Run: func(cmd *cobra.Command, args []string) {
-			jp := core.JobDispatcher{}
-			res := jp.ListJobs()
-			for _, job := range res {
-				println(job)
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
 			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			res, err := client.List(ctx, &pb.ListRequest{Selector: selector})
+			if err != nil {
+				return err
+			}
+			return printJobs(cmd, res.JobStatusList)
 		},
 	}
+	addSelectorFlag(cmd, &selector)
+	return cmd
+}
+
+// -l/--selector, shared by the commands that act on jobs by label
+func addSelectorFlag(cmd *cobra.Command, selector *string) {
+	cmd.Flags().StringVarP(selector, "selector", "l", "", "only jobs whose labels match, e.g. 'team=infra,env in (prod,staging),!canary'")
 }
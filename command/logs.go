@@ -0,0 +1,133 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"main/core"
+	pb "main/proto"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func LogsCommand() *cobra.Command {
+	var tail int32
+	var follow, timestamps bool
+	var since string
+	cmd := &cobra.Command{
+		Use:   "logs <job-id>",
+		Short: "Print the output of a job",
+		Long: "Print the output of a job. Once the job has finished, logs exits with the job's exit code; " +
+			"without --follow a job that is still running gives exit code 0.",
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &pb.OutputRequest{Id: args[0], Tail: tail, Follow: follow}
+			if since != "" {
+				t, err := core.ParseSince(since)
+				if err != nil {
+					return usageError(err)
+				}
+				req.Since = timestamppb.New(t)
+			}
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			if err := printOutput(cmd, client, req, timestamps); err != nil {
+				return err
+			}
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			jobStatus, err := client.Query(ctx, &pb.JobID{Id: args[0]})
+			if err != nil {
+				return err
+			}
+			if jobStatus.Job.GetState() != core.Finished {
+				return nil
+			}
+			return jobExitError(jobStatus.ExitCode)
+		},
+	}
+	cmd.Flags().Int32Var(&tail, "tail", 0, "only print the last N lines, 0 means all")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "keep printing new output until the job finishes")
+	cmd.Flags().StringVar(&since, "since", "", "only print lines captured after this time, an RFC 3339 time or a duration like 10m")
+	cmd.Flags().BoolVarP(&timestamps, "timestamps", "t", false, "prefix each line with the time it was captured")
+	return cmd
+}
+
+// attempts to resume a followed stream that broke off, with a growing pause
+const outputRetries = 5
+
+// print the output req asks for, the job's stdout to stdout and everything
+// else to stderr; output is received as raw chunks, or as lines to prefix
+// them with timestamps. A followed chunk stream that breaks is resumed after
+// the last byte received.
+func printOutput(cmd *cobra.Command, client pb.JobManagerClient, req *pb.OutputRequest, timestamps bool) error {
+	req.Chunks = !timestamps
+	for attempt := 1; ; attempt++ {
+		received, err := receiveOutput(cmd, client, req, timestamps)
+		if err == nil {
+			return nil
+		}
+		if !req.Follow || !req.Chunks || status.Code(err) != codes.Unavailable || cmd.Context().Err() != nil {
+			return err
+		}
+		if received {
+			attempt = 1
+		}
+		if attempt > outputRetries {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "output stream broke off, resuming at byte %d: %v\n", req.ByteOffset, status.Convert(err).Message())
+		select {
+		case <-time.After(time.Duration(attempt) * time.Second):
+		case <-cmd.Context().Done():
+			return err
+		}
+	}
+}
+
+// print one stream of output, moving req past every chunk received; true
+// when anything was received
+func receiveOutput(cmd *cobra.Command, client pb.JobManagerClient, req *pb.OutputRequest, timestamps bool) (bool, error) {
+	stream, err := client.StreamOutput(cmd.Context(), req)
+	if err != nil {
+		return false, err
+	}
+	received := false
+	for {
+		output, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return received, nil
+		} else if err != nil {
+			return received, err
+		}
+		received = true
+		out := cmd.OutOrStdout()
+		if output.Stream != "" && output.Stream != core.StreamStdout {
+			out = cmd.ErrOrStderr()
+		}
+		if timestamps {
+			fmt.Fprintf(out, "%s ", output.Time.AsTime().Local().Format(time.RFC3339Nano))
+		}
+		if req.Chunks {
+			out.Write(output.Output)
+			// a resumed stream starts right after this chunk, a notice of dropped
+			// output takes up no room
+			req.ByteOffset = output.Offset
+			if output.Dropped == 0 {
+				req.ByteOffset += int64(len(output.Output))
+			}
+			req.Offset, req.Tail = 0, 0
+		} else if output.Newline {
+			out.Write(append(output.Output, '\n'))
+		} else {
+			out.Write(output.Output)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package command
+
+import (
+	"main/core"
+	pb "main/proto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func finishedJob(code int32) func(*pb.JobID) (*pb.JobStatus, error) {
+	return func(in *pb.JobID) (*pb.JobStatus, error) {
+		return &pb.JobStatus{Job: &pb.Job{ID: in.Id, State: core.Finished}, ExitCode: code}, nil
+	}
+}
+
+func TestLogsCommand(t *testing.T) {
+	captured := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var requests []*pb.OutputRequest
+	f := &fakeServer{query: finishedJob(3), output: func(in *pb.OutputRequest, stream pb.JobManager_StreamOutputServer) error {
+		requests = append(requests, proto.Clone(in).(*pb.OutputRequest))
+		var messages []*pb.JobOutput
+		if in.Chunks {
+			messages = []*pb.JobOutput{
+				{Output: []byte("out 1\nout 2\n"), Stream: core.StreamStdout},
+				{Output: []byte("err\n"), Stream: core.StreamStderr},
+				{Output: []byte("no newline"), Stream: core.StreamStdout},
+			}
+		} else {
+			messages = []*pb.JobOutput{
+				{Output: []byte("Password: "), Stream: core.StreamStdout, Time: timestamppb.New(captured)},
+				{Output: []byte("ok"), Stream: core.StreamStdout, Time: timestamppb.New(captured), Newline: true},
+			}
+		}
+		for _, m := range messages {
+			if err := stream.Send(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+	addr := serveFake(t, f)
+
+	stdout, stderr, code := runCLI(t, addr, "logs", "--tail", "5", "--since", "10m", "job")
+	if stdout != "out 1\nout 2\nno newline" || stderr != "err\n" || code != 3 {
+		t.Errorf("logs = %q, %q, exit %d, want the job's streams and exit code", stdout, stderr, code)
+	}
+	if req := requests[0]; !req.Chunks || req.Tail != 5 || req.Since == nil || req.Follow {
+		t.Errorf("request = %v", req)
+	}
+
+	// timestamps need lines
+	stdout, _, _ = runCLI(t, addr, "logs", "-t", "job")
+	ts := captured.Local().Format(time.RFC3339Nano)
+	if want := ts + " Password: " + ts + " ok\n"; stdout != want {
+		t.Errorf("logs -t = %q, want %q", stdout, want)
+	}
+	if requests[1].Chunks {
+		t.Error("logs -t asked for chunks")
+	}
+
+	if _, stderr, code := runCLI(t, addr, "logs", "--since", "yesterday", "job"); code != ExitUsage || stderr == "" {
+		t.Errorf("invalid --since: exit %d with %q", code, stderr)
+	}
+}
+
+func TestLogsFollowResumes(t *testing.T) {
+	var mu sync.Mutex
+	var offsets []int64
+	f := &fakeServer{query: finishedJob(0), output: func(in *pb.OutputRequest, stream pb.JobManager_StreamOutputServer) error {
+		mu.Lock()
+		offsets = append(offsets, in.ByteOffset)
+		first := len(offsets) == 1
+		mu.Unlock()
+		if first {
+			stream.Send(&pb.JobOutput{Output: []byte("abc"), Offset: 0})
+			// a notice of dropped output takes up no room
+			stream.Send(&pb.JobOutput{Output: []byte("[10 bytes dropped]"), Offset: 13, Dropped: 10, Stream: core.StreamSystem})
+			return status.Error(codes.Unavailable, "worker went away")
+		}
+		return stream.Send(&pb.JobOutput{Output: []byte("def\n"), Offset: in.ByteOffset})
+	}}
+	addr := serveFake(t, f)
+	stdout, stderr, code := runCLI(t, addr, "logs", "-f", "job")
+	if stdout != "abcdef\n" || code != ExitOK || !strings.Contains(stderr, "resuming at byte 13") {
+		t.Errorf("logs -f = %q, %q, exit %d", stdout, stderr, code)
+	}
+	if len(offsets) != 2 || offsets[1] != 13 {
+		t.Errorf("requested offsets %v, want a resume at 13", offsets)
+	}
+}
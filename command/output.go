@@ -0,0 +1,520 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	pb "main/proto"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// --output, one of table, json, yaml or template=<go template>
+var outputFormat = "table"
+
+// parsed form of a template= output format
+var outputTemplate *template.Template
+
+// job as printed by the cli, the field names are part of the cli's interface
+// for scripts and must not change
+type jobView struct {
+	ID       string            `json:"id" yaml:"id"`
+	Command  string            `json:"command" yaml:"command"`
+	User     string            `json:"user" yaml:"user"`
+	State    string            `json:"state" yaml:"state"`
+	ExitCode int32             `json:"exit_code" yaml:"exit_code"`
+	Error    string            `json:"error" yaml:"error"`
+	Labels   map[string]string `json:"labels" yaml:"labels"`
+	Worker   string            `json:"worker,omitempty" yaml:"worker,omitempty"` // set by a coordinator
+	Restarts int32             `json:"restarts,omitempty" yaml:"restarts,omitempty"`
+	// output was discarded because of the job's output limit
+	Truncated bool `json:"truncated,omitempty" yaml:"truncated,omitempty"`
+	// only shown by query
+	StartedAt  *time.Time     `json:"started_at,omitempty" yaml:"started_at,omitempty"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty" yaml:"finished_at,omitempty"`
+	Webhooks   []deliveryView `json:"webhook_deliveries,omitempty" yaml:"webhook_deliveries,omitempty"`
+}
+
+type deliveryView struct {
+	URL        string    `json:"url" yaml:"url"`
+	Attempt    int32     `json:"attempt" yaml:"attempt"`
+	Time       time.Time `json:"time" yaml:"time"`
+	StatusCode int32     `json:"status_code" yaml:"status_code"`
+	Error      string    `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type templateView struct {
+	Name           string            `json:"name" yaml:"name"`
+	Command        string            `json:"command" yaml:"command"`
+	Env            map[string]string `json:"env" yaml:"env"`
+	Workdir        string            `json:"workdir" yaml:"workdir"`
+	TimeoutSeconds int32             `json:"timeout_seconds" yaml:"timeout_seconds"`
+	Labels         map[string]string `json:"labels" yaml:"labels"`
+	Params         []paramView       `json:"params" yaml:"params"`
+	Owner          string            `json:"owner,omitempty" yaml:"owner,omitempty"`
+}
+
+type paramView struct {
+	Name        string `json:"name" yaml:"name"`
+	Default     string `json:"default" yaml:"default"`
+	Required    bool   `json:"required" yaml:"required"`
+	Description string `json:"description" yaml:"description"`
+}
+
+type artifactView struct {
+	Path string `json:"path" yaml:"path"`
+	Size int64  `json:"size" yaml:"size"`
+}
+
+type secretView struct {
+	Name    string    `json:"name" yaml:"name"`
+	Owner   string    `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Created time.Time `json:"created" yaml:"created"`
+	Updated time.Time `json:"updated" yaml:"updated"`
+}
+
+type workerView struct {
+	ID            string            `json:"id" yaml:"id"`
+	Address       string            `json:"address" yaml:"address"`
+	Labels        map[string]string `json:"labels" yaml:"labels"`
+	Capacity      int32             `json:"capacity" yaml:"capacity"`
+	Active        int32             `json:"active" yaml:"active"`
+	Alive         bool              `json:"alive" yaml:"alive"`
+	LastHeartbeat time.Time         `json:"last_heartbeat" yaml:"last_heartbeat"`
+}
+
+type quotaView struct {
+	User        string  `json:"user,omitempty" yaml:"user,omitempty"`
+	Group       string  `json:"group,omitempty" yaml:"group,omitempty"`
+	Running     int32   `json:"running" yaml:"running"`
+	MaxRunning  int32   `json:"max_running" yaml:"max_running"`
+	Queued      int32   `json:"queued" yaml:"queued"`
+	MaxQueued   int32   `json:"max_queued" yaml:"max_queued"`
+	CPUSeconds  float64 `json:"cpu_seconds" yaml:"cpu_seconds"`
+	MaxCPU      int64   `json:"cpu_seconds_per_day" yaml:"cpu_seconds_per_day"`
+	OutputBytes int64   `json:"output_bytes" yaml:"output_bytes"`
+	MaxOutput   int64   `json:"max_output_bytes" yaml:"max_output_bytes"`
+}
+
+type matchView struct {
+	ID     string    `json:"id" yaml:"id"`
+	Line   int64     `json:"line" yaml:"line"`
+	Stream string    `json:"stream" yaml:"stream"`
+	Time   time.Time `json:"time" yaml:"time"`
+	Text   string    `json:"text" yaml:"text"`
+}
+
+type statusView struct {
+	Version       string           `json:"version" yaml:"version"`
+	UptimeSeconds int64            `json:"uptime_seconds" yaml:"uptime_seconds"`
+	Draining      bool             `json:"draining" yaml:"draining"`
+	QueueDepth    int32            `json:"queue_depth" yaml:"queue_depth"`
+	JobsByState   map[string]int32 `json:"jobs_by_state" yaml:"jobs_by_state"`
+}
+
+func newJobView(js *pb.JobStatus) jobView {
+	v := jobView{
+		ID:        js.Job.GetID(),
+		Command:   js.Job.GetCmd(),
+		User:      js.Job.GetUser(),
+		State:     js.Job.GetState(),
+		ExitCode:  js.ExitCode,
+		Error:     js.ErrorMessage,
+		Labels:    js.Job.GetLabels(),
+		Worker:    js.Job.GetWorker(),
+		Restarts:  js.Job.GetRestarts(),
+		Truncated: js.Truncated,
+	}
+	if v.Labels == nil {
+		v.Labels = map[string]string{}
+	}
+	return v
+}
+
+func newTemplateView(t *pb.JobTemplate) templateView {
+	v := templateView{
+		Name:           t.Name,
+		Command:        t.Cmd,
+		Env:            t.Env,
+		Workdir:        t.Workdir,
+		TimeoutSeconds: t.TimeoutSeconds,
+		Labels:         t.Labels,
+		Params:         []paramView{},
+		Owner:          t.Owner,
+	}
+	if v.Env == nil {
+		v.Env = map[string]string{}
+	}
+	if v.Labels == nil {
+		v.Labels = map[string]string{}
+	}
+	for _, p := range t.Params {
+		v.Params = append(v.Params, paramView{Name: p.Name, Default: p.Default, Required: p.Required, Description: p.Description})
+	}
+	return v
+}
+
+// name for required parameters, name=default for the others
+func formatParams(params []paramView) string {
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		if p.Required {
+			parts = append(parts, p.Name)
+		} else {
+			parts = append(parts, p.Name+"="+p.Default)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// key=value pairs sorted by key, for tables
+func formatLabels(pairs map[string]string) string {
+	list := make([]string, 0, len(pairs))
+	for key, value := range pairs {
+		list = append(list, key+"="+value)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
+
+// check --output and compile a template, called before any command runs
+func parseOutputFormat() error {
+	switch outputFormat {
+	case "table", "json", "yaml":
+		return nil
+	}
+	if text, ok := strings.CutPrefix(outputFormat, "template="); ok {
+		tmpl, err := template.New("output").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid output template: %w", err)
+		}
+		outputTemplate = tmpl
+		return nil
+	}
+	return fmt.Errorf("invalid output format %q, expected table, json, yaml or template=<go template>", outputFormat)
+}
+
+func printJobs(cmd *cobra.Command, statuses []*pb.JobStatus) error {
+	views := make([]jobView, 0, len(statuses))
+	for _, js := range statuses {
+		views = append(views, newJobView(js))
+	}
+	// the server returns jobs in no particular order, sort for a stable listing
+	sort.Slice(views, func(i, j int) bool { return views[i].ID < views[j].ID })
+	items := make([]any, len(views))
+	for i := range views {
+		items[i] = views[i]
+	}
+	return writeOutput(cmd.OutOrStdout(), views, items, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tSTATE\tEXIT\tUSER\tLABELS\tCOMMAND\tERROR")
+		for _, v := range views {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", v.ID, v.State, v.ExitCode, v.User, formatLabels(v.Labels), v.Command, v.Error)
+		}
+	})
+}
+
+func printJob(cmd *cobra.Command, js *pb.JobStatus) error {
+	v := newJobView(js)
+	if js.StartedAt != nil {
+		t := js.StartedAt.AsTime()
+		v.StartedAt = &t
+	}
+	if js.FinishedAt != nil {
+		t := js.FinishedAt.AsTime()
+		v.FinishedAt = &t
+	}
+	for _, d := range js.WebhookDeliveries {
+		v.Webhooks = append(v.Webhooks, deliveryView{URL: d.Url, Attempt: d.Attempt, Time: d.Time.AsTime(), StatusCode: d.StatusCode, Error: d.Error})
+	}
+	return writeOutput(cmd.OutOrStdout(), v, []any{v}, func(w io.Writer) {
+		fmt.Fprintf(w, "ID:\t%s\n", v.ID)
+		fmt.Fprintf(w, "Command:\t%s\n", v.Command)
+		fmt.Fprintf(w, "User:\t%s\n", v.User)
+		fmt.Fprintf(w, "State:\t%s\n", v.State)
+		fmt.Fprintf(w, "Labels:\t%s\n", formatLabels(v.Labels))
+		if v.Worker != "" {
+			fmt.Fprintf(w, "Worker:\t%s\n", v.Worker)
+		}
+		if v.Restarts > 0 {
+			fmt.Fprintf(w, "Restarts:\t%d\n", v.Restarts)
+		}
+		if v.StartedAt != nil {
+			fmt.Fprintf(w, "Started:\t%s\n", v.StartedAt.Format(time.RFC3339))
+		}
+		if v.FinishedAt != nil {
+			fmt.Fprintf(w, "Finished:\t%s\n", v.FinishedAt.Format(time.RFC3339))
+		}
+		fmt.Fprintf(w, "Exit code:\t%d\n", v.ExitCode)
+		fmt.Fprintf(w, "Error:\t%s\n", v.Error)
+		if v.Truncated {
+			fmt.Fprintf(w, "Output:\ttruncated at the output limit\n")
+		}
+		for _, d := range v.Webhooks {
+			result := fmt.Sprint(d.StatusCode)
+			if d.Error != "" {
+				result = d.Error
+			}
+			fmt.Fprintf(w, "Webhook:\t%s attempt %d at %s: %s\n", d.URL, d.Attempt, d.Time.Format(time.RFC3339), result)
+		}
+	})
+}
+
+func printTemplates(cmd *cobra.Command, list []*pb.JobTemplate) error {
+	views := make([]templateView, 0, len(list))
+	items := make([]any, 0, len(list))
+	for _, t := range list {
+		v := newTemplateView(t)
+		views = append(views, v)
+		items = append(items, v)
+	}
+	return writeOutput(cmd.OutOrStdout(), views, items, func(w io.Writer) {
+		fmt.Fprintln(w, "NAME\tOWNER\tPARAMS\tLABELS\tCOMMAND")
+		for _, v := range views {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", v.Name, v.Owner, formatParams(v.Params), formatLabels(v.Labels), v.Command)
+		}
+	})
+}
+
+func printTemplate(cmd *cobra.Command, t *pb.JobTemplate) error {
+	v := newTemplateView(t)
+	return writeOutput(cmd.OutOrStdout(), v, []any{v}, func(w io.Writer) {
+		fmt.Fprintf(w, "Name:\t%s\n", v.Name)
+		fmt.Fprintf(w, "Owner:\t%s\n", v.Owner)
+		fmt.Fprintf(w, "Command:\t%s\n", v.Command)
+		fmt.Fprintf(w, "Env:\t%s\n", formatLabels(v.Env))
+		fmt.Fprintf(w, "Workdir:\t%s\n", v.Workdir)
+		fmt.Fprintf(w, "Timeout:\t%ds\n", v.TimeoutSeconds)
+		fmt.Fprintf(w, "Labels:\t%s\n", formatLabels(v.Labels))
+		for _, p := range v.Params {
+			desc := ""
+			if p.Description != "" {
+				desc = "  # " + p.Description
+			}
+			if p.Required {
+				fmt.Fprintf(w, "Param:\t%s (required)%s\n", p.Name, desc)
+			} else {
+				fmt.Fprintf(w, "Param:\t%s=%s%s\n", p.Name, p.Default, desc)
+			}
+		}
+	})
+}
+
+func printArtifacts(cmd *cobra.Command, list []*pb.Artifact) error {
+	views := make([]artifactView, 0, len(list))
+	items := make([]any, 0, len(list))
+	for _, a := range list {
+		v := artifactView{Path: a.Path, Size: a.Size}
+		views = append(views, v)
+		items = append(items, v)
+	}
+	return writeOutput(cmd.OutOrStdout(), views, items, func(w io.Writer) {
+		fmt.Fprintln(w, "PATH\tSIZE")
+		for _, v := range views {
+			fmt.Fprintf(w, "%s\t%d\n", v.Path, v.Size)
+		}
+	})
+}
+
+func printSecrets(cmd *cobra.Command, list []*pb.SecretInfo) error {
+	views := make([]secretView, 0, len(list))
+	items := make([]any, 0, len(list))
+	for _, info := range list {
+		v := secretView{Name: info.Name, Owner: info.Owner, Created: info.Created.AsTime(), Updated: info.Updated.AsTime()}
+		views = append(views, v)
+		items = append(items, v)
+	}
+	return writeOutput(cmd.OutOrStdout(), views, items, func(w io.Writer) {
+		fmt.Fprintln(w, "NAME\tOWNER\tCREATED\tUPDATED")
+		for _, v := range views {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Name, v.Owner, v.Created.Format(time.RFC3339), v.Updated.Format(time.RFC3339))
+		}
+	})
+}
+
+func printWorkers(cmd *cobra.Command, list []*pb.WorkerStatus) error {
+	views := make([]workerView, 0, len(list))
+	items := make([]any, 0, len(list))
+	for _, ws := range list {
+		v := workerView{
+			ID:            ws.Worker.Id,
+			Address:       ws.Worker.Address,
+			Labels:        ws.Worker.Labels,
+			Capacity:      ws.Worker.Capacity,
+			Active:        ws.Worker.Active,
+			Alive:         ws.Alive,
+			LastHeartbeat: ws.LastHeartbeat.AsTime(),
+		}
+		if v.Labels == nil {
+			v.Labels = map[string]string{}
+		}
+		views = append(views, v)
+		items = append(items, v)
+	}
+	return writeOutput(cmd.OutOrStdout(), views, items, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tADDRESS\tALIVE\tACTIVE\tCAPACITY\tLABELS\tLAST HEARTBEAT")
+		for _, v := range views {
+			capacity := "-"
+			if v.Capacity > 0 {
+				capacity = fmt.Sprint(v.Capacity)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%t\t%d\t%s\t%s\t%s\n", v.ID, v.Address, v.Alive, v.Active, capacity, formatLabels(v.Labels), v.LastHeartbeat.Format(time.RFC3339))
+		}
+	})
+}
+
+// one change reported by watch
+type watchEventView struct {
+	Type string  `json:"type" yaml:"type"`
+	Job  jobView `json:"job" yaml:"job"`
+}
+
+// print an event as soon as it arrives: json as one object per line, yaml as
+// one document each, tables as fixed width rows since they cannot be aligned afterwards
+func printWatchEvent(cmd *cobra.Command, event *pb.WatchEvent, header bool) error {
+	v := watchEventView{Type: event.Type, Job: newJobView(event.JobStatus)}
+	out := cmd.OutOrStdout()
+	switch {
+	case outputFormat == "json":
+		return json.NewEncoder(out).Encode(v)
+	case outputFormat == "yaml":
+		fmt.Fprintln(out, "---")
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		defer enc.Close()
+		return enc.Encode(v)
+	case outputTemplate != nil:
+		if err := outputTemplate.Execute(out, v); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+		return nil
+	}
+	const row = "%-8s  %-36s  %-8s  %4s  %s\n"
+	if header {
+		fmt.Fprintf(out, row, "EVENT", "ID", "STATE", "EXIT", "LABELS")
+	}
+	exit := ""
+	if v.Job.State == "finished" {
+		exit = fmt.Sprint(v.Job.ExitCode)
+	}
+	fmt.Fprintf(out, row, v.Type, v.Job.ID, v.Job.State, exit, formatLabels(v.Job.Labels))
+	return nil
+}
+
+func printStatus(cmd *cobra.Command, st *pb.ServerStatus) error {
+	v := statusView{
+		Version:       st.Version,
+		UptimeSeconds: st.UptimeSeconds,
+		Draining:      st.Draining,
+		QueueDepth:    st.QueueDepth,
+		JobsByState:   st.JobsByState,
+	}
+	if v.JobsByState == nil {
+		v.JobsByState = map[string]int32{}
+	}
+	return writeOutput(cmd.OutOrStdout(), v, []any{v}, func(w io.Writer) {
+		fmt.Fprintf(w, "Version:\t%s\n", v.Version)
+		fmt.Fprintf(w, "Uptime:\t%ds\n", v.UptimeSeconds)
+		fmt.Fprintf(w, "Draining:\t%t\n", v.Draining)
+		fmt.Fprintf(w, "Queue depth:\t%d\n", v.QueueDepth)
+		states := make([]string, 0, len(v.JobsByState))
+		for state := range v.JobsByState {
+			states = append(states, state)
+		}
+		sort.Strings(states)
+		for _, state := range states {
+			fmt.Fprintf(w, "Jobs %s:\t%d\n", state, v.JobsByState[state])
+		}
+	})
+}
+
+// write value in the selected format; templates are applied to each of items
+// and table draws the human readable form into an aligned tabwriter
+func writeOutput(out io.Writer, value any, items []any, table func(w io.Writer)) error {
+	switch {
+	case outputFormat == "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(value)
+	case outputFormat == "yaml":
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		defer enc.Close()
+		return enc.Encode(value)
+	case outputTemplate != nil:
+		for _, item := range items {
+			if err := outputTemplate.Execute(out, item); err != nil {
+				return err
+			}
+			fmt.Fprintln(out)
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+func printQuotas(cmd *cobra.Command, list []*pb.QuotaUsage) error {
+	views := make([]quotaView, 0, len(list))
+	items := make([]any, 0, len(list))
+	for _, q := range list {
+		v := quotaView{
+			User:        q.User,
+			Group:       q.Group,
+			Running:     q.Running,
+			MaxRunning:  q.Limits.GetMaxRunning(),
+			Queued:      q.Queued,
+			MaxQueued:   q.Limits.GetMaxQueued(),
+			CPUSeconds:  q.CpuSeconds,
+			MaxCPU:      q.Limits.GetCpuSecondsPerDay(),
+			OutputBytes: q.OutputBytes,
+			MaxOutput:   q.Limits.GetOutputBytes(),
+		}
+		views = append(views, v)
+		items = append(items, v)
+	}
+	// used/limit, a limit of 0 is shown as -
+	used := func(n any, limit int64) string {
+		if limit == 0 {
+			return fmt.Sprintf("%v/-", n)
+		}
+		return fmt.Sprintf("%v/%d", n, limit)
+	}
+	return writeOutput(cmd.OutOrStdout(), views, items, func(w io.Writer) {
+		fmt.Fprintln(w, "SCOPE\tRUNNING\tQUEUED\tCPU SECONDS (24H)\tOUTPUT BYTES")
+		for _, v := range views {
+			scope := "user " + v.User
+			if v.Group != "" {
+				scope = "group " + v.Group
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", scope, used(v.Running, int64(v.MaxRunning)), used(v.Queued, int64(v.MaxQueued)),
+				used(fmt.Sprintf("%.1f", v.CPUSeconds), v.MaxCPU), used(v.OutputBytes, v.MaxOutput))
+		}
+	})
+}
+
+func printMatches(cmd *cobra.Command, res *pb.SearchResponse) error {
+	views := make([]matchView, 0, len(res.Matches))
+	items := make([]any, 0, len(res.Matches))
+	for _, m := range res.Matches {
+		v := matchView{ID: m.Id, Line: m.Line, Stream: m.Stream, Time: m.Time.AsTime(), Text: string(m.Text)}
+		views = append(views, v)
+		items = append(items, v)
+	}
+	if res.Truncated {
+		fmt.Fprintf(cmd.ErrOrStderr(), "more lines matched than shown, raise --max or narrow the search\n")
+	}
+	return writeOutput(cmd.OutOrStdout(), views, items, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tLINE\tSTREAM\tTIME\tTEXT")
+		for _, v := range views {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", v.ID, v.Line, v.Stream, v.Time.Local().Format(time.RFC3339), v.Text)
+		}
+	})
+}
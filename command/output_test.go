@@ -0,0 +1,63 @@
+package command
+
+import (
+	"encoding/json"
+	pb "main/proto"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestListOutputFormats(t *testing.T) {
+	f := &fakeServer{list: func() (*pb.JobStatusList, error) {
+		// the server's order is not the printed one
+		return &pb.JobStatusList{JobStatusList: []*pb.JobStatus{
+			{Job: &pb.Job{ID: "b", Cmd: "false", State: "finished", Labels: map[string]string{"team": "x", "env": "ci"}}, ExitCode: 1, ErrorMessage: "exit status 1"},
+			{Job: &pb.Job{ID: "a", Cmd: "sleep 9", User: "alice", State: "running"}},
+		}}, nil
+	}}
+	addr := serveFake(t, f)
+
+	stdout, stderr, code := runCLI(t, addr, "list")
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if code != ExitOK || len(lines) != 3 || !strings.HasPrefix(lines[0], "ID ") || !strings.HasPrefix(lines[1], "a ") ||
+		!strings.Contains(lines[2], "env=ci,team=x") {
+		t.Errorf("table = %q, %q, exit %d", stdout, stderr, code)
+	}
+
+	stdout, _, code = runCLI(t, addr, "-o", "json", "list")
+	var jobs []map[string]any
+	if err := json.Unmarshal([]byte(stdout), &jobs); err != nil || code != ExitOK {
+		t.Fatalf("json = %q, %v, exit %d", stdout, err, code)
+	}
+	// the field names scripts rely on
+	if len(jobs) != 2 || jobs[0]["id"] != "a" || jobs[1]["exit_code"] != 1.0 || jobs[1]["error"] != "exit status 1" || jobs[0]["labels"] == nil {
+		t.Errorf("json = %v", jobs)
+	}
+
+	stdout, _, code = runCLI(t, addr, "-o", "yaml", "list")
+	var docs []map[string]any
+	if err := yaml.Unmarshal([]byte(stdout), &docs); err != nil || code != ExitOK || len(docs) != 2 || docs[1]["command"] != "false" {
+		t.Errorf("yaml = %q, %v, exit %d", stdout, err, code)
+	}
+
+	stdout, _, code = runCLI(t, addr, "-o", "template={{.ID}}:{{.State}}:{{index .Labels \"team\"}}", "list")
+	if code != ExitOK || stdout != "a:running:\nb:finished:x\n" {
+		t.Errorf("template = %q, exit %d", stdout, code)
+	}
+
+	tests := []struct {
+		format string
+		code   int
+	}{
+		{format: "xml", code: ExitUsage},
+		{format: "template={{.ID", code: ExitUsage},
+		{format: "template={{.Missing}}", code: ExitFailure},
+	}
+	for _, tt := range tests {
+		if _, stderr, code := runCLI(t, addr, "-o", tt.format, "list"); code != tt.code || stderr == "" {
+			t.Errorf("-o %s: exit %d with %q, want %d and an error", tt.format, code, stderr, tt.code)
+		}
+	}
+}
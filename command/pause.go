@@ -0,0 +1,45 @@
+package command
+
+import (
+	pb "main/proto"
+
+	"github.com/spf13/cobra"
+)
+
+func PauseCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "pause <job-id>",
+		Short: "Freeze a running job, its timeout stops counting until it is resumed",
+		Args:  usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			_, err = client.Pause(ctx, &pb.JobID{Id: args[0]})
+			return err
+		},
+	}
+}
+
+func ResumeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "resume <job-id>",
+		Short: "Resume a paused job",
+		Args:  usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			_, err = client.Resume(ctx, &pb.JobID{Id: args[0]})
+			return err
+		},
+	}
+}
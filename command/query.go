@@ -1,20 +1,29 @@
 package command
 
 import (
-	"main/core"
+	pb "main/proto"
 
 	"github.com/spf13/cobra"
 )
 
 func QueryCommand() *cobra.Command {
 	return &cobra.Command{
-		Use:   "query",
+		Use:   "query <job-id>",
 		Short: "Query a job",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			jd := core.JobDispatcher{}
-			res := jd.StopJob(args[0])
-			println(res)
+		Args:  usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			jobStatus, err := client.Query(ctx, &pb.JobID{Id: args[0]})
+			if err != nil {
+				return err
+			}
+			return printJob(cmd, jobStatus)
 		},
 	}
 }
@@ -0,0 +1,33 @@
+package command
+
+import (
+	pb "main/proto"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func QuotaCommand() *cobra.Command {
+	var user string
+	cmd := &cobra.Command{
+		Use:   "quota",
+		Short: "Show your job quotas and what your jobs use",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			res, err := client.Quota(ctx, &pb.QuotaRequest{User: user})
+			if err != nil {
+				return err
+			}
+			return printQuotas(cmd, res.Quotas)
+		},
+	}
+	cmd.Flags().StringVar(&user, "user", os.Getenv("USER"), "user to show, ignored when the server identifies the caller")
+	return cmd
+}
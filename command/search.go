@@ -0,0 +1,35 @@
+package command
+
+import (
+	pb "main/proto"
+
+	"github.com/spf13/cobra"
+)
+
+func SearchCommand() *cobra.Command {
+	var jobId, selector string
+	var maxMatches int32
+	cmd := &cobra.Command{
+		Use:   "search <pattern>",
+		Short: "Find output lines matching a regular expression across jobs",
+		Args:  usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			res, err := client.Search(ctx, &pb.SearchRequest{Pattern: args[0], Id: jobId, Selector: selector, MaxMatches: maxMatches})
+			if err != nil {
+				return err
+			}
+			return printMatches(cmd, res)
+		},
+	}
+	cmd.Flags().StringVarP(&jobId, "job", "j", "", "search only this job")
+	cmd.Flags().StringVarP(&selector, "selector", "l", "", "search only jobs whose labels match, e.g. 'team=infra'")
+	cmd.Flags().Int32VarP(&maxMatches, "max", "m", 0, "most matching lines to return, 0 means the server's default of 100")
+	return cmd
+}
@@ -0,0 +1,113 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	pb "main/proto"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+func SecretCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secret",
+		Short: "Manage secrets that jobs receive as environment variables or files",
+		Long: "Manage the server's secret store. Values can be written but never read back; jobs " +
+			"reference secrets by name with 'start --secret-env' or '--secret-file'.",
+	}
+	cmd.AddCommand(secretPutCommand())
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List secret names",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			res, err := client.ListSecrets(ctx, &pb.NilMessage{})
+			if err != nil {
+				return err
+			}
+			return printSecrets(cmd, res.Secrets)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a secret",
+		Args:  usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			_, err = client.DeleteSecret(ctx, &pb.SecretName{Name: args[0]})
+			return err
+		},
+	})
+	return cmd
+}
+
+func secretPutCommand() *cobra.Command {
+	var fromFile string
+	cmd := &cobra.Command{
+		Use:   "put <name>",
+		Short: "Create or replace a secret, reading the value from stdin or --from-file",
+		// the value is never an argument, it would end up in the shell history and the process list
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var value []byte
+			var err error
+			if fromFile != "" {
+				value, err = os.ReadFile(fromFile)
+			} else {
+				value, err = io.ReadAll(cmd.InOrStdin())
+			}
+			if err != nil {
+				return err
+			}
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			info, err := client.PutSecret(ctx, &pb.Secret{Name: args[0], Value: value})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), info.Name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&fromFile, "from-file", "f", "", "read the value from this file instead of stdin")
+	return cmd
+}
+
+// --secret-env and --secret-file, shared by start and template
+func addSecretFlags(cmd *cobra.Command, envs, files *map[string]string) {
+	cmd.Flags().StringToStringVar(envs, "secret-env", nil, "give the job a secret as an environment variable, VAR=secret-name, repeatable")
+	cmd.Flags().StringToStringVar(files, "secret-file", nil, "give the job a secret as the file $JOB_SECRETS_DIR/<file>, file=secret-name, repeatable")
+}
+
+func secretRefs(envs, files map[string]string) []*pb.SecretRef {
+	var refs []*pb.SecretRef
+	for env, name := range envs {
+		refs = append(refs, &pb.SecretRef{Name: name, Env: env})
+	}
+	for file, name := range files {
+		refs = append(refs, &pb.SecretRef{Name: name, File: file})
+	}
+	// flags come back as maps, keep the request stable
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Env+"/"+refs[i].File < refs[j].Env+"/"+refs[j].File })
+	return refs
+}
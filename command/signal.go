@@ -0,0 +1,31 @@
+package command
+
+import (
+	pb "main/proto"
+
+	"github.com/spf13/cobra"
+)
+
+func SignalCommand() *cobra.Command {
+	var group bool
+	cmd := &cobra.Command{
+		Use:   "signal <job-id> <signal>",
+		Short: "Send a signal such as HUP or USR1 to a running job",
+		Long: "Send a signal to a running job's process, or with --group to every process in its process group. " +
+			"The server only delivers signals from its allowlist.",
+		Args: usageArgs(cobra.ExactArgs(2)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			_, err = client.Signal(ctx, &pb.SignalRequest{Id: args[0], Signal: args[1], Group: group})
+			return err
+		},
+	}
+	cmd.Flags().BoolVarP(&group, "group", "g", false, "signal the job's whole process group")
+	return cmd
+}
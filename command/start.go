@@ -1,34 +1,110 @@
 package command
 
 import (
+	"errors"
+	"fmt"
 	"main/core"
+	pb "main/proto"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 func StartCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:   "start",
+	var labels, params, secretEnvs, secretFiles, env map[string]string
+	var template, nodeSelector, restartPolicy, workdir string
+	var jobTimeout time.Duration
+	var maxRestarts int32
+	var artifacts []string
+	var webhooks []string
+	var outputLimit int64
+	var outputPolicy string
+	cmd := &cobra.Command{
+		Use:   "start [flags] -- command [args...] | start --template <name> [--param name=value...]",
 		Short: "Start a job",
-		Args:  cobra.MinimumNArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			// get the string of the command to run as a string
-			cmdStr := strings.Join(args, " ")
-			jd := core.JobDispatcher{}
-			jd.Init()
-			job := core.Job{
-				ID: "1",
-				// ID:    fmt.Sprintf("%d", time.Now().Unix()),
-				Cmd:   cmdStr,
-				User:  os.Getenv("USER"),
-				State: core.Created,
+		Long: "Start a job. A single argument is run as a shell command line, e.g. start -- 'make && make test';\n" +
+			"with several each argument is passed to the command as it is.",
+		Args: usageArgs(func(cmd *cobra.Command, args []string) error {
+			if template != "" {
+				if len(artifacts) > 0 || len(secretEnvs) > 0 || len(secretFiles) > 0 || len(env) > 0 || workdir != "" || jobTimeout != 0 {
+					return errors.New("--artifact, --secret-*, --env, --workdir and --job-timeout cannot be combined with --template, declare them in the template")
+				}
+				return cobra.NoArgs(cmd, args)
 			}
-			res := jd.StartJob(job)
-			if res != "" {
-				println(res)
+			if len(params) > 0 {
+				return errors.New("--param needs --template")
 			}
+			if jobTimeout < 0 || jobTimeout%time.Second != 0 {
+				return errors.New("--job-timeout must be whole seconds and not negative")
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		}),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			var job *pb.Job
+			if template != "" {
+				job, err = client.StartTemplate(ctx, &pb.StartTemplateRequest{Name: template, Params: params, Labels: labels,
+					NodeSelector: nodeSelector, RestartPolicy: restartPolicy, MaxRestarts: maxRestarts, Webhooks: webhooks})
+			} else {
+				job, err = client.Start(ctx, &pb.Job{
+					Cmd:            commandLine(args),
+					User:           os.Getenv("USER"),
+					Labels:         labels,
+					Env:            env,
+					Workdir:        workdir,
+					TimeoutSeconds: int32(jobTimeout / time.Second),
+					Artifacts:      artifacts,
+					Secrets:        secretRefs(secretEnvs, secretFiles),
+					NodeSelector:   nodeSelector,
+					RestartPolicy:  restartPolicy,
+					MaxRestarts:    maxRestarts,
+					Webhooks:       webhooks,
+					OutputLimit:    outputLimit,
+					OutputPolicy:   outputPolicy,
+				})
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), job.ID)
+			return nil
 		},
 	}
+	cmd.Flags().StringVarP(&template, "template", "t", "", "start the job from this template instead of a command")
+	cmd.Flags().StringToStringVarP(&params, "param", "p", nil, "template parameter, name=value, repeatable")
+	cmd.Flags().StringArrayVarP(&artifacts, "artifact", "a", nil, "collect files matching this glob from the working directory when the job finishes, ** matches any directories, repeatable")
+	addSecretFlags(cmd, &secretEnvs, &secretFiles)
+	cmd.Flags().StringToStringVarP(&labels, "label", "l", nil, "label the job, key=value, repeatable")
+	cmd.Flags().StringToStringVarP(&env, "env", "e", nil, "environment variable of the job on top of the server's, NAME=value, repeatable")
+	cmd.Flags().StringVarP(&workdir, "workdir", "w", "", "directory the job runs in, default the server's; needs to be absolute with --artifact")
+	cmd.Flags().DurationVar(&jobTimeout, "job-timeout", 0, "stop the job after this long, whole seconds, 0 means the server's default")
+	cmd.Flags().StringVarP(&nodeSelector, "node-selector", "n", "", "with a coordinator: label selector choosing the workers the job may run on")
+	cmd.Flags().StringVar(&restartPolicy, "restart", "", "with a coordinator: never, or on-lost to start the job again on another worker when its worker dies")
+	cmd.Flags().Int32Var(&maxRestarts, "max-restarts", 0, "with --restart on-lost: how often the job is started again, 0 means 3")
+	cmd.Flags().Int64Var(&outputLimit, "output-limit", 0, "bytes of output the server keeps, 0 means the server's limit")
+	cmd.Flags().StringVar(&outputPolicy, "output-policy", "", "when the output limit is reached: head (discard further output), tail (drop the oldest lines) or kill")
+	cmd.Flags().StringArrayVar(&webhooks, "webhook", nil, "http(s) URL the server posts the final status to when the job ends, repeatable")
+	// everything after the command belongs to the job
+	cmd.Flags().SetInterspersed(false)
+	return cmd
+}
+
+// the sh command line running args, a single argument already is one
+func commandLine(args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = core.ShellQuote(arg)
+	}
+	return strings.Join(words, " ")
 }
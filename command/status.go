@@ -0,0 +1,29 @@
+package command
+
+import (
+	pb "main/proto"
+
+	"github.com/spf13/cobra"
+)
+
+func StatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the state of the server",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			st, err := client.Status(ctx, &pb.NilMessage{})
+			if err != nil {
+				return err
+			}
+			return printStatus(cmd, st)
+		},
+	}
+}
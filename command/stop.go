@@ -1,20 +1,45 @@
 package command
 
 import (
-	"main/core"
+	"errors"
+	pb "main/proto"
 
 	"github.com/spf13/cobra"
 )
 
 func StopCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:   "stop",
-		Short: "Stop a running job",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			jd := core.JobDispatcher{}
-			res := jd.StopJob(args[0])
-			println(res)
+	var selector string
+	cmd := &cobra.Command{
+		Use:   "stop <job-id> | --selector <selector>",
+		Short: "Stop a running job, or every running job matching a label selector",
+		Args: usageArgs(func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("selector") {
+				if len(args) > 0 {
+					return errors.New("give either a job ID or --selector, not both")
+				}
+				return nil
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		}),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			if len(args) == 0 {
+				res, err := client.StopMatching(ctx, &pb.ListRequest{Selector: selector})
+				if err != nil {
+					return err
+				}
+				return printJobs(cmd, res.JobStatusList)
+			}
+			_, err = client.Stop(ctx, &pb.JobID{Id: args[0]})
+			return err
 		},
 	}
+	addSelectorFlag(cmd, &selector)
+	return cmd
 }
@@ -0,0 +1,23 @@
+package command
+
+import (
+	pb "main/proto"
+
+	"github.com/spf13/cobra"
+)
+
+func StreamCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stream <job-id>",
+		Short: "Print the output of a job until it finishes",
+		Args:  usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			return printOutput(cmd, client, &pb.OutputRequest{Id: args[0], Follow: true}, false)
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	pb "main/proto"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TemplateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "template",
+		Short: "Manage named job templates",
+		Long: "Manage job templates stored on the server. Placeholders like {{name}} in the command, " +
+			"environment values, working directory and label values are filled from parameters when a job " +
+			"is started with 'start --template'. In the command every value becomes a single shell word, " +
+			"so placeholders there must not be quoted.",
+	}
+	cmd.AddCommand(templateWriteCommand("create", "Create a template"))
+	cmd.AddCommand(templateWriteCommand("update", "Replace an existing template"))
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List templates",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			res, err := client.ListTemplates(ctx, &pb.NilMessage{})
+			if err != nil {
+				return err
+			}
+			return printTemplates(cmd, res.Templates)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <name>",
+		Short: "Show a template",
+		Args:  usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			t, err := client.GetTemplate(ctx, &pb.TemplateName{Name: args[0]})
+			if err != nil {
+				return err
+			}
+			return printTemplate(cmd, t)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a template",
+		Args:  usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			_, err = client.DeleteTemplate(ctx, &pb.TemplateName{Name: args[0]})
+			return err
+		},
+	})
+	return cmd
+}
+
+// create and update take the whole template
+func templateWriteCommand(use, short string) *cobra.Command {
+	var env, labels, secretEnvs, secretFiles map[string]string
+	var workdir string
+	var timeout time.Duration
+	var params, artifacts []string
+	cmd := &cobra.Command{
+		Use:   use + " [flags] <name> <command> [args...]",
+		Short: short,
+		Args:  usageArgs(cobra.MinimumNArgs(2)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := &pb.JobTemplate{
+				Name:           args[0],
+				Cmd:            strings.Join(args[1:], " "),
+				Env:            env,
+				Workdir:        workdir,
+				TimeoutSeconds: int32(timeout / time.Second),
+				Labels:         labels,
+				Artifacts:      artifacts,
+				Secrets:        secretRefs(secretEnvs, secretFiles),
+			}
+			for _, p := range params {
+				name, def, hasDefault := strings.Cut(p, "=")
+				if name == "" {
+					return usageError(errors.New("--param needs a name"))
+				}
+				t.Params = append(t.Params, &pb.TemplateParam{Name: name, Default: def, Required: !hasDefault})
+			}
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			if use == "create" {
+				_, err = client.CreateTemplate(ctx, t)
+			} else {
+				_, err = client.UpdateTemplate(ctx, t)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), t.Name)
+			return nil
+		},
+	}
+	cmd.Flags().StringToStringVarP(&env, "env", "e", nil, "environment variable for the job, NAME=value, repeatable")
+	cmd.Flags().StringToStringVarP(&labels, "label", "l", nil, "label the jobs, key=value, repeatable")
+	cmd.Flags().StringVar(&workdir, "workdir", "", "working directory of the jobs")
+	cmd.Flags().DurationVar(&timeout, "job-timeout", 0, "kill the jobs after this long, 0 uses the server default")
+	cmd.Flags().StringArrayVarP(&artifacts, "artifact", "a", nil, "collect files matching this glob from the working directory, repeatable")
+	addSecretFlags(cmd, &secretEnvs, &secretFiles)
+	cmd.Flags().StringArrayVarP(&params, "param", "p", nil, "declare a parameter, 'name' is required, 'name=default' is optional, repeatable")
+	// everything after the name belongs to the command
+	cmd.Flags().SetInterspersed(false)
+	return cmd
+}
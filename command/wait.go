@@ -0,0 +1,57 @@
+package command
+
+import (
+	"errors"
+	"main/core"
+	pb "main/proto"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func WaitCommand() *cobra.Command {
+	var anyJob bool
+	var wait time.Duration
+	cmd := &cobra.Command{
+		Use:   "wait <job-id>...",
+		Short: "Wait for jobs to finish",
+		Long: "Wait until every given job has finished, or the first one with --any, and print their status. " +
+			"wait exits with the exit code of the first finished job that failed, 0 if none did, " +
+			"and with 124 when --wait-timeout elapses first.",
+		Args: usageArgs(cobra.MinimumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &pb.WaitRequest{Ids: args, Mode: core.WaitAll, TimeoutSeconds: int32(wait.Seconds())}
+			if anyJob {
+				req.Mode = core.WaitAny
+			}
+			if wait > 0 && req.TimeoutSeconds == 0 {
+				return usageError(errors.New("--wait-timeout must be at least 1s"))
+			}
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			// the server enforces --wait-timeout, --timeout would cut every wait short
+			res, err := client.Wait(cmd.Context(), req)
+			if err != nil {
+				return err
+			}
+			if err := printJobs(cmd, res.JobStatusList); err != nil {
+				return err
+			}
+			if res.TimedOut {
+				return &ExitError{Code: ExitTimeout}
+			}
+			for _, js := range res.JobStatusList {
+				if js.Job.State == core.Finished && js.ExitCode != 0 {
+					return jobExitError(js.ExitCode)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&anyJob, "any", false, "return as soon as one of the jobs has finished")
+	cmd.Flags().DurationVar(&wait, "wait-timeout", 0, "give up after this long, 0 means no limit")
+	return cmd
+}
@@ -0,0 +1,49 @@
+package command
+
+import (
+	"errors"
+	"io"
+	pb "main/proto"
+
+	"github.com/spf13/cobra"
+)
+
+func WatchCommand() *cobra.Command {
+	var selector string
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Print the jobs matching a label selector and every change to them",
+		Long: "Print an added event for every job matching --selector, then an event each time one of them " +
+			"changes state or is deleted, until interrupted. With --output template the template is applied " +
+			"to each event, which has the fields Type and Job.",
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			stream, err := client.Watch(cmd.Context(), &pb.ListRequest{Selector: selector})
+			if err != nil {
+				return err
+			}
+			for header := true; ; header = false {
+				event, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					return nil
+				} else if err != nil {
+					// interrupted by the user, not a failure
+					if cmd.Context().Err() != nil {
+						return nil
+					}
+					return err
+				}
+				if err := printWatchEvent(cmd, event, header); err != nil {
+					return err
+				}
+			}
+		},
+	}
+	addSelectorFlag(cmd, &selector)
+	return cmd
+}
@@ -0,0 +1,29 @@
+package command
+
+import (
+	pb "main/proto"
+
+	"github.com/spf13/cobra"
+)
+
+func WorkersCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "workers",
+		Short: "List the workers registered with a coordinator",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, conn, err := dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := callContext(cmd)
+			defer cancel()
+			res, err := pb.NewCoordinatorClient(conn).ListWorkers(ctx, &pb.NilMessage{})
+			if err != nil {
+				return err
+			}
+			return printWorkers(cmd, res.Workers)
+		},
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+type ClientConfig struct {
+	Server  string        `yaml:"server" toml:"server"`   // address of the job server, host:port or unix:///path
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"` // deadline of unary calls
+	TLS     ClientTLS     `yaml:"tls" toml:"tls"`
+}
+
+// tls is enabled when CA is set, Cert and Key add a client certificate
+type ClientTLS struct {
+	CA         string `yaml:"ca" toml:"ca"`
+	Cert       string `yaml:"cert" toml:"cert"`
+	Key        string `yaml:"key" toml:"key"`
+	ServerName string `yaml:"server_name" toml:"server_name"`
+}
+
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		Server:  "localhost:8080",
+		Timeout: 10 * time.Second,
+	}
+}
+
+// register the client flags on fs, pointing into c
+// every flag can also be set with JOBCLIENT_<FLAG> or in the config file
+func (c *ClientConfig) RegisterFlags(fs *pflag.FlagSet) {
+	fs.String("config", "", "path of a yaml or toml config file")
+	fs.StringVar(&c.Server, "server", c.Server, "address of the job server, host:port or unix:///path/to/socket")
+	fs.DurationVar(&c.Timeout, "timeout", c.Timeout, "deadline of each request")
+	fs.StringVar(&c.TLS.CA, "tls-ca", c.TLS.CA, "CA file used to verify the server, enables tls")
+	fs.StringVar(&c.TLS.Cert, "tls-cert", c.TLS.Cert, "client certificate file for mutual tls")
+	fs.StringVar(&c.TLS.Key, "tls-key", c.TLS.Key, "client private key file")
+	fs.StringVar(&c.TLS.ServerName, "tls-server-name", c.TLS.ServerName, "name expected in the server certificate, defaults to the host of --server")
+}
+
+// complete c from the config file and environment once fs is parsed
+func (c *ClientConfig) Load(fs *pflag.FlagSet) error {
+	if err := LoadFlags(PFlags(fs), "JOBCLIENT", "config", c); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+func (c ClientConfig) Validate() error {
+	var errs []error
+	if c.Server == "" {
+		errs = append(errs, errors.New("server address must not be empty"))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("timeout must be positive, got %s", c.Timeout))
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		errs = append(errs, errors.New("tls cert and key must be set together"))
+	}
+	if c.IsUnix() && c.TLS.CA != "" {
+		errs = append(errs, errors.New("tls is not used on unix sockets"))
+	}
+	if c.TLS.Cert != "" && c.TLS.CA == "" {
+		errs = append(errs, errors.New("tls client certificate requires a CA"))
+	}
+	for _, f := range []string{c.TLS.CA, c.TLS.Cert, c.TLS.Key} {
+		if f == "" {
+			continue
+		}
+		if _, err := os.Stat(f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// the server is reached over a unix socket, where the server identifies us by our uid
+func (c ClientConfig) IsUnix() bool {
+	return strings.HasPrefix(c.Server, "unix:")
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// the parts of a parsed flag set that loading needs, so both the standard
+// flag package (server) and pflag (cobra cli) can be used
+type FlagSet interface {
+	Explicit() map[string]string // flags given on the command line and their values
+	Names() []string
+	Set(name, value string) error
+}
+
+// parse args with fs and fill cfg, see LoadFlags
+func Load(fs *flag.FlagSet, args []string, envPrefix, configFlag string, cfg any) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return LoadFlags(goFlags{fs}, envPrefix, configFlag, cfg)
+}
+
+// fill cfg from, lowest priority first: the defaults already in cfg, the config
+// file, PREFIX_NAME environment variables and finally the flags given on the
+// command line. The flags of fs must point into cfg; the file comes from the
+// flag named configFlag or the PREFIX_CONFIG environment variable.
+func LoadFlags(fs FlagSet, envPrefix, configFlag string, cfg any) error {
+	explicit := fs.Explicit()
+	path, ok := explicit[configFlag]
+	if !ok {
+		path = os.Getenv(envName(envPrefix, configFlag))
+	}
+	if path != "" {
+		if err := readFile(path, cfg); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range fs.Names() {
+		if _, ok := explicit[name]; ok {
+			continue
+		}
+		if v, ok := os.LookupEnv(envName(envPrefix, name)); ok {
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("invalid value %q for %s: %w", v, envName(envPrefix, name), err)
+			}
+		}
+	}
+	// the file overwrote the flag targets, put the command line values back
+	for name, v := range explicit {
+		if err := fs.Set(name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type goFlags struct {
+	*flag.FlagSet
+}
+
+func (fs goFlags) Explicit() map[string]string {
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+	return explicit
+}
+
+func (fs goFlags) Names() []string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	return names
+}
+
+type pFlags struct {
+	*pflag.FlagSet
+}
+
+// adapt a parsed pflag set for LoadFlags
+func PFlags(fs *pflag.FlagSet) FlagSet {
+	return pFlags{fs}
+}
+
+func (fs pFlags) Explicit() map[string]string {
+	explicit := make(map[string]string)
+	fs.Visit(func(f *pflag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+	return explicit
+}
+
+func (fs pFlags) Names() []string {
+	var names []string
+	fs.VisitAll(func(f *pflag.Flag) {
+		names = append(names, f.Name)
+	})
+	return names
+}
+
+// environment variable backing a flag, e.g. JOBSERVER_TLS_CERT for -tls-cert
+func envName(prefix, flagName string) string {
+	return prefix + "_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// decode a yaml or toml file into cfg depending on its extension
+func readFile(path string, cfg any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("config file %s: unknown format, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// dump the effective configuration as yaml, used by `config print`
+func Print(cfg any) error {
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(cfg)
+}
+
+// true when args is the `config print` command
+func IsPrintCommand(args []string) bool {
+	return len(args) == 2 && args[0] == "config" && args[1] == "print"
+}
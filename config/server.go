@@ -0,0 +1,413 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"main/core"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ServerConfig struct {
+	Listen      string          `yaml:"listen" toml:"listen"`             // tcp address, empty disables the tcp listener
+	UnixSocket  string          `yaml:"unix_socket" toml:"unix_socket"`   // callers on this socket are identified by SO_PEERCRED
+	HTTPListen  string          `yaml:"http_listen" toml:"http_listen"`   // json/sse gateway, empty disables it
+	MetricsAddr string          `yaml:"metrics_addr" toml:"metrics_addr"` // empty disables /metrics
+	DataDir     string          `yaml:"data_dir" toml:"data_dir"`         // server state, created on startup
+	Reflection  bool            `yaml:"reflection" toml:"reflection"`     // register the grpc reflection service
+	TLS         ServerTLS       `yaml:"tls" toml:"tls"`
+	Log         LogConfig       `yaml:"log" toml:"log"`
+	Shutdown    ShutdownConfig  `yaml:"shutdown" toml:"shutdown"`
+	Jobs        JobsConfig      `yaml:"jobs" toml:"jobs"`
+	Secrets     SecretsConfig   `yaml:"secrets" toml:"secrets"`
+	Cluster     ClusterConfig   `yaml:"cluster" toml:"cluster"`
+	Quotas      QuotasConfig    `yaml:"quotas" toml:"quotas"`
+	RateLimit   RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	Webhooks    WebhooksConfig  `yaml:"webhooks" toml:"webhooks"`
+}
+
+// tls is enabled when Cert and Key are set, ClientCA additionally requires client certificates
+type ServerTLS struct {
+	Cert     string `yaml:"cert" toml:"cert"`
+	Key      string `yaml:"key" toml:"key"`
+	ClientCA string `yaml:"client_ca" toml:"client_ca"`
+}
+
+type LogConfig struct {
+	Format string `yaml:"format" toml:"format"`
+	Level  string `yaml:"level" toml:"level"`
+	Output string `yaml:"output" toml:"output"`
+}
+
+type ShutdownConfig struct {
+	Policy  string        `yaml:"policy" toml:"policy"`
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+type JobsConfig struct {
+	MaxConcurrent  int           `yaml:"max_concurrent" toml:"max_concurrent"`
+	DefaultTimeout time.Duration `yaml:"default_timeout" toml:"default_timeout"`
+	AllowedSignals string        `yaml:"allowed_signals" toml:"allowed_signals"` // comma separated, e.g. HUP,USR1
+	CgroupRoot     string        `yaml:"cgroup_root" toml:"cgroup_root"`         // cgroup v2 directory for per-job cgroups, empty disables pause
+	OutputLimit    int64         `yaml:"output_limit" toml:"output_limit"`       // bytes of output kept per job, 0 means no limit
+	OutputPolicy   string        `yaml:"output_policy" toml:"output_policy"`     // head, tail or kill
+	RunAs          string        `yaml:"run_as" toml:"run_as"`                   // user running the jobs, empty means the server's
+	ArtifactBytes  int64         `yaml:"artifact_bytes" toml:"artifact_bytes"`   // collected per job, 0 means no limit
+	ArtifactFiles  int           `yaml:"artifact_files" toml:"artifact_files"`   // collected per job, 0 means no limit
+}
+
+// per-user and per-group job quotas, 0 means no limit
+type QuotaConfig struct {
+	MaxRunning       int   `yaml:"max_running" toml:"max_running"`
+	MaxQueued        int   `yaml:"max_queued" toml:"max_queued"`
+	CPUSecondsPerDay int64 `yaml:"cpu_seconds_per_day" toml:"cpu_seconds_per_day"`
+	OutputBytes      int64 `yaml:"output_bytes" toml:"output_bytes"`
+}
+
+// Default applies to users without an entry in Users, group quotas are shared by the group's members
+type QuotasConfig struct {
+	Default QuotaConfig            `yaml:"default" toml:"default"`
+	Users   map[string]QuotaConfig `yaml:"users" toml:"users"`
+	Groups  map[string]QuotaConfig `yaml:"groups" toml:"groups"`
+}
+
+// token bucket per caller and JobManager method, a Rate of 0 means no limit
+type RateLimit struct {
+	Rate  float64 `yaml:"rate" toml:"rate"`   // calls per second
+	Burst int     `yaml:"burst" toml:"burst"` // calls allowed at once, 0 means the rate rounded up
+}
+
+type RateLimitConfig struct {
+	Rate  float64 `yaml:"rate" toml:"rate"`
+	Burst int     `yaml:"burst" toml:"burst"`
+	// by method name, e.g. Query, replacing the limit above for that method
+	Methods map[string]RateLimit `yaml:"methods" toml:"methods"`
+}
+
+// delivery of job completion webhooks
+type WebhooksConfig struct {
+	KeyFile     string        `yaml:"key_file" toml:"key_file"`     // empty means webhook.key in the data directory
+	AllowNets   string        `yaml:"allow_nets" toml:"allow_nets"` // comma separated CIDRs of internal addresses webhooks may call
+	MaxAttempts int           `yaml:"max_attempts" toml:"max_attempts"`
+	Backoff     time.Duration `yaml:"backoff" toml:"backoff"` // before the first retry, doubled for each further one
+	Timeout     time.Duration `yaml:"timeout" toml:"timeout"`
+	OutputTail  int           `yaml:"output_tail" toml:"output_tail"` // lines of output in the payload
+}
+
+type SecretsConfig struct {
+	KeyFile string `yaml:"key_file" toml:"key_file"` // empty means secret.key in the data directory
+}
+
+// what a server does in a cluster
+const (
+	RoleStandalone  = "standalone"  // runs jobs itself
+	RoleCoordinator = "coordinator" // runs jobs on the workers registered with it
+	RoleWorker      = "worker"      // runs the jobs a coordinator sends it
+)
+
+type ClusterConfig struct {
+	Role              string        `yaml:"role" toml:"role"`
+	Coordinator       string        `yaml:"coordinator" toml:"coordinator"`               // worker: grpc address of the coordinator
+	CoordinatorName   string        `yaml:"coordinator_name" toml:"coordinator_name"`     // worker: common name of the coordinator's client certificate
+	Advertise         string        `yaml:"advertise" toml:"advertise"`                   // worker: address the coordinator calls, defaults to listen
+	WorkerID          string        `yaml:"worker_id" toml:"worker_id"`                   // worker: defaults to hostname:port
+	WorkerLabels      string        `yaml:"worker_labels" toml:"worker_labels"`           // worker: comma separated key=value pairs
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval" toml:"heartbeat_interval"` // coordinator: how often workers report
+	WorkerNames       string        `yaml:"worker_names" toml:"worker_names"`             // coordinator: comma separated common names of the workers' client certificates
+	TLS               ClientTLS     `yaml:"tls" toml:"tls"`                               // used for calls between coordinator and workers
+}
+
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Listen:      ":8080",
+		MetricsAddr: ":9090",
+		DataDir:     "data",
+		Log:         LogConfig{Format: "text", Level: "info", Output: "stderr"},
+		Shutdown:    ShutdownConfig{Policy: core.DrainWait, Timeout: 30 * time.Second},
+		Jobs:        JobsConfig{AllowedSignals: strings.Join(core.DefaultAllowedSignals, ","), OutputPolicy: core.OutputKeepHead, ArtifactBytes: 1 << 30, ArtifactFiles: 1000},
+		Cluster:     ClusterConfig{Role: RoleStandalone, HeartbeatInterval: 2 * time.Second},
+		Webhooks:    WebhooksConfig{MaxAttempts: 5, Backoff: time.Second, Timeout: 10 * time.Second, OutputTail: 20},
+	}
+}
+
+// parse the server command line, args excludes the program name
+// the remaining positional arguments are returned
+func LoadServerConfig(args []string) (ServerConfig, []string, error) {
+	cfg := DefaultServerConfig()
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.String("config", "", "path of a yaml or toml config file")
+	fs.StringVar(&cfg.Listen, "listen", cfg.Listen, "tcp address of the grpc listener, empty to disable")
+	fs.StringVar(&cfg.UnixSocket, "unix-socket", cfg.UnixSocket, "path of a unix socket to listen on, the caller's uid becomes the job user")
+	fs.StringVar(&cfg.HTTPListen, "http-listen", cfg.HTTPListen, "address of the http/json gateway, uses the same tls settings as -listen, empty to disable")
+	fs.StringVar(&cfg.MetricsAddr, "metrics-addr", cfg.MetricsAddr, "address of the prometheus /metrics endpoint, empty to disable")
+	fs.StringVar(&cfg.DataDir, "data-dir", cfg.DataDir, "directory for server state")
+	fs.BoolVar(&cfg.Reflection, "reflection", cfg.Reflection, "enable grpc server reflection, e.g. for grpcurl")
+	fs.StringVar(&cfg.TLS.Cert, "tls-cert", cfg.TLS.Cert, "server certificate file, enables tls together with -tls-key")
+	fs.StringVar(&cfg.TLS.Key, "tls-key", cfg.TLS.Key, "server private key file")
+	fs.StringVar(&cfg.TLS.ClientCA, "tls-client-ca", cfg.TLS.ClientCA, "CA file used to verify client certificates, enables mutual tls")
+	fs.StringVar(&cfg.Log.Format, "log-format", cfg.Log.Format, "log format: text or json")
+	fs.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "minimum log level: debug, info, warn or error")
+	fs.StringVar(&cfg.Log.Output, "log-output", cfg.Log.Output, "log destination: stderr, stdout or a file path")
+	fs.StringVar(&cfg.Shutdown.Policy, "shutdown-policy", cfg.Shutdown.Policy, "running jobs on SIGTERM/SIGINT: wait, stop or detach (left running, output no longer captured)")
+	fs.DurationVar(&cfg.Shutdown.Timeout, "shutdown-timeout", cfg.Shutdown.Timeout, "how long shutdown waits for jobs and in-flight rpcs")
+	fs.IntVar(&cfg.Jobs.MaxConcurrent, "max-concurrent-jobs", cfg.Jobs.MaxConcurrent, "jobs running at once, 0 means no limit")
+	fs.DurationVar(&cfg.Jobs.DefaultTimeout, "default-job-timeout", cfg.Jobs.DefaultTimeout, "kill jobs running longer than this, 0 means no limit")
+	fs.StringVar(&cfg.Jobs.AllowedSignals, "allowed-signals", cfg.Jobs.AllowedSignals, "comma separated signals callers may send to jobs, empty allows none")
+	fs.Int64Var(&cfg.Jobs.OutputLimit, "output-limit", cfg.Jobs.OutputLimit, "bytes of output kept per job, jobs may ask for less, 0 means no limit")
+	fs.StringVar(&cfg.Jobs.OutputPolicy, "output-policy", cfg.Jobs.OutputPolicy, "when a job reaches its output limit: head (discard further output), tail (drop the oldest lines) or kill")
+	fs.Int64Var(&cfg.Jobs.ArtifactBytes, "artifact-max-bytes", cfg.Jobs.ArtifactBytes, "bytes of artifacts collected per job, 0 means no limit")
+	fs.IntVar(&cfg.Jobs.ArtifactFiles, "artifact-max-files", cfg.Jobs.ArtifactFiles, "artifact files collected per job, 0 means no limit")
+	fs.StringVar(&cfg.Jobs.RunAs, "job-user", cfg.Jobs.RunAs, "run jobs as this user instead of the server's so they can't read -data-dir and its keys, needs root")
+	fs.StringVar(&cfg.Jobs.CgroupRoot, "cgroup-root", cfg.Jobs.CgroupRoot, "writable cgroup v2 directory to run each job in its own cgroup, needed to pause jobs")
+	fs.IntVar(&cfg.Quotas.Default.MaxRunning, "quota-max-running", cfg.Quotas.Default.MaxRunning, "running jobs per user, 0 means no limit")
+	fs.IntVar(&cfg.Quotas.Default.MaxQueued, "quota-max-queued", cfg.Quotas.Default.MaxQueued, "jobs per user waiting to run, 0 means no limit")
+	fs.Int64Var(&cfg.Quotas.Default.CPUSecondsPerDay, "quota-cpu-seconds", cfg.Quotas.Default.CPUSecondsPerDay, "CPU seconds per user used by jobs finished in the last 24 hours, 0 means no limit")
+	fs.Int64Var(&cfg.Quotas.Default.OutputBytes, "quota-output-bytes", cfg.Quotas.Default.OutputBytes, "bytes of output kept per user, 0 means no limit")
+	fs.Float64Var(&cfg.RateLimit.Rate, "rate-limit", cfg.RateLimit.Rate, "calls per second each caller may make to each rpc method, 0 means no limit")
+	fs.IntVar(&cfg.RateLimit.Burst, "rate-limit-burst", cfg.RateLimit.Burst, "calls each caller may make at once to each rpc method, 0 means -rate-limit rounded up")
+	fs.StringVar(&cfg.Webhooks.KeyFile, "webhook-key-file", cfg.Webhooks.KeyFile, "base64 key signing job webhooks, generated if missing, default webhook.key in -data-dir; keep it where jobs can't read it, see -job-user")
+	fs.StringVar(&cfg.Webhooks.AllowNets, "webhook-allow-nets", cfg.Webhooks.AllowNets, "comma separated CIDRs webhooks may call although they are loopback, private or link-local, e.g. 10.1.0.0/16")
+	fs.IntVar(&cfg.Webhooks.MaxAttempts, "webhook-max-attempts", cfg.Webhooks.MaxAttempts, "attempts to deliver a job webhook before giving up")
+	fs.DurationVar(&cfg.Webhooks.Backoff, "webhook-backoff", cfg.Webhooks.Backoff, "wait before retrying a failed webhook, doubled for every further retry")
+	fs.DurationVar(&cfg.Webhooks.Timeout, "webhook-timeout", cfg.Webhooks.Timeout, "timeout of a single webhook request")
+	fs.IntVar(&cfg.Webhooks.OutputTail, "webhook-output-tail", cfg.Webhooks.OutputTail, "last lines of job output sent to webhooks")
+	fs.StringVar(&cfg.Secrets.KeyFile, "secrets-key-file", cfg.Secrets.KeyFile, "key encrypting the secret store, generated if missing, default secret.key in -data-dir")
+	fs.StringVar(&cfg.Cluster.Role, "role", cfg.Cluster.Role, "standalone, coordinator (runs jobs on registered workers) or worker (runs jobs for -coordinator)")
+	fs.StringVar(&cfg.Cluster.Coordinator, "coordinator", cfg.Cluster.Coordinator, "worker: grpc address of the coordinator to register with")
+	fs.StringVar(&cfg.Cluster.CoordinatorName, "coordinator-name", cfg.Cluster.CoordinatorName, "worker: common name of the coordinator's -cluster-tls-cert, jobs are only taken from it; needs -tls-client-ca")
+	fs.StringVar(&cfg.Cluster.Advertise, "advertise-addr", cfg.Cluster.Advertise, "worker: address the coordinator reaches -listen at, an empty host means the address the worker connects from")
+	fs.StringVar(&cfg.Cluster.WorkerID, "worker-id", cfg.Cluster.WorkerID, "worker: unique name of this worker, default hostname:port")
+	fs.StringVar(&cfg.Cluster.WorkerNames, "worker-names", cfg.Cluster.WorkerNames, "coordinator: comma separated common names of the workers' -cluster-tls-cert, only they may register; needs -tls-client-ca")
+	fs.StringVar(&cfg.Cluster.WorkerLabels, "worker-labels", cfg.Cluster.WorkerLabels, "worker: comma separated key=value labels matched by the node selector of jobs")
+	fs.DurationVar(&cfg.Cluster.HeartbeatInterval, "heartbeat-interval", cfg.Cluster.HeartbeatInterval, "coordinator: how often workers report their load")
+	fs.StringVar(&cfg.Cluster.TLS.CA, "cluster-tls-ca", cfg.Cluster.TLS.CA, "CA file verifying the coordinator or workers, enables tls between them")
+	fs.StringVar(&cfg.Cluster.TLS.Cert, "cluster-tls-cert", cfg.Cluster.TLS.Cert, "client certificate presented to the coordinator or workers")
+	fs.StringVar(&cfg.Cluster.TLS.Key, "cluster-tls-key", cfg.Cluster.TLS.Key, "private key of -cluster-tls-cert")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: server [flags] [config print]\n\nEvery flag can also be set with JOBSERVER_<FLAG> (e.g. JOBSERVER_TLS_CERT) or in the config file.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := Load(fs, args, "JOBSERVER", "config", &cfg); err != nil {
+		return cfg, nil, err
+	}
+	return cfg, fs.Args(), cfg.Validate()
+}
+
+func (c ServerConfig) Validate() error {
+	var errs []error
+	if c.Listen == "" && c.UnixSocket == "" {
+		errs = append(errs, errors.New("at least one of listen address and unix socket must be set"))
+	}
+	if c.DataDir == "" {
+		errs = append(errs, errors.New("data directory must not be empty"))
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		errs = append(errs, errors.New("tls cert and key must be set together"))
+	}
+	if c.TLS.ClientCA != "" && c.TLS.Cert == "" {
+		errs = append(errs, errors.New("tls client CA requires a server cert and key"))
+	}
+	for _, f := range []string{c.TLS.Cert, c.TLS.Key, c.TLS.ClientCA} {
+		if f == "" {
+			continue
+		}
+		if _, err := os.Stat(f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("invalid log format %q, expected text or json", c.Log.Format))
+	}
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("invalid log level %q", c.Log.Level))
+	}
+	if err := core.ValidateDrainPolicy(c.Shutdown.Policy); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Shutdown.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown timeout must be positive, got %s", c.Shutdown.Timeout))
+	}
+	if err := c.Limits().Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.JobQuotas().Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	for name, limit := range c.RateLimits() {
+		if limit.Rate < 0 || limit.Burst < 0 {
+			if name == "" {
+				name = "every method"
+			}
+			errs = append(errs, fmt.Errorf("rate limit of %s must not be negative", name))
+		}
+	}
+	if c.Webhooks.MaxAttempts < 1 || c.Webhooks.Backoff <= 0 || c.Webhooks.Timeout <= 0 || c.Webhooks.OutputTail < 0 {
+		errs = append(errs, errors.New("webhook attempts, backoff and timeout must be positive and the output tail not negative"))
+	}
+	if _, err := c.WebhookAllowNets(); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.Cluster.Role {
+	case RoleStandalone:
+	case RoleCoordinator:
+		if c.Cluster.HeartbeatInterval <= 0 {
+			errs = append(errs, fmt.Errorf("heartbeat interval must be positive, got %s", c.Cluster.HeartbeatInterval))
+		}
+		if len(c.WorkerNames()) == 0 || c.TLS.ClientCA == "" {
+			errs = append(errs, errors.New("a coordinator checks the client certificates of workers, it needs the worker names and a tls client CA"))
+		}
+	case RoleWorker:
+		if c.Cluster.Coordinator == "" {
+			errs = append(errs, errors.New("a worker needs the coordinator address"))
+		}
+		if c.Listen == "" {
+			errs = append(errs, errors.New("a worker needs a tcp listen address for the coordinator"))
+		}
+		if c.Cluster.CoordinatorName != "" && c.TLS.ClientCA == "" {
+			errs = append(errs, errors.New("the coordinator name is checked against client certificates, it needs a tls client CA"))
+		}
+		if _, err := c.WorkerLabels(); err != nil {
+			errs = append(errs, err)
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid role %q, expected standalone, coordinator or worker", c.Cluster.Role))
+	}
+	if (c.Cluster.TLS.Cert == "") != (c.Cluster.TLS.Key == "") {
+		errs = append(errs, errors.New("cluster tls cert and key must be set together"))
+	}
+	return errors.Join(errs...)
+}
+
+// coordinator: common names of the client certificates workers register with
+func (c ServerConfig) WorkerNames() []string {
+	return splitList(c.Cluster.WorkerNames)
+}
+
+// the labels a worker registers with
+func (c ServerConfig) WorkerLabels() (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, item := range splitList(c.Cluster.WorkerLabels) {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("worker label %q must be key=value", item)
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return labels, core.ValidateLabels(labels)
+}
+
+// the worker's name and the address it asks the coordinator to call
+func (c ServerConfig) WorkerAddress() (id, address string, err error) {
+	address = c.Cluster.Advertise
+	if address == "" {
+		address = c.Listen
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", "", fmt.Errorf("advertised address %q: %w", address, err)
+	}
+	id = c.Cluster.WorkerID
+	if id == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return "", "", err
+		}
+		id = net.JoinHostPort(host, port)
+	}
+	return id, address, nil
+}
+
+// path of the secret store key
+func (c ServerConfig) SecretsKeyFile() string {
+	if c.Secrets.KeyFile != "" {
+		return c.Secrets.KeyFile
+	}
+	return filepath.Join(c.DataDir, "secret.key")
+}
+
+// path of the key signing webhooks
+func (c ServerConfig) WebhookKeyFile() string {
+	if c.Webhooks.KeyFile != "" {
+		return c.Webhooks.KeyFile
+	}
+	return filepath.Join(c.DataDir, "webhook.key")
+}
+
+// internal networks webhooks may call
+func (c ServerConfig) WebhookAllowNets() ([]netip.Prefix, error) {
+	var nets []netip.Prefix
+	for _, item := range splitList(c.Webhooks.AllowNets) {
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("webhook allowed network: %w", err)
+		}
+		nets = append(nets, prefix.Masked())
+	}
+	return nets, nil
+}
+
+// job limits for the dispatcher
+func (c ServerConfig) Limits() core.Limits {
+	return core.Limits{
+		MaxConcurrent:  c.Jobs.MaxConcurrent,
+		DefaultTimeout: c.Jobs.DefaultTimeout,
+		AllowedSignals: splitList(c.Jobs.AllowedSignals),
+		Output:         core.OutputLimit{Bytes: c.Jobs.OutputLimit, Policy: c.Jobs.OutputPolicy},
+		RunAs:          c.Jobs.RunAs,
+		Artifacts:      core.ArtifactLimit{Bytes: c.Jobs.ArtifactBytes, Files: c.Jobs.ArtifactFiles},
+	}
+}
+
+// job quotas for the dispatcher
+func (c ServerConfig) JobQuotas() core.Quotas {
+	q := core.Quotas{
+		Default: c.Quotas.Default.quota(),
+		Users:   make(map[string]core.Quota, len(c.Quotas.Users)),
+		Groups:  make(map[string]core.Quota, len(c.Quotas.Groups)),
+	}
+	for name, quota := range c.Quotas.Users {
+		q.Users[name] = quota.quota()
+	}
+	for name, quota := range c.Quotas.Groups {
+		q.Groups[name] = quota.quota()
+	}
+	return q
+}
+
+func (q QuotaConfig) quota() core.Quota {
+	return core.Quota{
+		MaxRunning:       q.MaxRunning,
+		MaxQueued:        q.MaxQueued,
+		CPUSecondsPerDay: q.CPUSecondsPerDay,
+		OutputBytes:      q.OutputBytes,
+	}
+}
+
+// rate limits by method name, the one for methods without their own under ""
+func (c ServerConfig) RateLimits() map[string]RateLimit {
+	limits := map[string]RateLimit{"": {Rate: c.RateLimit.Rate, Burst: c.RateLimit.Burst}}
+	for name, limit := range c.RateLimit.Methods {
+		limits[name] = limit
+	}
+	return limits
+}
+
+// items of a comma separated list, without blanks
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadServerConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, "server.yaml", `
+listen: ":7000"
+data_dir: /var/lib/from-file
+jobs:
+  max_concurrent: 3
+  default_timeout: 1m
+log:
+  level: debug
+`)
+	t.Setenv("JOBSERVER_DATA_DIR", "/var/lib/from-env")
+	t.Setenv("JOBSERVER_MAX_CONCURRENT_JOBS", "4")
+	cfg, args, err := LoadServerConfig([]string{"-config", path, "-max-concurrent-jobs", "5", "config", "print"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		what      string
+		got, want any
+	}{
+		{what: "default", got: cfg.MetricsAddr, want: ":9090"},
+		{what: "file", got: cfg.Listen, want: ":7000"},
+		{what: "file duration", got: cfg.Jobs.DefaultTimeout, want: time.Minute},
+		{what: "file over default", got: cfg.Log.Level, want: "debug"},
+		{what: "env over file", got: cfg.DataDir, want: "/var/lib/from-env"},
+		{what: "flag over env", got: cfg.Jobs.MaxConcurrent, want: 5},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.what, tt.got, tt.want)
+		}
+	}
+	if !IsPrintCommand(args) {
+		t.Errorf("args = %q, want config print", args)
+	}
+}
+
+func TestLoadServerConfigFiles(t *testing.T) {
+	tests := []struct {
+		name, content string
+		err           string
+	}{
+		{name: "server.toml", content: "listen = \":7000\"\n[jobs]\nmax_concurrent = 3\n"},
+		{name: "server.yml", content: "listen: \":7000\"\n"},
+		{name: "server.json", content: "{}", err: "unknown format"},
+		{name: "server.yaml", content: "listen: [", err: "config file"},
+		{name: "server.yaml", content: "jobs:\n  max_concurrent: -1\n", err: "max concurrent"},
+		{name: "server.yaml", content: "cluster:\n  role: coordinator\n", err: "worker names"},
+		{name: "server.yaml", content: "webhooks:\n  allow_nets: 10.0.0.0/33\n", err: "webhook allowed network"},
+	}
+	for _, tt := range tests {
+		path := writeConfig(t, tt.name, tt.content)
+		_, _, err := LoadServerConfig([]string{"-config", path})
+		if tt.err == "" && err != nil {
+			t.Errorf("%s %q: %v", tt.name, tt.content, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s %q: error %v, want one about %s", tt.name, tt.content, err, tt.err)
+		}
+	}
+	if _, _, err := LoadServerConfig([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("loaded a missing config file")
+	}
+}
+
+func TestServerConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*ServerConfig)
+		ok     bool
+	}{
+		{name: "defaults", change: func(*ServerConfig) {}, ok: true},
+		{name: "no listener", change: func(c *ServerConfig) { c.Listen = "" }},
+		{name: "unix socket only", change: func(c *ServerConfig) { c.Listen, c.UnixSocket = "", "/run/js.sock" }, ok: true},
+		{name: "cert without key", change: func(c *ServerConfig) { c.TLS.Cert = "cert.pem" }},
+		{name: "log format", change: func(c *ServerConfig) { c.Log.Format = "xml" }},
+		{name: "shutdown policy", change: func(c *ServerConfig) { c.Shutdown.Policy = "later" }},
+		{name: "role", change: func(c *ServerConfig) { c.Cluster.Role = "leader" }},
+		{name: "worker without coordinator", change: func(c *ServerConfig) { c.Cluster.Role = RoleWorker }},
+		{name: "worker", change: func(c *ServerConfig) { c.Cluster.Role, c.Cluster.Coordinator = RoleWorker, "coord:8080" }, ok: true},
+		{name: "bad worker label", change: func(c *ServerConfig) {
+			c.Cluster.Role, c.Cluster.Coordinator, c.Cluster.WorkerLabels = RoleWorker, "coord:8080", "gpu"
+		}},
+		{name: "webhook attempts", change: func(c *ServerConfig) { c.Webhooks.MaxAttempts = 0 }},
+		{name: "negative artifact limit", change: func(c *ServerConfig) { c.Jobs.ArtifactFiles = -1 }},
+	}
+	for _, tt := range tests {
+		cfg := DefaultServerConfig()
+		tt.change(&cfg)
+		if err := cfg.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
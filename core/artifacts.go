@@ -0,0 +1,311 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// a file collected from a job's working directory after it finished
+type Artifact struct {
+	Path string // relative to the working directory, with forward slashes
+	Size int64
+}
+
+// how much of a job's working directory is collected, 0 means no limit
+type ArtifactLimit struct {
+	Bytes int64 // total size of the files
+	Files int
+}
+
+func (l ArtifactLimit) Validate() error {
+	if l.Bytes < 0 || l.Files < 0 {
+		return fmt.Errorf("artifact limits must not be negative, got %d bytes and %d files", l.Bytes, l.Files)
+	}
+	return nil
+}
+
+// where collected artifacts are kept, one directory per job; empty disables collection
+// dir's parent is the server's data directory, nothing is collected from a
+// working directory inside it or containing it
+func (jd *JobDispatcher) SetArtifactDir(dir string) {
+	jd.lock.Lock()
+	defer jd.lock.Unlock()
+	jd.artifactDir = dir
+}
+
+// patterns are relative slash separated globs that stay inside the working
+// directory, a "**" segment matches any number of directories
+func ValidateArtifactPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if pattern == "" || path.IsAbs(pattern) || filepath.IsAbs(pattern) {
+			return fmt.Errorf("%w: artifact pattern %q must be a relative path", ErrInvalidJob, pattern)
+		}
+		for _, segment := range strings.Split(pattern, "/") {
+			if segment == ".." {
+				return fmt.Errorf("%w: artifact pattern %q leaves the working directory", ErrInvalidJob, pattern)
+			}
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("%w: artifact pattern %q: %v", ErrInvalidJob, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// match a slash separated relative path against a pattern with optional ** segments
+func matchArtifact(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// where to look for a pattern's matches: the segments before the first wildcard,
+// or the file itself for a plain path
+func patternRoot(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if strings.ContainsAny(segment, `*?[\`) {
+			return path.Join(segments[:i]...)
+		}
+	}
+	return pattern
+}
+
+// copy the regular files under workdir matching any of patterns into dest, up
+// to limit; a uid other than -1 only collects the files it owns
+// symlinks are not followed anywhere below workdir, so a job cannot make the
+// server copy files from elsewhere
+func collectArtifacts(workdir string, patterns []string, dest string, limit ArtifactLimit, uid int) ([]Artifact, error) {
+	workdir, err := filepath.EvalSymlinks(workdir)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool)
+	var errs []error
+	for _, pattern := range patterns {
+		if limit.Files > 0 && len(found) > limit.Files {
+			break
+		}
+		root := filepath.Join(workdir, filepath.FromSlash(patternRoot(pattern)))
+		// the walk doesn't follow links but the path to where it starts would
+		if real, err := filepath.EvalSymlinks(root); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		} else if real != root {
+			errs = append(errs, fmt.Errorf("artifact pattern %q: %s goes through a link", pattern, root))
+			continue
+		}
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			rel, err := filepath.Rel(workdir, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if d.Type().IsRegular() && matchArtifact(pattern, rel) {
+				found[rel] = true
+				// one more than the limit is enough to know it was exceeded
+				if limit.Files > 0 && len(found) > limit.Files {
+					return fs.SkipAll
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if limit.Files > 0 && len(names) > limit.Files {
+		errs = append(errs, fmt.Errorf("more than %d files match, only the first %d are collected", limit.Files, limit.Files))
+		names = names[:limit.Files]
+	}
+	artifacts := make([]Artifact, 0, len(names))
+	var total int64
+	for _, name := range names {
+		left := int64(-1)
+		if limit.Bytes > 0 {
+			left = limit.Bytes - total
+		}
+		size, err := copyFile(workdir, name, filepath.Join(dest, filepath.FromSlash(name)), left, uid)
+		if errors.Is(err, errArtifactTooLarge) {
+			errs = append(errs, fmt.Errorf("%s: %w, the artifacts are limited to %d bytes", name, err, limit.Bytes))
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		total += size
+		artifacts = append(artifacts, Artifact{Path: name, Size: size})
+	}
+	return artifacts, errors.Join(errs...)
+}
+
+var errArtifactTooLarge = errors.New("too large for what is left of the limit")
+
+// open the regular file name below dir one component at a time without
+// following links, a process still running can't swap one in after the walk
+func openNoFollow(dir, name string) (*os.File, error) {
+	fd, err := syscall.Open(dir, syscall.O_DIRECTORY|syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: dir, Err: err}
+	}
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		flags := syscall.O_RDONLY | syscall.O_CLOEXEC | syscall.O_NOFOLLOW
+		if i < len(segments)-1 {
+			flags |= syscall.O_DIRECTORY
+		} else {
+			// a fifo swapped in must not block the collection
+			flags |= syscall.O_NONBLOCK
+		}
+		next, err := syscall.Openat(fd, segment, flags, 0)
+		syscall.Close(fd)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: filepath.Join(dir, filepath.FromSlash(name)), Err: err}
+		}
+		fd = next
+	}
+	f := os.NewFile(uintptr(fd), filepath.Join(dir, filepath.FromSlash(name)))
+	info, err := f.Stat()
+	if err == nil && !info.Mode().IsRegular() {
+		err = &fs.PathError{Op: "open", Path: f.Name(), Err: errors.New("not a regular file")}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// copy at most left bytes of name, -1 means no limit; a uid other than -1 has to own the file
+func copyFile(workdir, name, dst string, left int64, uid int) (int64, error) {
+	in, err := openNoFollow(workdir, name)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); uid != -1 && (!ok || st.Uid != uint32(uid)) {
+		return 0, &fs.PathError{Op: "collect", Path: in.Name(), Err: errors.New("not owned by the job's user")}
+	}
+	if left >= 0 && info.Size() > left {
+		return 0, errArtifactTooLarge
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	src := io.Reader(in)
+	if left >= 0 {
+		// the job may still be writing to it
+		src = io.LimitReader(in, left+1)
+	}
+	n, err := io.Copy(out, src)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && left >= 0 && n > left {
+		os.Remove(dst)
+		return 0, errArtifactTooLarge
+	}
+	return n, err
+}
+
+// artifacts collected from a job, empty until it has finished
+// they are collected after the job counts as finished, this waits for that
+func (jd *JobDispatcher) Artifacts(ctx context.Context, jobId string) ([]Artifact, error) {
+	jobStatus, err := jd.QueryJob(jobId)
+	if err != nil {
+		return nil, err
+	}
+	if jobStatus.Job.State != Finished {
+		return nil, nil
+	}
+	select {
+	case <-jobStatus.Job.collected:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	jobStatus, err = jd.QueryJob(jobId)
+	if err != nil {
+		return nil, err
+	}
+	return jobStatus.Artifacts, nil
+}
+
+// open a collected artifact for reading, the caller closes the file
+func (jd *JobDispatcher) OpenArtifact(ctx context.Context, jobId, name string) (*os.File, Artifact, error) {
+	artifacts, err := jd.Artifacts(ctx, jobId)
+	if err != nil {
+		return nil, Artifact{}, err
+	}
+	// only names from the list are opened, so a request cannot reach outside the job's directory
+	for _, artifact := range artifacts {
+		if artifact.Path == name {
+			f, err := os.Open(filepath.Join(jd.jobArtifactDir(jobId), filepath.FromSlash(name)))
+			if err != nil {
+				return nil, Artifact{}, err
+			}
+			return f, artifact, nil
+		}
+	}
+	return nil, Artifact{}, fmt.Errorf("%w: %s", ErrArtifactNotFound, name)
+}
+
+func (jd *JobDispatcher) jobArtifactDir(jobId string) string {
+	jd.lock.RLock()
+	defer jd.lock.RUnlock()
+	if jd.artifactDir == "" {
+		return ""
+	}
+	return filepath.Join(jd.artifactDir, jobId)
+}
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchArtifact(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		ok            bool
+	}{
+		{pattern: "out.txt", name: "out.txt", ok: true},
+		{pattern: "*.log", name: "a.log", ok: true},
+		{pattern: "*.log", name: "dir/a.log"},
+		{pattern: "**/*.log", name: "a.log", ok: true},
+		{pattern: "**/*.log", name: "dir/sub/a.log", ok: true},
+		{pattern: "build/**", name: "build/x/y", ok: true},
+		{pattern: "build/**", name: "src/x"},
+		{pattern: "a/**/b", name: "a/b", ok: true},
+		{pattern: "a/**/b", name: "a/x/y/b", ok: true},
+		{pattern: "a/**/b", name: "a/x/c"},
+	}
+	for _, tt := range tests {
+		if got := matchArtifact(tt.pattern, tt.name); got != tt.ok {
+			t.Errorf("matchArtifact(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.ok)
+		}
+	}
+}
+
+func TestValidateArtifactPatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		ok      bool
+	}{
+		{pattern: "out/*.txt", ok: true},
+		{pattern: "**/*.log", ok: true},
+		{pattern: ""},
+		{pattern: "/etc/passwd"},
+		{pattern: "../secret"},
+		{pattern: "a/../../b"},
+		{pattern: "[a"},
+	}
+	for _, tt := range tests {
+		err := ValidateArtifactPatterns([]string{tt.pattern})
+		if tt.ok && err != nil {
+			t.Errorf("ValidateArtifactPatterns(%q) = %v", tt.pattern, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidJob) {
+			t.Errorf("ValidateArtifactPatterns(%q) = %v, want ErrInvalidJob", tt.pattern, err)
+		}
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func artifactPaths(artifacts []Artifact) string {
+	paths := make([]string, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		paths = append(paths, artifact.Path)
+	}
+	return strings.Join(paths, ",")
+}
+
+func TestCollectArtifactsLinks(t *testing.T) {
+	outside := t.TempDir()
+	writeFiles(t, outside, map[string]string{"secret": "key", "dir/secret.log": "key"})
+	workdir := t.TempDir()
+	writeFiles(t, workdir, map[string]string{"out/a.log": "a", "out/sub/b.log": "b"})
+	for link, target := range map[string]string{
+		"out/c.log":   filepath.Join(outside, "secret"),
+		"out/linked":  filepath.Join(outside, "dir"),
+		"via":         filepath.Join(outside, "dir"),
+		"out/sub/d.l": "a.log",
+	} {
+		if err := os.Symlink(target, filepath.Join(workdir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dest := t.TempDir()
+	artifacts, err := collectArtifacts(workdir, []string{"out/**/*.log", "via/*.log"}, dest, ArtifactLimit{}, -1)
+	if got := artifactPaths(artifacts); got != "out/a.log,out/sub/b.log" {
+		t.Errorf("collected %s, want only the regular files", got)
+	}
+	// the pattern starting at a link is reported, links found by the walk are skipped
+	if err == nil || !strings.Contains(err.Error(), "goes through a link") {
+		t.Errorf("collectArtifacts error = %v, want the link named", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "out", "sub", "b.log")); err != nil || string(data) != "b" {
+		t.Errorf("copied b.log = %q, %v", data, err)
+	}
+}
+
+func TestCollectArtifactsLimits(t *testing.T) {
+	workdir := t.TempDir()
+	writeFiles(t, workdir, map[string]string{"a": "12345", "b": "12345", "c": "12345"})
+	tests := []struct {
+		name  string
+		limit ArtifactLimit
+		want  string
+		err   bool
+	}{
+		{name: "no limit", want: "a,b,c"},
+		{name: "enough", limit: ArtifactLimit{Bytes: 15, Files: 3}, want: "a,b,c"},
+		{name: "files", limit: ArtifactLimit{Files: 2}, want: "a,b", err: true},
+		{name: "bytes", limit: ArtifactLimit{Bytes: 12}, want: "a,b", err: true},
+	}
+	for _, tt := range tests {
+		dest := t.TempDir()
+		artifacts, err := collectArtifacts(workdir, []string{"*"}, dest, tt.limit, -1)
+		if got := artifactPaths(artifacts); got != tt.want || (err != nil) != tt.err {
+			t.Errorf("%s: collected %s, %v, want %s and error %v", tt.name, got, err, tt.want, tt.err)
+		}
+		// nothing is left of a file over the limit
+		if _, err := os.Stat(filepath.Join(dest, "c")); tt.want == "a,b" && !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: c was copied: %v", tt.name, err)
+		}
+	}
+}
+
+func TestCollectArtifactsOwner(t *testing.T) {
+	workdir := t.TempDir()
+	writeFiles(t, workdir, map[string]string{"a": "a"})
+	artifacts, err := collectArtifacts(workdir, []string{"a"}, t.TempDir(), ArtifactLimit{}, os.Getuid()+1)
+	if len(artifacts) != 0 || err == nil {
+		t.Errorf("collected %s, %v from another user's file", artifactPaths(artifacts), err)
+	}
+}
+
+func TestCheckOutside(t *testing.T) {
+	root := t.TempDir()
+	data := filepath.Join(root, "data")
+	writeFiles(t, root, map[string]string{"data/secret.key": "k", "data/artifacts/x": "x", "work/a": "a"})
+	tests := []struct {
+		workdir string
+		ok      bool
+	}{
+		{workdir: filepath.Join(root, "work"), ok: true},
+		{workdir: data},
+		{workdir: filepath.Join(data, "artifacts")},
+		{workdir: root},
+		{workdir: "/"},
+	}
+	for _, tt := range tests {
+		if err := checkOutside(tt.workdir, data); (err == nil) != tt.ok {
+			t.Errorf("checkOutside(%s) = %v, want ok %v", tt.workdir, err, tt.ok)
+		}
+	}
+}
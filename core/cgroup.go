@@ -0,0 +1,117 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// how long freezing, thawing or emptying a cgroup may take before giving up
+const freezeTimeout = 5 * time.Second
+
+// create jobs in their own cgroup below dir, which must be in a cgroup v2
+// hierarchy writable by the server; empty disables cgroups and with them Pause
+func (jd *JobDispatcher) SetCgroupRoot(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(dir, "cgroup.freeze")); err != nil {
+			if _, err := os.Stat(filepath.Join(dir, "cgroup.procs")); err != nil {
+				return fmt.Errorf("%s is not a cgroup v2 directory", dir)
+			}
+			// the root cgroup has no cgroup.freeze, but its children do
+		}
+	}
+	jd.lock.Lock()
+	defer jd.lock.Unlock()
+	jd.cgroupRoot = dir
+	return nil
+}
+
+// create the job's cgroup, the returned directory fd lets the process start inside it
+func createCgroup(root, jobId string) (string, int, error) {
+	// the ID is part of the path
+	if err := validateJobId(jobId); err != nil {
+		return "", -1, err
+	}
+	path := filepath.Join(root, jobId)
+	if err := os.Mkdir(path, 0o755); err != nil {
+		return "", -1, fmt.Errorf("creating cgroup: %w", err)
+	}
+	fd, err := syscall.Open(path, syscall.O_DIRECTORY|syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		os.Remove(path)
+		return "", -1, fmt.Errorf("opening cgroup: %w", err)
+	}
+	return path, fd, nil
+}
+
+// kill whatever the job left behind in its cgroup and remove it, a cgroup can
+// only be removed once all its processes are gone
+func removeCgroup(path string) error {
+	err := os.WriteFile(filepath.Join(path, "cgroup.kill"), []byte("1"), 0)
+	if errors.Is(err, os.ErrNotExist) {
+		// before linux 5.14, kill the processes one by one
+		err = killCgroupProcs(path)
+	}
+	if err != nil {
+		return err
+	}
+	if err := waitCgroupEvent(path, "populated 0"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func killCgroupProcs(path string) error {
+	procs, err := os.ReadFile(filepath.Join(path, "cgroup.procs"))
+	if err != nil {
+		return err
+	}
+	for _, field := range strings.Fields(string(procs)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}
+	return nil
+}
+
+// freeze or thaw every process in the cgroup and wait until the kernel reports it done
+func setFrozen(path string, frozen bool) error {
+	value := "0"
+	if frozen {
+		value = "1"
+	}
+	if err := os.WriteFile(filepath.Join(path, "cgroup.freeze"), []byte(value), 0); err != nil {
+		return err
+	}
+	return waitCgroupEvent(path, "frozen "+value)
+}
+
+// wait until cgroup.events has the line event, e.g. "frozen 1"
+func waitCgroupEvent(path, event string) error {
+	want := []byte(event)
+	deadline := time.Now().Add(freezeTimeout)
+	for {
+		events, err := os.ReadFile(filepath.Join(path, "cgroup.events"))
+		if err != nil {
+			return err
+		}
+		for _, line := range bytes.Split(events, []byte("\n")) {
+			if bytes.Equal(bytes.TrimSpace(line), want) {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cgroup %s did not reach %q within %s", path, event, freezeTimeout)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,19 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestCreateCgroupRejectsPath(t *testing.T) {
+	root := t.TempDir()
+	for _, id := range []string{"../escape", "a/b", ""} {
+		if _, _, err := createCgroup(root, id); !errors.Is(err, ErrInvalidJobID) {
+			t.Errorf("createCgroup(%q) = %v, want ErrInvalidJobID", id, err)
+		}
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 0 {
+		t.Errorf("directories were created: %v", entries)
+	}
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// what Drain does with jobs that are still running
+const (
+	DrainWait   = "wait"   // block until every running job exits
+	DrainStop   = "stop"   // kill every running job
+	DrainDetach = "detach" // leave running jobs alone
+)
+
+var ErrDraining = errors.New("server is draining, not accepting new jobs")
+
+func ValidateDrainPolicy(policy string) error {
+	switch policy {
+	case DrainWait, DrainStop, DrainDetach:
+		return nil
+	}
+	return fmt.Errorf("invalid drain policy %q, expected %s, %s or %s", policy, DrainWait, DrainStop, DrainDetach)
+}
+
+func (jd *JobDispatcher) Draining() bool {
+	jd.lock.RLock()
+	defer jd.lock.RUnlock()
+	return jd.draining
+}
+
+// number of jobs that are created or running
+func (jd *JobDispatcher) ActiveJobs() int {
+	jd.lock.RLock()
+	defer jd.lock.RUnlock()
+	n := 0
+	for _, job := range jd.jobs {
+		if job.State != Finished {
+			n++
+		}
+	}
+	return n
+}
+
+// IDs of the jobs that are created, running or paused
+func (jd *JobDispatcher) ActiveJobIDs() []string {
+	jd.lock.RLock()
+	defer jd.lock.RUnlock()
+	var ids []string
+	for id, job := range jd.jobs {
+		if job.State != Finished {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// number of jobs in each state
+func (jd *JobDispatcher) CountByState() map[string]int {
+	jd.lock.RLock()
+	defer jd.lock.RUnlock()
+	counts := make(map[string]int)
+	for _, job := range jd.jobs {
+		counts[job.State]++
+	}
+	return counts
+}
+
+// stop accepting new jobs and apply policy to the running ones
+// with DrainWait it returns ctx.Err() if the context ends before the jobs do
+func (jd *JobDispatcher) Drain(ctx context.Context, policy string) error {
+	if err := ValidateDrainPolicy(policy); err != nil {
+		return err
+	}
+	jd.lock.Lock()
+	jd.draining = true
+	if policy == DrainStop {
+		select {
+		case <-jd.cancelQueued:
+		default:
+			close(jd.cancelQueued)
+		}
+	}
+	var running []string
+	for id, job := range jd.jobs {
+		if job.alive() {
+			running = append(running, id)
+		}
+	}
+	jd.lock.Unlock()
+	slog.Info("draining job dispatcher", "policy", policy, "running", len(running))
+
+	switch policy {
+	case DrainStop:
+		for _, id := range running {
+			jd.StopJob(id)
+		}
+	case DrainDetach:
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		jd.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	running := Job{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", Cmd: "sleep 10"}
+	queued := Job{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Cmd: "echo ran"}
+	tests := []struct {
+		policy string
+		err    error
+		active int // jobs left created or running
+	}{
+		{policy: DrainDetach, active: 2},
+		{policy: DrainWait, err: context.DeadlineExceeded, active: 2},
+		{policy: DrainStop},
+	}
+	for _, tt := range tests {
+		jd := NewJobDispatcher()
+		jd.SetLimits(Limits{MaxConcurrent: 1})
+		if err := jd.SubmitJob(running); err != nil {
+			t.Fatal(err)
+		}
+		waitRunning(t, jd, running.ID)
+		if err := jd.SubmitJob(queued); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		err := jd.Drain(ctx, tt.policy)
+		cancel()
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Drain(%s) = %v, want %v", tt.policy, err, tt.err)
+		}
+		if !jd.Draining() {
+			t.Errorf("Drain(%s): not draining", tt.policy)
+		}
+		if err := jd.SubmitJob(Job{ID: "a5d4f9b2-6c1e-4d3a-9b7f-2e8c1d0a3b4c", Cmd: "true"}); !errors.Is(err, ErrDraining) {
+			t.Errorf("Drain(%s): SubmitJob = %v, want ErrDraining", tt.policy, err)
+		}
+		if active := jd.ActiveJobs(); active != tt.active {
+			t.Errorf("Drain(%s): %d active jobs, want %d", tt.policy, active, tt.active)
+		}
+		if tt.policy == DrainStop {
+			// the queued job never ran
+			if status, _ := jd.QueryJob(queued.ID); !status.StartedAt.IsZero() || status.ExitCode != 1 {
+				t.Errorf("Drain(stop): queued job started %v, exit code %d", status.StartedAt, status.ExitCode)
+			}
+		}
+		jd.StopJob(running.ID)
+	}
+	if err := NewJobDispatcher().Drain(context.Background(), "later"); err == nil {
+		t.Error("Drain accepted an invalid policy")
+	}
+}
@@ -1,35 +1,75 @@
 package core
 
 import (
-	"bufio"
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
-	"io"
+	"io/fs"
+	"log/slog"
+	"main/metrics"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 type Job struct {
 	ID     string
 	Cmd    string
 	User   string
+	Caller string // verified caller that started the job, empty when anonymous
 	State  string
-	cmdObj *exec.Cmd
-	buffer *bytes.Buffer
+	Labels map[string]string // set at start, never changed afterwards
+	// environment variables added to the server's, working directory, and a limit overriding DefaultTimeout
+	Env     map[string]string
+	Dir     string
+	Timeout time.Duration
+	// files to keep once the job has finished, see ValidateArtifactPatterns
+	Artifacts []string
+	Secrets   []SecretRef
+	Webhooks  []string // called with the final status, see sendWebhooks
+	// set at start to the limit the job asked for combined with the server's
+	OutputLimit OutputLimit
+	cmdObj      *exec.Cmd
+	cgroup      string    // the job's cgroup directory when cgroups are enabled
+	freezing    bool      // PauseJob or ResumeJob is waiting for the freezer
+	timeout     *jobTimer // nil without a timeout
+	output      *outputLog
+	groups      []string      // groups of the user whose quotas the job counts against
+	quotaSlot   bool          // the job got past the running-jobs quotas, see acquireQuotaSlot
+	waiting     bool          // blocked waiting for a running slot, counts as queued
+	stop        chan struct{} // closed by StopJob while the job is created, it then never runs
+	stopped     bool          // stop is closed
+	done        chan struct{} // closed once the process has exited and the final status is set
+	collected   chan struct{} // closed after done once the artifacts are collected
+	// value of the dispatcher's revision at the job's last change
+	revision uint64
 }
 
 type JobStatus struct {
-	Job      *Job
-	ExitCode int
-	ErrorMsg string
+	Job        *Job
+	ExitCode   int
+	ErrorMsg   string
+	Artifacts  []Artifact // collected after the job finished, see JobDispatcher.Artifacts
+	StartedAt  time.Time  // zero until the process started
+	FinishedAt time.Time  // zero until the final status is set
+	Deliveries []WebhookDelivery
 }
 
 func (j Job) ToString() string {
 	return fmt.Sprintf("ID: %s, Cmd: %s, User: %s, State: %s", j.ID, j.Cmd, j.User, j.State)
 }
 
+// whether output of the job was discarded because of its output limit
+func (js JobStatus) Truncated() bool {
+	return js.Job.output != nil && js.Job.output.isTruncated()
+}
+
 func (js JobStatus) ToString() string {
 	return fmt.Sprintf("Job: %s, ExitCode: %d, ErrorMsg: %s", js.Job.ToString(), js.ExitCode, js.ErrorMsg)
 }
@@ -37,18 +77,38 @@ func (js JobStatus) ToString() string {
 const (
 	Created  = "created"
 	Running  = "running"
+	Paused   = "paused" // frozen by PauseJob, still has its processes
 	Finished = "finished"
 )
 
 type JobDispatcher struct {
 	// map of job id to job initialized as empty
-	jobs        map[string]*Job       // contain job info
-	JobStatuses map[string]*JobStatus // contain job info and exit status
-	lock        sync.RWMutex          // read write lock
+	jobs          map[string]*Job       // contain job info
+	JobStatuses   map[string]*JobStatus // contain job info and exit status
+	lock          sync.RWMutex          // read write lock
+	draining      bool                  // set by Drain, no new jobs are accepted
+	running       sync.WaitGroup        // jobs whose process has not exited yet
+	limits        Limits
+	slots         chan struct{} // one entry per running job when MaxConcurrent is set
+	cancelQueued  chan struct{} // closed by a stopping drain, jobs waiting for a slot give up
+	labels        labelIndex
+	revision      uint64        // counts changes to jobs, see notifyLocked
+	changed       chan struct{} // closed and replaced on every change, watchers wait on it
+	artifactDir   string
+	secrets       SecretSource
+	cgroupRoot    string
+	quotas        Quotas
+	userGroups    func(user string) []string
+	cpuLog        []cpuRecord // CPU time of the jobs finished in the last day, oldest first
+	webhookConfig WebhookConfig
+	webhooks      sync.WaitGroup // deliveries in progress
+	webhookCtx    context.Context
+	// ends the deliveries in progress, see WaitWebhooks
+	cancelWebhooks context.CancelFunc
 }
 
-func NewJobDispatcher() JobDispatcher {
-	jd := JobDispatcher{}
+func NewJobDispatcher() *JobDispatcher {
+	jd := &JobDispatcher{}
 	jd.Init()
 	return jd
 }
@@ -56,16 +116,75 @@ func NewJobDispatcher() JobDispatcher {
 func (jd *JobDispatcher) Init() {
 	jd.jobs = make(map[string]*Job)
 	jd.JobStatuses = make(map[string]*JobStatus)
+	jd.cancelQueued = make(chan struct{})
+	jd.labels = make(labelIndex)
+	jd.changed = make(chan struct{})
+	jd.webhookCtx, jd.cancelWebhooks = context.WithCancel(context.Background())
 	// lru
 }
 
+// change the state of a job and keep the state metrics in sync, caller holds the lock
+// watchers are notified even if the state stays the same, the exit status may have changed
+func (jd *JobDispatcher) setState(job *Job, state string) {
+	jd.notifyLocked(job)
+	if job.State == state {
+		return
+	}
+	metrics.StateChanged(job.State, state)
+	job.State = state
+}
+
+// job IDs name directories and cgroups, only the canonical form of a UUID is
+// accepted so an ID can't be a path
 func validateJobId(jobId string) error {
-	if _, err := uuid.Parse(jobId); err != nil {
-		return err
+	id, err := uuid.Parse(jobId)
+	if err != nil {
+		return fmt.Errorf("%w %q: %v", ErrInvalidJobID, jobId, err)
+	}
+	if id.String() != jobId {
+		return fmt.Errorf("%w %q: not a lowercase UUID without braces or prefix", ErrInvalidJobID, jobId)
 	}
 	return nil
 }
 
+// check the settings chosen by the caller
+func ValidateJob(job Job) error {
+	if err := validateJobId(job.ID); err != nil {
+		return err
+	}
+	if err := ValidateLabels(job.Labels); err != nil {
+		return err
+	}
+	for name := range job.Env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("%w: environment variable name %q", ErrInvalidJob, name)
+		}
+	}
+	if job.Timeout < 0 {
+		return fmt.Errorf("%w: negative timeout", ErrInvalidJob)
+	}
+	if err := validateSecretRefs(job.Secrets); err != nil {
+		return err
+	}
+	if err := ValidateWebhooks(job.Webhooks); err != nil {
+		return err
+	}
+	if job.OutputLimit.Bytes < 0 {
+		return fmt.Errorf("%w: negative output limit", ErrInvalidJob)
+	}
+	if job.OutputLimit.Policy != "" {
+		if err := ValidateOutputPolicy(job.OutputLimit.Policy); err != nil {
+			return err
+		}
+	}
+	// a relative directory would be the server's own, where its keys are; an
+	// absolute one is checked against the data directory before collecting
+	if len(job.Artifacts) > 0 && !filepath.IsAbs(job.Dir) {
+		return fmt.Errorf("%w: collecting artifacts needs an absolute working directory", ErrInvalidJob)
+	}
+	return ValidateArtifactPatterns(job.Artifacts)
+}
+
 // list all jobs
 func (jd *JobDispatcher) ListJobs() []JobStatus {
 	jd.lock.RLock()
@@ -77,120 +196,502 @@ func (jd *JobDispatcher) ListJobs() []JobStatus {
 	return jobs
 }
 
-func (jd *JobDispatcher) StopJob(jobId string) string {
+func (jd *JobDispatcher) StopJob(jobId string) error {
 	// if job is not found, print a message and return
 	if err := validateJobId(jobId); err != nil {
-		return "Invalid job ID"
+		return err
 	}
 	jd.lock.Lock()
+	defer jd.lock.Unlock()
 	job := jd.jobs[jobId]
-	jd.lock.Unlock()
-	if job.State == Running {
-		// Check if cmdObj is not nil and has a valid process
-		if job.cmdObj != nil && job.cmdObj.Process != nil {
-			// Attempt to kill the process
-			jd.lock.Lock()
-			err := job.cmdObj.Process.Kill()
-			println("killing process")
-			if err != nil {
-				return "Failed to kill the process:"
-			}
-			job.State = Finished
-			jobStatus := jd.JobStatuses[jobId]
-			jobStatus.ExitCode = 1
-			jobStatus.ErrorMsg = "signal: killed" // e.g. sleep 50 && pwd
-			jd.lock.Unlock()
-		} else {
-			return "No process to kill or command was not started"
+	if job == nil {
+		return ErrJobNotFound
+	}
+	if job.State == Created {
+		// waiting for a slot or about to start, run finishes it
+		if !job.stopped {
+			slog.Info("stopping queued job", "job_id", jobId)
+			job.stopped = true
+			close(job.stop)
 		}
-		return job.ToString()
-	} else {
-		return "Job is not running"
+		return nil
 	}
+	// Check if cmdObj is not nil and has a valid process
+	if !job.alive() || job.cmdObj == nil || job.cmdObj.Process == nil {
+		return ErrJobNotRunning
+	}
+	// Attempt to kill the process
+	slog.Info("killing process", "job_id", jobId, "pid", job.cmdObj.Process.Pid)
+	// the job runs in its own process group, kill the whole group so no children are left behind
+	err := syscall.Kill(-job.cmdObj.Process.Pid, syscall.SIGKILL)
+	if err != nil {
+		slog.Error("failed to kill process", "job_id", jobId, "err", err)
+		return fmt.Errorf("failed to kill the process: %w", err)
+	}
+	jd.setState(job, Finished)
+	jobStatus := jd.JobStatuses[jobId]
+	jobStatus.ExitCode = 1
+	jobStatus.ErrorMsg = "signal: killed" // e.g. sleep 50 && pwd
+	return nil
+}
+
+func (jd *JobDispatcher) stopRequested(job *Job) bool {
+	jd.lock.RLock()
+	defer jd.lock.RUnlock()
+	return job.stopped
+}
+
+// the job's process was started and has not exited, whether paused or not
+func (j *Job) alive() bool {
+	return j.State == Running || j.State == Paused
 }
 
-func (jd *JobDispatcher) QueryJob(jobId string) JobStatus {
+func (jd *JobDispatcher) QueryJob(jobId string) (JobStatus, error) {
 	jd.lock.RLock()
 	defer jd.lock.RUnlock()
 	// if job is not found, print a message and return
 	if err := validateJobId(jobId); err != nil {
-		return JobStatus{Job: &Job{ID: jobId}, ExitCode: -1, ErrorMsg: "Invalid job ID"}
+		return JobStatus{}, err
 	}
 	jobStatus := jd.JobStatuses[jobId]
 	if jobStatus == nil {
-		return JobStatus{Job: &Job{ID: jobId}, ExitCode: -1, ErrorMsg: "Job not found"}
+		return JobStatus{}, ErrJobNotFound
 	}
-	return *jobStatus
+	return *jobStatus, nil
 }
 
 func (jd *JobDispatcher) StartJob(job Job) string {
+	j, err := jd.admit(job)
+	if err != nil {
+		return err.Error()
+	}
+	return jd.run(j)
+}
+
+// register the job and run it in the background, an error means it was not accepted
+func (jd *JobDispatcher) SubmitJob(job Job) error {
+	j, err := jd.admit(job)
+	if err != nil {
+		return err
+	}
+	go jd.run(j)
+	return nil
+}
+
+// register the job in the created state, unless the dispatcher is draining or
+// a quota of the job's user is used up
+func (jd *JobDispatcher) admit(job Job) (*Job, error) {
+	// looking up groups may be slow, do it before taking the lock
+	job.groups = jd.quotaGroups(job.quotaUser())
 	jd.lock.Lock()
-	job.State = Running
+	defer jd.lock.Unlock()
+	if jd.draining {
+		return nil, ErrDraining
+	}
+	if jd.jobs[job.ID] != nil {
+		return nil, fmt.Errorf("%w: %s", ErrJobExists, job.ID)
+	}
+	if err := jd.admitQuotaLocked(&job); err != nil {
+		return nil, err
+	}
+	jd.running.Add(1)
+	job.State = ""
+	jd.setState(&job, Created)
+	job.OutputLimit = jd.outputLimitLocked(job.OutputLimit)
+	if left := jd.outputQuotaLocked(&job); left > 0 && (job.OutputLimit.Bytes == 0 || left < job.OutputLimit.Bytes) {
+		job.OutputLimit.Bytes = left
+	}
+	id := job.ID
+	job.output = newOutputLog(job.OutputLimit, func() {
+		slog.Info("job exceeded its output limit", "job_id", id, "limit", job.OutputLimit.Bytes)
+		go jd.StopJob(id)
+	})
+	job.done = make(chan struct{})
+	job.collected = make(chan struct{})
+	job.stop = make(chan struct{})
+	jd.jobs[job.ID] = &job
+	jd.labels.add(job.ID, job.Labels)
+	jd.JobStatuses[job.ID] = &JobStatus{Job: &job, ExitCode: -1, ErrorMsg: ""}
+	return &job, nil
+}
+
+// run an admitted job until its process exits
+func (jd *JobDispatcher) run(job *Job) string {
+	defer jd.running.Done()
+	jd.lock.Lock()
+	cgroupRoot := jd.cgroupRoot
 	cmdObj := exec.Command("sh", "-c", job.Cmd) // Create a new command object, prepare to run the command
+	// own process group: not hit by signals sent to the server's group, and can be killed as a unit
+	cmdObj.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmdObj.Dir = job.Dir
 	job.cmdObj = cmdObj
-	job.buffer = &bytes.Buffer{}
-	jd.jobs[job.ID] = &job
-	jobStatus := JobStatus{Job: &job, ExitCode: -1, ErrorMsg: ""}
-	jd.JobStatuses[job.ID] = &jobStatus
+	jobStatus := jd.JobStatuses[job.ID]
 	jd.lock.Unlock()
+	// the webhooks get the whole output
+	defer jd.sendWebhooks(job, jobStatus)
+	defer job.output.Close()
+	// copying artifacts can take a while, waiters are told the job finished first
+	defer jd.collectArtifacts(job, jobStatus)
+	defer close(job.done)
 	// 将io输入重定向到缓冲区
 	//var outBuf bytes.Buffer
-	cmdObj.Stdout = job.buffer // 将io输入重定向到缓冲区
+	cmdObj.Stdout = job.output // 将io输入重定向到缓冲区
+	cmdObj.Stderr = job.output.stream(StreamStderr)
 
-	// Start the command (non-blocking)
-	err := cmdObj.Start()
+	// wait for a free slot when the number of running jobs is limited
+	metrics.QueueDepth.Inc()
+	acquired := jd.acquireQuotaSlot(job) && jd.acquireSlot(job)
+	metrics.QueueDepth.Dec()
+	if !acquired {
+		jd.lock.Lock()
+		jd.setState(job, Finished)
+		jobStatus.ExitCode = 1
+		jobStatus.ErrorMsg = "cancelled before start: " + ErrDraining.Error()
+		if job.stopped {
+			jobStatus.ErrorMsg = "stopped before start"
+		}
+		jobStatus.FinishedAt = time.Now()
+		jd.lock.Unlock()
+		slog.Info("queued job cancelled", "job_id", job.ID)
+		return jobStatus.ErrorMsg
+	}
+	defer jd.releaseSlot()
+	cred, home, err := jd.jobCredential()
+	var secrets *jobSecrets
+	if err == nil {
+		// secrets are fetched only now, so a job waiting for a slot gets the current value
+		secrets, err = jd.resolveSecrets(job, cred)
+	}
+	var cgroupPath string
+	if err == nil {
+		defer secrets.cleanup()
+		if cred != nil {
+			cmdObj.SysProcAttr.Credential = cred
+			if cmdObj.Dir == "" {
+				cmdObj.Dir = home
+			}
+		}
+		job.output.redactValues(secrets.values)
+		if len(job.Env) > 0 || len(secrets.env) > 0 {
+			cmdObj.Env = os.Environ()
+			for k, v := range job.Env {
+				cmdObj.Env = append(cmdObj.Env, k+"="+v)
+			}
+			cmdObj.Env = append(cmdObj.Env, secrets.env...)
+		}
+		if cgroupRoot != "" {
+			var fd int
+			if cgroupPath, fd, err = createCgroup(cgroupRoot, job.ID); err == nil {
+				defer func() {
+					if err := removeCgroup(cgroupPath); err != nil {
+						slog.Warn("failed to remove cgroup", "job_id", job.ID, "cgroup", cgroupPath, "err", err)
+					}
+				}()
+				// the kernel starts the process inside the cgroup, so no child can escape it
+				cmdObj.SysProcAttr.UseCgroupFD = true
+				cmdObj.SysProcAttr.CgroupFD = fd
+				defer syscall.Close(fd)
+			}
+		}
+		if err == nil && jd.stopRequested(job) {
+			err = errors.New("stopped before start")
+		}
+		if err == nil {
+			// Start the command (non-blocking)
+			err = cmdObj.Start()
+		}
+	}
 	if err != nil {
 		jd.lock.Lock()
-		job.State = Finished
-		job.buffer.WriteString(err.Error() + "\n")
+		jd.setState(job, Finished)
+		job.output.writeLine(err.Error())
 		jobStatus.ExitCode = 1
 		jobStatus.ErrorMsg = err.Error()
+		jobStatus.FinishedAt = time.Now()
 		jd.lock.Unlock()
+		slog.Error("failed to start job", "job_id", job.ID, "err", err)
+		metrics.JobFinished(1, 0)
 		return "Failed to start job:"
 	}
+	startedAt := time.Now()
+	var timedOut atomic.Bool
+	timeout := jd.limits.DefaultTimeout
+	if job.Timeout > 0 {
+		timeout = job.Timeout
+	}
+	jd.lock.Lock()
+	job.cgroup = cgroupPath
+	jobStatus.StartedAt = startedAt
+	jd.setState(job, Running)
+	if timeout > 0 {
+		// time spent paused does not count, see PauseJob
+		job.timeout = newJobTimer(timeout, func() {
+			timedOut.Store(true)
+			slog.Info("job timed out", "job_id", job.ID, "timeout", timeout)
+			jd.StopJob(job.ID)
+		})
+	}
+	stopped := job.stopped
+	jd.lock.Unlock()
+	if stopped || job.OutputLimit.Policy == OutputKill && job.output.isTruncated() {
+		// stopped or over the limit before the job counted as running, StopJob did not kill it then
+		jd.StopJob(job.ID)
+	}
+	slog.Info("job started", "job_id", job.ID, "user", job.User, "pid", cmdObj.Process.Pid)
+	metrics.JobsStarted.Inc()
 	// Run the command in a goroutine
 	err = cmdObj.Wait() // Wait for the command to finish
+	if state := cmdObj.ProcessState; state != nil {
+		jd.recordCPU(job, (state.UserTime() + state.SystemTime()).Seconds())
+	}
+	jd.lock.Lock()
+	if job.timeout != nil {
+		job.timeout.stop()
+	}
+	jd.lock.Unlock()
 	if err != nil {
+		code := exitCode(err)
 		jd.lock.Lock()
-		job.State = Finished
-		job.buffer.WriteString(err.Error() + "\n")
-		jobStatus.ExitCode = 1
+		jd.setState(job, Finished)
+		job.output.writeLine(err.Error())
+		jobStatus.ExitCode = code
 		jobStatus.ErrorMsg = err.Error() // sleep 50
+		jobStatus.FinishedAt = time.Now()
+		if timedOut.Load() {
+			jobStatus.ErrorMsg = fmt.Sprintf("timed out after %s: %s", timeout, err)
+		} else if job.OutputLimit.Policy == OutputKill && job.output.isTruncated() {
+			jobStatus.ErrorMsg = fmt.Sprintf("output limit of %d bytes exceeded: %s", job.OutputLimit.Bytes, err)
+		}
 		jd.lock.Unlock()
+		slog.Info("job finished with error", "job_id", job.ID, "exit_code", code, "err", err, "duration", time.Since(startedAt))
+		metrics.JobFinished(code, time.Since(startedAt).Seconds())
 		return "Job finished with error:" + err.Error()
 	} else {
 		jd.lock.Lock()
-		job.State = Finished
+		jd.setState(job, Finished)
 		jobStatus.ExitCode = 0
 		jobStatus.ErrorMsg = ""
+		jobStatus.FinishedAt = time.Now()
 		jd.lock.Unlock()
-		println(strings.TrimSpace(job.buffer.String()))
-		return strings.TrimSpace(job.buffer.String())
+		slog.Info("job finished", "job_id", job.ID, "exit_code", 0, "duration", time.Since(startedAt))
+		metrics.JobFinished(0, time.Since(startedAt).Seconds())
+		return strings.TrimSpace(job.output.String())
 	}
 }
 
-//func (j *Job) stream() io.Reader {
-//	return j.buffer
-//}
+// copy the artifacts of a job whose process ran into its artifact directory,
+// problems are logged and added to the job's output but don't change its exit status
+func (jd *JobDispatcher) collectArtifacts(job *Job, jobStatus *JobStatus) {
+	defer close(job.collected)
+	jd.lock.RLock()
+	started := !jobStatus.StartedAt.IsZero()
+	dataDir := filepath.Dir(jd.artifactDir)
+	limit := jd.limits.Artifacts
+	jd.lock.RUnlock()
+	dir := jd.jobArtifactDir(job.ID)
+	if len(job.Artifacts) == 0 || dir == "" || !started {
+		return
+	}
+	cred, _, err := jd.jobCredential()
+	var artifacts []Artifact
+	if err == nil {
+		err = checkOutside(job.Dir, dataDir)
+	}
+	if err == nil {
+		uid := -1
+		if cred != nil {
+			// the server may read what the job's user can't
+			uid = int(cred.Uid)
+		}
+		artifacts, err = collectArtifacts(job.Dir, job.Artifacts, dir, limit, uid)
+	}
+	if err != nil {
+		slog.Warn("failed to collect artifacts", "job_id", job.ID, "err", err)
+		job.output.writeLine("collecting artifacts: " + err.Error())
+	}
+	slog.Info("artifacts collected", "job_id", job.ID, "count", len(artifacts))
+	jd.lock.Lock()
+	jobStatus.Artifacts = artifacts
+	jd.notifyLocked(job)
+	jd.lock.Unlock()
+}
 
-func (jd *JobDispatcher) Output(jobId string, channel chan string) {
+// fail if workdir is inside dir or contains it, the server's keys are there
+func checkOutside(workdir, dir string) error {
+	real := func(p string) (string, error) {
+		p, err := filepath.Abs(p)
+		if err != nil {
+			return "", err
+		}
+		return filepath.EvalSymlinks(p)
+	}
+	w, err := real(workdir)
+	if err != nil {
+		return err
+	}
+	d, err := real(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	within := func(p, parent string) bool {
+		rel, err := filepath.Rel(parent, p)
+		return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+	}
+	if within(w, d) || within(d, w) {
+		return fmt.Errorf("working directory %s overlaps the server's data directory %s", workdir, dir)
+	}
+	return nil
+}
+
+// exit status of a finished process, 128+signal when it was killed, as a shell reports it
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// send the output lines selected by opts to channel, which is closed when done
+// without opts.Follow it returns after the lines captured so far
+func (jd *JobDispatcher) Output(ctx context.Context, jobId string, opts OutputOptions, channel chan OutputLine) error {
 	defer close(channel)
+	return jd.readOutput(ctx, jobId, opts, false, func(lines []OutputLine) error {
+		for _, line := range lines {
+			select {
+			case channel <- line: // write data to channel, give to server
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+}
+
+// like Output, but send the raw bytes as chunks starting at opts.ByteOffset;
+// lines of a stream captured together are joined into one chunk
+func (jd *JobDispatcher) OutputChunks(ctx context.Context, jobId string, opts OutputOptions, channel chan OutputChunk) error {
+	defer close(channel)
+	return jd.readOutput(ctx, jobId, opts, true, func(lines []OutputLine) error {
+		var chunk *OutputChunk
+		send := func() error {
+			if chunk == nil {
+				return nil
+			}
+			select {
+			case channel <- *chunk:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			chunk = nil
+			return nil
+		}
+		for _, line := range lines {
+			if chunk != nil && (chunk.Stream != line.Stream || !chunk.Time.Equal(line.Time) || chunk.Dropped > 0 || line.Dropped > 0 ||
+				chunk.Offset+int64(len(chunk.Data)) != line.Offset || len(chunk.Data)+len(line.Text) >= maxChunkBytes) {
+				if err := send(); err != nil {
+					return err
+				}
+			}
+			if chunk == nil {
+				chunk = &OutputChunk{Offset: line.Offset, Line: line.Line, Time: line.Time, Stream: line.Stream, Dropped: line.Dropped}
+			}
+			chunk.Data = append(chunk.Data, line.Bytes()...)
+		}
+		return send()
+	})
+}
+
+// call send with the output selected by opts as it is captured, by line or
+// from opts.ByteOffset on when byOffset is set
+func (jd *JobDispatcher) readOutput(ctx context.Context, jobId string, opts OutputOptions, byOffset bool, send func([]OutputLine) error) error {
 	if err := validateJobId(jobId); err != nil {
-		return
+		return err
 	}
+	jd.lock.RLock()
 	job := jd.jobs[jobId]
+	jd.lock.RUnlock()
 	if job == nil {
-		return
+		return ErrJobNotFound
+	}
+	next := opts.Offset
+	if n := job.output.len(); opts.Tail > 0 && n-opts.Tail > next {
+		next = n - opts.Tail
 	}
-	stream := bufio.NewReader(job.buffer)
+	offset := max(opts.ByteOffset, job.output.lineOffset(next))
 	for {
-		line, err := stream.ReadString('\n')
-		if err == io.EOF && job.State == Finished {
-			break
+		var lines []OutputLine
+		var closed bool
+		var changed <-chan struct{}
+		if byOffset {
+			lines, closed, changed = job.output.readAt(offset)
+		} else {
+			lines, closed, changed = job.output.read(next)
+		}
+		selected := make([]OutputLine, 0, len(lines))
+		for _, line := range lines {
+			next = line.Line + 1
+			offset = line.Offset + line.size()
+			if !line.Time.Before(opts.Since) {
+				selected = append(selected, line)
+			}
+		}
+		if err := send(selected); err != nil {
+			return err
+		}
+		if closed || !opts.Follow {
+			return nil
 		}
-		if line != "" {
-			line = line[:len(line)-1]
-			channel <- line // write data to channel, give to server
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
 }
+
+var (
+	ErrInvalidJobID  = errors.New("invalid job ID")
+	ErrInvalidJob    = errors.New("invalid job")
+	ErrJobNotFound   = errors.New("job not found")
+	ErrJobExists     = errors.New("job ID already in use")
+	ErrJobNotRunning = errors.New("job is not running")
+	ErrJobActive     = errors.New("job has not finished")
+)
+
+// remove a finished job and its output
+func (jd *JobDispatcher) DeleteJob(jobId string) error {
+	if err := validateJobId(jobId); err != nil {
+		return err
+	}
+	jd.lock.Lock()
+	defer jd.lock.Unlock()
+	job := jd.jobs[jobId]
+	if job == nil {
+		return ErrJobNotFound
+	}
+	if job.State != Finished {
+		return ErrJobActive
+	}
+	select {
+	case <-job.collected:
+	default:
+		return fmt.Errorf("%w: artifacts are still being collected", ErrJobActive)
+	}
+	metrics.JobsByState.WithLabelValues(job.State).Dec()
+	jd.labels.remove(jobId, job.Labels)
+	jd.notifyLocked(job)
+	delete(jd.jobs, jobId)
+	delete(jd.JobStatuses, jobId)
+	if jd.artifactDir != "" {
+		if err := os.RemoveAll(filepath.Join(jd.artifactDir, jobId)); err != nil {
+			slog.Warn("failed to remove artifacts", "job_id", jobId, "err", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidateJobId(t *testing.T) {
+	tests := []struct {
+		id string
+		ok bool
+	}{
+		{id: "0f8fad5b-d9cb-469f-a165-70867728950e", ok: true},
+		{id: ""},
+		{id: "../../etc"},
+		{id: "0F8FAD5B-D9CB-469F-A165-70867728950E"},
+		{id: "{0f8fad5b-d9cb-469f-a165-70867728950e}"},
+		{id: "urn:uuid:0f8fad5b-d9cb-469f-a165-70867728950e"},
+		{id: "0f8fad5bd9cb469fa16570867728950e"},
+	}
+	for _, tt := range tests {
+		err := validateJobId(tt.id)
+		if tt.ok && err != nil {
+			t.Errorf("validateJobId(%q) = %v", tt.id, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidJobID) {
+			t.Errorf("validateJobId(%q) = %v, want ErrInvalidJobID", tt.id, err)
+		}
+	}
+}
+
+func TestAdmitDuplicateId(t *testing.T) {
+	jd := NewJobDispatcher()
+	job := Job{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", Cmd: "true"}
+	if _, err := jd.admit(job); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jd.admit(job); !errors.Is(err, ErrJobExists) {
+		t.Errorf("admitting the same ID again = %v, want ErrJobExists", err)
+	}
+}
+
+func TestStopQueuedJob(t *testing.T) {
+	jd := NewJobDispatcher()
+	jd.SetLimits(Limits{MaxConcurrent: 1})
+	running := Job{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", Cmd: "sleep 10"}
+	queued := Job{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Cmd: "echo ran"}
+	for _, job := range []Job{running, queued} {
+		if err := jd.SubmitJob(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer jd.StopJob(running.ID)
+	if err := jd.StopJob(queued.ID); err != nil {
+		t.Fatalf("StopJob of a queued job = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	statuses, err := jd.WaitJobs(ctx, []string{queued.ID}, WaitAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := statuses[0]; status.Job.State != Finished || status.ErrorMsg != "stopped before start" || !status.StartedAt.IsZero() {
+		t.Errorf("stopped queued job: state %s, error %q, started %v", status.Job.State, status.ErrorMsg, status.StartedAt)
+	}
+}
+
+func TestArtifactsAfterWait(t *testing.T) {
+	jd := NewJobDispatcher()
+	jd.SetArtifactDir(filepath.Join(t.TempDir(), "artifacts"))
+	job := Job{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", Cmd: "echo hi > out.txt", Dir: t.TempDir(), Artifacts: []string{"*.txt"}}
+	if err := jd.SubmitJob(job); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := jd.WaitJobs(ctx, []string{job.ID}, WaitAll); err != nil {
+		t.Fatal(err)
+	}
+	// collected after the job counts as finished, listing waits for them
+	artifacts, err := jd.Artifacts(ctx, job.ID)
+	if err != nil || len(artifacts) != 1 || artifacts[0].Path != "out.txt" || artifacts[0].Size != 3 {
+		t.Errorf("Artifacts() = %+v, %v, want out.txt", artifacts, err)
+	}
+	if err := jd.DeleteJob(job.ID); err != nil {
+		t.Errorf("DeleteJob() = %v once collected", err)
+	}
+}
+
+func TestOutputChunksResume(t *testing.T) {
+	jd := NewJobDispatcher()
+	job := &Job{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", output: newOutputLog(OutputLimit{}, nil)}
+	jd.jobs[job.ID] = job
+	job.output.Write([]byte("one\ntwo\n"))
+	job.output.stream(StreamStderr).Write([]byte("err\n"))
+	job.output.Write([]byte("three\n"))
+	job.output.Close()
+	const all = "one\ntwo\nerr\nthree\n"
+	for offset := int64(0); offset <= int64(len(all)); offset++ {
+		channel := make(chan OutputChunk, 16)
+		if err := jd.OutputChunks(context.Background(), job.ID, OutputOptions{ByteOffset: offset}, channel); err != nil {
+			t.Fatal(err)
+		}
+		// resuming after each chunk as a client does gives the rest exactly once
+		var got []byte
+		next := offset
+		for chunk := range channel {
+			if chunk.Offset != next {
+				t.Errorf("from %d: chunk at %d, want %d", offset, chunk.Offset, next)
+			}
+			got = append(got, chunk.Data...)
+			next = chunk.Offset + int64(len(chunk.Data))
+		}
+		if string(got) != all[offset:] {
+			t.Errorf("from %d: %q, want %q", offset, got, all[offset:])
+		}
+	}
+}
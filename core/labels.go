@@ -0,0 +1,276 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidLabel    = errors.New("invalid label")
+	ErrInvalidSelector = errors.New("invalid label selector")
+)
+
+var (
+	labelKeyPattern   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,251}[A-Za-z0-9])?$`)
+	labelValuePattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?)?$`)
+	setPattern        = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+)
+
+// keys are up to 253 characters of letters, digits, '.', '_', '-' and '/',
+// values up to 63 without '/', both start and end with a letter or digit
+func ValidateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("%w: key %q", ErrInvalidLabel, key)
+		}
+		if !labelValuePattern.MatchString(value) {
+			return fmt.Errorf("%w: value %q of %s", ErrInvalidLabel, value, key)
+		}
+	}
+	return nil
+}
+
+// how a requirement compares a label
+const (
+	opEquals    = "="
+	opNotEquals = "!="
+	opIn        = "in"
+	opNotIn     = "notin"
+	opExists    = "exists"
+	opNotExists = "!"
+)
+
+type requirement struct {
+	key    string
+	op     string
+	values []string
+}
+
+func (r requirement) matches(labels map[string]string) bool {
+	value, ok := labels[r.key]
+	switch r.op {
+	case opEquals, opIn:
+		return ok && contains(r.values, value)
+	case opNotEquals, opNotIn:
+		return !ok || !contains(r.values, value)
+	case opExists:
+		return ok
+	case opNotExists:
+		return !ok
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// label selector, a job matches when it satisfies every requirement
+// the empty selector matches every job
+type Selector []requirement
+
+// parse a comma separated list of requirements in the kubernetes syntax:
+// key=value, key==value, key!=value, key in (a,b), key notin (a,b), key and !key
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	for _, part := range splitRequirements(s) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			if strings.TrimSpace(s) == "" {
+				break
+			}
+			return nil, fmt.Errorf("%w %q: empty requirement", ErrInvalidSelector, s)
+		}
+		r, err := parseRequirement(part)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidSelector, s, err)
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+// split at the commas that are not inside a value set
+func splitRequirements(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func parseRequirement(s string) (requirement, error) {
+	var r requirement
+	switch {
+	case strings.HasPrefix(s, "!"):
+		r = requirement{key: strings.TrimSpace(s[1:]), op: opNotExists}
+	case setPattern.MatchString(s):
+		m := setPattern.FindStringSubmatch(s)
+		r = requirement{key: m[1], op: m[2]}
+		for _, v := range strings.Split(m[3], ",") {
+			r.values = append(r.values, strings.TrimSpace(v))
+		}
+	case strings.Contains(s, "!="):
+		key, value, _ := strings.Cut(s, "!=")
+		r = requirement{key: strings.TrimSpace(key), op: opNotEquals, values: []string{strings.TrimSpace(value)}}
+	case strings.Contains(s, "="):
+		key, value, _ := strings.Cut(s, "=")
+		value = strings.TrimPrefix(value, "=")
+		r = requirement{key: strings.TrimSpace(key), op: opEquals, values: []string{strings.TrimSpace(value)}}
+	default:
+		r = requirement{key: s, op: opExists}
+	}
+	if !labelKeyPattern.MatchString(r.key) {
+		return r, fmt.Errorf("invalid key %q", r.key)
+	}
+	for _, v := range r.values {
+		if !labelValuePattern.MatchString(v) {
+			return r, fmt.Errorf("invalid value %q", v)
+		}
+	}
+	return r, nil
+}
+
+func (sel Selector) Matches(labels map[string]string) bool {
+	for _, r := range sel {
+		if !r.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// job IDs by label key and value, kept under the dispatcher lock
+type labelIndex map[string]map[string]map[string]struct{}
+
+func (idx labelIndex) add(jobId string, labels map[string]string) {
+	for key, value := range labels {
+		if idx[key] == nil {
+			idx[key] = make(map[string]map[string]struct{})
+		}
+		if idx[key][value] == nil {
+			idx[key][value] = make(map[string]struct{})
+		}
+		idx[key][value][jobId] = struct{}{}
+	}
+}
+
+func (idx labelIndex) remove(jobId string, labels map[string]string) {
+	for key, value := range labels {
+		delete(idx[key][value], jobId)
+		if len(idx[key][value]) == 0 {
+			delete(idx[key], value)
+		}
+		if len(idx[key]) == 0 {
+			delete(idx, key)
+		}
+	}
+}
+
+// IDs of the jobs that can satisfy r, false when r needs a full scan (!=, notin, !key)
+func (idx labelIndex) candidates(r requirement) (map[string]struct{}, bool) {
+	var values []string
+	switch r.op {
+	case opEquals, opIn:
+		values = r.values
+	case opExists:
+		for value := range idx[r.key] {
+			values = append(values, value)
+		}
+	default:
+		return nil, false
+	}
+	if len(values) == 1 {
+		return idx[r.key][values[0]], true
+	}
+	ids := make(map[string]struct{})
+	for _, value := range values {
+		for id := range idx[r.key][value] {
+			ids[id] = struct{}{}
+		}
+	}
+	return ids, true
+}
+
+// jobs matching sel, narrowed down through the index when a requirement allows it, caller holds the lock
+func (jd *JobDispatcher) selectLocked(sel Selector) []*Job {
+	var best map[string]struct{}
+	indexed := false
+	for _, r := range sel {
+		if ids, ok := jd.labels.candidates(r); ok && (!indexed || len(ids) < len(best)) {
+			best, indexed = ids, true
+		}
+	}
+	var jobs []*Job
+	if indexed {
+		for id := range best {
+			if job := jd.jobs[id]; job != nil && sel.Matches(job.Labels) {
+				jobs = append(jobs, job)
+			}
+		}
+	} else {
+		for _, job := range jd.jobs {
+			if sel.Matches(job.Labels) {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+// statuses of the jobs matching sel
+func (jd *JobDispatcher) SelectJobs(sel Selector) []JobStatus {
+	jd.lock.RLock()
+	defer jd.lock.RUnlock()
+	jobs := jd.selectLocked(sel)
+	statuses := make([]JobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		statuses = append(statuses, *jd.JobStatuses[job.ID])
+	}
+	return statuses
+}
+
+// stop every running job matching sel and return their statuses
+// jobs that finish on their own in the meantime are skipped
+func (jd *JobDispatcher) StopJobs(sel Selector) ([]JobStatus, error) {
+	jd.lock.RLock()
+	var ids []string
+	for _, job := range jd.selectLocked(sel) {
+		if job.alive() {
+			ids = append(ids, job.ID)
+		}
+	}
+	jd.lock.RUnlock()
+	stopped := make([]JobStatus, 0, len(ids))
+	for _, id := range ids {
+		if err := jd.StopJob(id); errors.Is(err, ErrJobNotRunning) || errors.Is(err, ErrJobNotFound) {
+			continue
+		} else if err != nil {
+			return stopped, fmt.Errorf("stopping %s: %w", id, err)
+		}
+		if jobStatus, err := jd.QueryJob(id); err == nil {
+			stopped = append(stopped, jobStatus)
+		}
+	}
+	return stopped, nil
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Selector
+		err  bool
+	}{
+		{in: "", want: nil},
+		{in: "  ", want: nil},
+		{in: "env=prod", want: Selector{{key: "env", op: opEquals, values: []string{"prod"}}}},
+		{in: "env==prod", want: Selector{{key: "env", op: opEquals, values: []string{"prod"}}}},
+		{in: "env != prod", want: Selector{{key: "env", op: opNotEquals, values: []string{"prod"}}}},
+		{in: "env in (prod, staging)", want: Selector{{key: "env", op: opIn, values: []string{"prod", "staging"}}}},
+		{in: "env notin (dev)", want: Selector{{key: "env", op: opNotIn, values: []string{"dev"}}}},
+		{in: "gpu", want: Selector{{key: "gpu", op: opExists}}},
+		{in: "!gpu", want: Selector{{key: "gpu", op: opNotExists}}},
+		{in: "env in (a,b),tier=web,!gpu", want: Selector{
+			{key: "env", op: opIn, values: []string{"a", "b"}},
+			{key: "tier", op: opEquals, values: []string{"web"}},
+			{key: "gpu", op: opNotExists},
+		}},
+		{in: "env=", want: Selector{{key: "env", op: opEquals, values: []string{""}}}},
+		{in: "env=prod,", err: true},
+		{in: ",env=prod", err: true},
+		{in: "=prod", err: true},
+		{in: "env=pr/od", err: true},
+		{in: "env in (a,b/c)", err: true},
+		{in: "-env", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSelector(tt.in)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidSelector) {
+				t.Errorf("ParseSelector(%q) error = %v, want ErrInvalidSelector", tt.in, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSelector(%q) error = %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSelector(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"env": "prod", "tier": "web"}
+	tests := []struct {
+		sel  string
+		want bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env=dev", false},
+		{"env!=dev", true},
+		{"owner!=me", true},
+		{"env in (dev,prod)", true},
+		{"env notin (dev,prod)", false},
+		{"owner notin (me)", true},
+		{"tier", true},
+		{"owner", false},
+		{"!owner", true},
+		{"!tier", false},
+		{"env=prod,tier=db", false},
+	}
+	for _, tt := range tests {
+		sel, err := ParseSelector(tt.sel)
+		if err != nil {
+			t.Fatalf("ParseSelector(%q): %v", tt.sel, err)
+		}
+		if got := sel.Matches(labels); got != tt.want {
+			t.Errorf("%q matches %v = %v, want %v", tt.sel, labels, got, tt.want)
+		}
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	tests := []struct {
+		labels map[string]string
+		err    bool
+	}{
+		{labels: nil},
+		{labels: map[string]string{"app": "web", "example.com/team": "a-b_c.d", "empty": ""}},
+		{labels: map[string]string{"-app": "web"}, err: true},
+		{labels: map[string]string{"app": "a/b"}, err: true},
+		{labels: map[string]string{"app": "web-"}, err: true},
+	}
+	for _, tt := range tests {
+		err := ValidateLabels(tt.labels)
+		if got := errors.Is(err, ErrInvalidLabel); got != tt.err || (err != nil && !tt.err) {
+			t.Errorf("ValidateLabels(%v) = %v, want error %v", tt.labels, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// limits applied by the dispatcher to every job
+type Limits struct {
+	MaxConcurrent  int           // jobs running at once, further jobs wait in the created state, 0 means no limit
+	DefaultTimeout time.Duration // jobs still running after this long are killed, 0 means no limit
+	AllowedSignals []string      // signals callers may send to jobs with SignalJob
+	Output         OutputLimit   // jobs can ask for a lower limit or another policy
+	Artifacts      ArtifactLimit // per job
+	// user the jobs run as so they can't read the server's files, its keys
+	// included; needs a server running as root, empty runs them as the server's user
+	RunAs string
+}
+
+func (l Limits) Validate() error {
+	if l.MaxConcurrent < 0 {
+		return fmt.Errorf("max concurrent jobs must not be negative, got %d", l.MaxConcurrent)
+	}
+	if l.DefaultTimeout < 0 {
+		return fmt.Errorf("default job timeout must not be negative, got %s", l.DefaultTimeout)
+	}
+	if l.Output.Bytes < 0 {
+		return fmt.Errorf("output limit must not be negative, got %d", l.Output.Bytes)
+	}
+	if l.Output.Policy != "" {
+		if err := ValidateOutputPolicy(l.Output.Policy); err != nil {
+			return err
+		}
+	}
+	if err := l.Artifacts.Validate(); err != nil {
+		return err
+	}
+	for _, name := range l.AllowedSignals {
+		if _, err := ParseSignal(name); err != nil {
+			return fmt.Errorf("allowed signals: %w", err)
+		}
+	}
+	if l.RunAs != "" {
+		if _, _, err := lookupCredential(l.RunAs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// credentials and home directory of the user jobs run as, the home is where
+// jobs without a working directory run; nil when they run as the server's user
+func (jd *JobDispatcher) jobCredential() (*syscall.Credential, string, error) {
+	jd.lock.RLock()
+	name := jd.limits.RunAs
+	jd.lock.RUnlock()
+	if name == "" {
+		return nil, "", nil
+	}
+	return lookupCredential(name)
+}
+
+func lookupCredential(name string) (*syscall.Credential, string, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, "", fmt.Errorf("job user: %w", err)
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, "", fmt.Errorf("job user %s: uid %q", name, u.Uid)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, "", fmt.Errorf("job user %s: gid %q", name, u.Gid)
+	}
+	cred := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	gids, err := u.GroupIds()
+	if err != nil {
+		return nil, "", fmt.Errorf("job user %s: %w", name, err)
+	}
+	for _, g := range gids {
+		if id, err := strconv.ParseUint(g, 10, 32); err == nil {
+			cred.Groups = append(cred.Groups, uint32(id))
+		}
+	}
+	home := u.HomeDir
+	if info, err := os.Stat(home); err != nil || !info.IsDir() {
+		// e.g. nobody, whose home doesn't exist
+		home = "/"
+	}
+	return cred, home, nil
+}
+
+// set the limits, call before any job is started
+func (jd *JobDispatcher) SetLimits(l Limits) {
+	jd.lock.Lock()
+	defer jd.lock.Unlock()
+	jd.limits = l
+	jd.slots = nil
+	if l.MaxConcurrent > 0 {
+		jd.slots = make(chan struct{}, l.MaxConcurrent)
+	}
+}
+
+// block until the job may run, false if the queue was cancelled by a stopping drain
+func (jd *JobDispatcher) acquireSlot(job *Job) bool {
+	if jd.slots == nil {
+		return true
+	}
+	select {
+	case jd.slots <- struct{}{}:
+		return true
+	default:
+	}
+	// only a job that actually waits counts as queued for its quotas
+	jd.setWaiting(job, true)
+	defer jd.setWaiting(job, false)
+	select {
+	case jd.slots <- struct{}{}:
+		return true
+	case <-jd.cancelQueued:
+		return false
+	case <-job.stop:
+		return false
+	}
+}
+
+func (jd *JobDispatcher) setWaiting(job *Job, waiting bool) {
+	jd.lock.Lock()
+	defer jd.lock.Unlock()
+	job.waiting = waiting
+}
+
+func (jd *JobDispatcher) releaseSlot() {
+	if jd.slots != nil {
+		<-jd.slots
+	}
+}
+
+// the output limit of a job asking for requested, the lower of the two limits
+// and the job's policy if it has one, caller holds the lock
+func (jd *JobDispatcher) outputLimitLocked(requested OutputLimit) OutputLimit {
+	limit := jd.limits.Output
+	if requested.Bytes > 0 && (limit.Bytes == 0 || requested.Bytes < limit.Bytes) {
+		limit.Bytes = requested.Bytes
+	}
+	if requested.Policy != "" {
+		limit.Policy = requested.Policy
+	}
+	if limit.Policy == "" {
+		limit.Policy = OutputKeepHead
+	}
+	return limit
+}
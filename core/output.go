@@ -0,0 +1,500 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// one line of job output, without the newline
+type OutputLine struct {
+	Line    int       // position in the job's output, starting at 0
+	Offset  int64     // of the line's first byte in the job's output
+	Time    time.Time // when the line was captured
+	Stream  string    // where the line came from, see StreamStdout
+	Text    string
+	Newline bool // false for a last line without newline and for pieces of a split long line
+	// set on a notice that this many bytes right before Offset were dropped,
+	// the notice itself takes up no room in the output
+	Dropped int64
+}
+
+// the line as it was written
+func (l OutputLine) Bytes() []byte {
+	if !l.Newline {
+		return []byte(l.Text)
+	}
+	return append([]byte(l.Text), '\n')
+}
+
+// bytes the line takes up in the output
+func (l OutputLine) size() int64 {
+	if l.Dropped > 0 {
+		return 0
+	}
+	if l.Newline {
+		return int64(len(l.Text)) + 1
+	}
+	return int64(len(l.Text))
+}
+
+// raw bytes of job output from one stream, captured at the same time
+type OutputChunk struct {
+	Offset int64 // of Data[0] in the job's output, the next chunk starts at Offset+len(Data) unless output was dropped
+	Line   int   // the line Data starts in
+	Time   time.Time
+	Stream string
+	Data   []byte
+	// set on a notice that this many bytes right before Offset were dropped,
+	// Data is the notice and the next chunk starts at Offset
+	Dropped int64
+}
+
+// names of the streams output is captured from
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+	StreamSystem = "system" // messages of the dispatcher, like errors and truncation markers
+)
+
+// which part of a job's output Output and OutputChunks send
+type OutputOptions struct {
+	Offset     int       // first line to send
+	ByteOffset int64     // OutputChunks only: first byte to send, a later one than the start of Offset wins
+	Tail       int       // only the last Tail lines present when the call starts, 0 means all
+	Since      time.Time // skip lines captured before this time, zero means no filter
+	Follow     bool      // after the existing lines, wait for new ones until the job finishes
+}
+
+// Since from an RFC 3339 time or a duration before now
+func ParseSince(v string) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since %q, expected a duration or an RFC 3339 time", v)
+	}
+	return t, nil
+}
+
+// longer lines are split, so a process writing without newlines can't build
+// up an unbounded line
+const maxLineBytes = 64 << 10
+
+// chunks are made of several lines up to this size
+const maxChunkBytes = 32 << 10
+
+// what happens once a job's output reaches its limit
+const (
+	OutputKeepHead = "head" // keep the first lines, discard the rest
+	OutputKeepTail = "tail" // keep the last lines, dropping the oldest ones
+	OutputKill     = "kill" // keep the first lines and kill the job
+)
+
+func ValidateOutputPolicy(policy string) error {
+	switch policy {
+	case OutputKeepHead, OutputKeepTail, OutputKill:
+		return nil
+	}
+	return fmt.Errorf("%w: output policy %q, expected %s, %s or %s", ErrInvalidJob, policy, OutputKeepHead, OutputKeepTail, OutputKill)
+}
+
+// cap on the output kept for a job, counting every line with its newline and
+// lineOverhead
+type OutputLimit struct {
+	Bytes  int64 // 0 means no limit
+	Policy string
+}
+
+// memory a kept line takes up besides its text, counted against the limit so
+// many short lines can't use much more than it
+const lineOverhead = 64
+
+// output of a job, written by the process and read by any number of streams
+// complete lines are kept with their capture time, readers wait on changed;
+// output without a newline is added as a piece of a line after partialDelay
+type outputLog struct {
+	mu      sync.Mutex
+	lines   []OutputLine      // lines[0] has Line first, entries are never modified once added
+	first   int               // lines dropped from the front by the tail policy
+	stale   int               // dropped lines still in the backing array of lines
+	next    int64             // offset of the next line, dropped lines keep counting
+	partial map[string][]byte // bytes after the last newline, by stream
+	flush   *time.Timer       // adds the partial lines, running while there are any
+	closed  bool              // the process exited, no more lines will come
+	changed chan struct{}     // closed and replaced whenever lines are added or the log is closed
+	redact  []string          // replaced by redacted in every line, see redactValues
+	overlap int               // most bytes a split line holds back so a value across the split is still hidden
+	limit   OutputLimit
+	kept    int64     // bytes of the kept lines
+	dropped time.Time // when the tail policy last dropped a line
+	// the limit was reached and output was discarded; with OutputKill exceeded
+	// is called once, it must not block
+	truncated bool
+	exceeded  func()
+}
+
+const redacted = "[redacted]"
+
+// how long output without a newline waits for the rest of its line, so
+// prompts and progress bars reach readers while the process runs
+const partialDelay = 100 * time.Millisecond
+
+func newOutputLog(limit OutputLimit, exceeded func()) *outputLog {
+	return &outputLog{changed: make(chan struct{}), partial: make(map[string][]byte), limit: limit, exceeded: exceeded}
+}
+
+// io.Writer for the process's standard output
+func (o *outputLog) Write(p []byte) (int, error) {
+	return o.write(StreamStdout, p)
+}
+
+type streamWriter struct {
+	o      *outputLog
+	stream string
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	return w.o.write(w.stream, p)
+}
+
+// io.Writer for another stream of the process, lines of each stream are
+// assembled on their own
+func (o *outputLog) stream(name string) io.Writer {
+	return streamWriter{o: o, stream: name}
+}
+
+func (o *outputLog) write(stream string, p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	data := append(o.partial[stream], p...)
+	added := false
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		added = o.addLocked(stream, string(data[:i]), true, now, true) || added
+		data = data[i+1:]
+	}
+	// a long line, or one longer than the whole limit, is split so it can't
+	// grow without bound; its end stays back in case it starts a secret
+	if len(data) >= maxLineBytes || (o.limit.Bytes > 0 && int64(len(data)) >= o.limit.Bytes) {
+		var piece bool
+		data, piece = o.addPieceLocked(stream, data, min(o.overlap, len(data)/2), now)
+		added = piece || added
+	}
+	o.partial[stream] = append([]byte(nil), data...)
+	if len(data) > 0 && o.flush == nil {
+		o.flush = time.AfterFunc(partialDelay, o.flushPartial)
+	}
+	if added {
+		o.notify()
+	}
+	return len(p), nil
+}
+
+// add data without newline as a piece of a line, holding back up to hold
+// bytes of its end that could start a secret; returns what was held back,
+// caller holds the lock
+func (o *outputLog) addPieceLocked(stream string, data []byte, hold int, now time.Time) ([]byte, bool) {
+	text := o.redactText(string(data))
+	cut := len(text) - min(hold, o.secretStartLocked(text))
+	if cut == 0 {
+		return data, false
+	}
+	return []byte(text[cut:]), o.addLocked(stream, text[:cut], false, now, true)
+}
+
+// add what the process wrote without newline since partialDelay, the rest
+// of its line follows as another piece
+func (o *outputLog) flushPartial() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.flush = nil
+	if o.closed {
+		return
+	}
+	now := time.Now()
+	added := false
+	for _, stream := range []string{StreamStdout, StreamStderr} {
+		if len(o.partial[stream]) > 0 {
+			var piece bool
+			o.partial[stream], piece = o.addPieceLocked(stream, o.partial[stream], o.overlap, now)
+			added = piece || added
+		}
+	}
+	if added {
+		o.notify()
+	}
+}
+
+// add the unterminated lines of the process as they are, caller holds the lock
+func (o *outputLog) flushLocked(now time.Time) {
+	for _, stream := range []string{StreamStdout, StreamStderr} {
+		if len(o.partial[stream]) > 0 {
+			o.addLocked(stream, string(o.partial[stream]), false, now, true)
+		}
+		delete(o.partial, stream)
+	}
+}
+
+// add a line of text from the dispatcher itself, ending any unterminated line
+// of the process first; it is kept even past the output limit
+func (o *outputLog) writeLine(text string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	o.flushLocked(now)
+	o.addLocked(StreamSystem, text, true, now, false)
+	o.notify()
+}
+
+// redact and append a line, applying the limit when capped; false when the
+// line was discarded, caller holds the lock
+func (o *outputLog) addLocked(stream, text string, newline bool, now time.Time, capped bool) bool {
+	text = o.redactText(text)
+	line := OutputLine{Time: now, Stream: stream, Text: text, Newline: newline}
+	if line.size() == 0 {
+		return false
+	}
+	// bytes cut from the front of the line, they keep their offsets
+	var cut int64
+	if capped && o.limit.Bytes > 0 {
+		switch o.limit.Policy {
+		case OutputKeepTail:
+			if room := max(o.limit.Bytes-lineOverhead, 1); line.size() > room {
+				cut = line.size() - room
+				line.Text = line.Text[cut:]
+				o.truncated = true
+			}
+			for len(o.lines) > 0 && o.usedLocked()+line.size()+lineOverhead > o.limit.Bytes {
+				o.dropLocked(now)
+			}
+		default:
+			if o.truncated {
+				o.next += line.size()
+				return false
+			}
+			if o.usedLocked()+line.size()+lineOverhead > o.limit.Bytes {
+				o.truncated = true
+				marker := fmt.Sprintf("[output truncated: limit of %d bytes reached]", o.limit.Bytes)
+				if o.limit.Policy == OutputKill {
+					marker = fmt.Sprintf("[output limit of %d bytes exceeded, killing the job]", o.limit.Bytes)
+					if o.exceeded != nil {
+						o.exceeded()
+					}
+				}
+				o.addLocked(StreamSystem, marker, true, now, false)
+				o.next += line.size()
+				return true
+			}
+		}
+	}
+	line.Line = o.first + len(o.lines)
+	line.Offset = o.next + cut
+	o.lines = append(o.lines, line)
+	o.kept += line.size()
+	o.next += cut + line.size()
+	return true
+}
+
+// bytes counted against the limit, caller holds the lock
+func (o *outputLog) usedLocked() int64 {
+	return o.kept + int64(len(o.lines))*lineOverhead
+}
+
+// drop the oldest line for the tail policy, caller holds the lock
+func (o *outputLog) dropLocked(now time.Time) {
+	o.kept -= o.lines[0].size()
+	o.lines = o.lines[1:]
+	o.first++
+	o.dropped = now
+	o.truncated = true
+	// readers may still hold the old array, so the kept lines are copied
+	// rather than the dropped ones cleared
+	if o.stale++; o.stale > max(len(o.lines), 16) {
+		o.lines = append(make([]OutputLine, 0, 2*len(o.lines)), o.lines...)
+		o.stale = 0
+	}
+}
+
+// hide values in every line written from now on; output is redacted a line
+// at a time, so each line of a multi-line value is hidden on its own
+func (o *outputLog) redactValues(values [][]byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, value := range values {
+		for _, line := range strings.Split(string(value), "\n") {
+			if line = strings.TrimSuffix(line, "\r"); line != "" {
+				o.redact = append(o.redact, line)
+				o.overlap = max(o.overlap, len(line)-1)
+			}
+		}
+	}
+	// longest first, so a value containing another is hidden as a whole
+	sort.Slice(o.redact, func(i, j int) bool { return len(o.redact[i]) > len(o.redact[j]) })
+}
+
+// length of the longest end of text that is the start of a redacted value,
+// caller holds the lock
+func (o *outputLog) secretStartLocked(text string) int {
+	longest := 0
+	for _, value := range o.redact {
+		for n := min(len(value)-1, len(text)); n > longest; n-- {
+			if strings.HasSuffix(text, value[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// caller holds the lock
+func (o *outputLog) redactText(text string) string {
+	for _, value := range o.redact {
+		text = strings.ReplaceAll(text, value, redacted)
+	}
+	return text
+}
+
+// mark the end of the output, a last line without newline is kept as is
+func (o *outputLog) Close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return
+	}
+	o.flushLocked(time.Now())
+	if o.flush != nil {
+		o.flush.Stop()
+		o.flush = nil
+	}
+	o.closed = true
+	o.notify()
+}
+
+// caller holds the lock
+func (o *outputLog) notify() {
+	close(o.changed)
+	o.changed = make(chan struct{})
+}
+
+// lines from index from on, whether the log is closed, and a channel closed on the next change
+// when lines from on were dropped, a marker line numbered just before the first kept one comes first
+func (o *outputLog) read(from int) ([]OutputLine, bool, <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	end := o.first + len(o.lines)
+	if from > end {
+		from = end
+	}
+	if from < o.first {
+		marker := OutputLine{
+			Line:    o.first - 1,
+			Offset:  o.lines[0].Offset,
+			Time:    o.dropped,
+			Stream:  StreamSystem,
+			Text:    fmt.Sprintf("[output truncated: %d earlier lines dropped]", o.first),
+			Newline: true,
+		}
+		return append([]OutputLine{marker}, o.lines...), o.closed, o.changed
+	}
+	return o.lines[from-o.first : len(o.lines) : len(o.lines)], o.closed, o.changed
+}
+
+// like read, but from byte offset on: the line holding it comes first, cut to
+// start at offset; where output was dropped a notice with Dropped set comes
+// before the next kept line
+func (o *outputLog) readAt(offset int64) ([]OutputLine, bool, <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	i := sort.Search(len(o.lines), func(i int) bool { return o.lines[i].Offset+o.lines[i].size() > offset })
+	lines := make([]OutputLine, 0, len(o.lines)-i)
+	for _, line := range o.lines[i:] {
+		if line.Offset < offset {
+			line.Text = line.Text[min(offset-line.Offset, int64(len(line.Text))):]
+			line.Offset = offset
+		} else if line.Offset > offset {
+			lines = append(lines, OutputLine{
+				Line:    line.Line,
+				Offset:  line.Offset,
+				Time:    line.Time,
+				Stream:  StreamSystem,
+				Text:    fmt.Sprintf("[output truncated: %d bytes dropped]", line.Offset-offset),
+				Newline: true,
+				Dropped: line.Offset - offset,
+			})
+		}
+		lines = append(lines, line)
+		offset = line.Offset + line.size()
+	}
+	return lines, o.closed, o.changed
+}
+
+// offset of line n, or 0 when it was dropped
+func (o *outputLog) lineOffset(n int) int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// like read, a dropped line starts from the front so the gap is reported
+	if i := n - o.first; i < 0 {
+		return 0
+	} else if i < len(o.lines) {
+		return o.lines[i].Offset
+	}
+	return o.next
+}
+
+// number of lines written, including the dropped ones
+func (o *outputLog) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.first + len(o.lines)
+}
+
+// whether output was discarded because of the limit
+func (o *outputLog) isTruncated() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.truncated
+}
+
+// text of the last n lines
+func (o *outputLog) tail(n int) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	lines := o.lines[max(0, len(o.lines)-n):]
+	text := make([]string, 0, len(lines))
+	for _, line := range lines {
+		text = append(text, line.Text)
+	}
+	return text
+}
+
+// bytes of output kept
+func (o *outputLog) size() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.kept
+}
+
+// the whole output as text
+func (o *outputLog) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var sb strings.Builder
+	for _, line := range o.lines {
+		sb.Write(line.Bytes())
+	}
+	for _, stream := range []string{StreamStdout, StreamStderr} {
+		sb.WriteString(o.redactText(string(o.partial[stream])))
+	}
+	return sb.String()
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func texts(lines []OutputLine) []string {
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		out = append(out, line.Text)
+	}
+	return out
+}
+
+func TestOutputLimit(t *testing.T) {
+	// each line takes 3 bytes and lineOverhead, three of them fit
+	const limit = 3*(3+lineOverhead) + 10
+	tests := []struct {
+		name      string
+		limit     OutputLimit
+		want      []string // as read from the start
+		first     int
+		next      int64
+		truncated bool
+		killed    bool
+	}{
+		{
+			name: "no limit",
+			want: []string{"l1", "l2", "l3", "l4", "l5"},
+			next: 15,
+		},
+		{
+			name:      "head",
+			limit:     OutputLimit{Bytes: limit, Policy: OutputKeepHead},
+			want:      []string{"l1", "l2", "l3", "[output truncated: limit of 211 bytes reached]"},
+			next:      15 + 47,
+			truncated: true,
+		},
+		{
+			name:      "kill",
+			limit:     OutputLimit{Bytes: limit, Policy: OutputKill},
+			want:      []string{"l1", "l2", "l3", "[output limit of 211 bytes exceeded, killing the job]"},
+			next:      15 + 54,
+			truncated: true,
+			killed:    true,
+		},
+		{
+			name:      "tail",
+			limit:     OutputLimit{Bytes: limit, Policy: OutputKeepTail},
+			want:      []string{"[output truncated: 2 earlier lines dropped]", "l3", "l4", "l5"},
+			first:     2,
+			next:      15,
+			truncated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			killed := 0
+			o := newOutputLog(tt.limit, func() { killed++ })
+			o.Write([]byte("l1\nl2\nl3\n"))
+			o.Write([]byte("l4\nl5\n"))
+			o.Close()
+			lines, closed, _ := o.read(0)
+			if !closed {
+				t.Error("read after Close: not closed")
+			}
+			if got := texts(lines); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+			if o.first != tt.first || o.next != tt.next || o.truncated != tt.truncated {
+				t.Errorf("first, next, truncated = %d, %d, %v, want %d, %d, %v", o.first, o.next, o.truncated, tt.first, tt.next, tt.truncated)
+			}
+			// only the tail policy has no marker past the limit
+			if tt.limit.Policy == OutputKeepTail && o.usedLocked() > tt.limit.Bytes {
+				t.Errorf("%d bytes used, limit %d", o.usedLocked(), tt.limit.Bytes)
+			}
+			if (killed == 1) != tt.killed || killed > 1 {
+				t.Errorf("exceeded called %d times, killed %v", killed, tt.killed)
+			}
+		})
+	}
+}
+
+func TestOutputTailCutsLongLine(t *testing.T) {
+	// room for 100-lineOverhead bytes of the line, its newline included
+	o := newOutputLog(OutputLimit{Bytes: 100, Policy: OutputKeepTail}, nil)
+	o.Write([]byte("head\n" + strings.Repeat("x", 199) + "y\n"))
+	lines, _, _ := o.readAt(0)
+	want := []OutputLine{
+		{Line: 1, Offset: 5 + 165, Stream: StreamSystem, Text: "[output truncated: 170 bytes dropped]", Newline: true, Dropped: 170},
+		{Line: 1, Offset: 5 + 165, Stream: StreamStdout, Text: strings.Repeat("x", 34) + "y", Newline: true},
+	}
+	for i := range lines {
+		lines[i].Time = time.Time{}
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("readAt(0) = %+v, want %+v", lines, want)
+	}
+	if o.next != 5+201 {
+		t.Errorf("next = %d, want %d", o.next, 5+201)
+	}
+}
+
+func TestOutputReadAt(t *testing.T) {
+	const limit = 3*(3+lineOverhead) + 10
+	o := newOutputLog(OutputLimit{Bytes: limit, Policy: OutputKeepTail}, nil)
+	o.Write([]byte("l1\nl2\nl3\nl4\nl5\n"))
+	tests := []struct {
+		offset  int64
+		want    []string
+		dropped int64
+	}{
+		{offset: 0, want: []string{"[output truncated: 6 bytes dropped]", "l3", "l4", "l5"}, dropped: 6},
+		{offset: 4, want: []string{"[output truncated: 2 bytes dropped]", "l3", "l4", "l5"}, dropped: 2},
+		{offset: 6, want: []string{"l3", "l4", "l5"}},
+		{offset: 7, want: []string{"3", "l4", "l5"}},
+		{offset: 9, want: []string{"l4", "l5"}},
+		{offset: 15, want: []string{}},
+		{offset: 20, want: []string{}},
+	}
+	for _, tt := range tests {
+		lines, _, _ := o.readAt(tt.offset)
+		if got := texts(lines); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("readAt(%d) = %q, want %q", tt.offset, got, tt.want)
+		}
+		if len(lines) > 0 && lines[0].Dropped != tt.dropped {
+			t.Errorf("readAt(%d) dropped = %d, want %d", tt.offset, lines[0].Dropped, tt.dropped)
+		}
+		// the offsets continue where the notice says
+		offset := tt.offset
+		for _, line := range lines {
+			if line.Offset != offset+line.Dropped {
+				t.Errorf("readAt(%d): line %q at %d, want %d", tt.offset, line.Text, line.Offset, offset+line.Dropped)
+			}
+			offset = line.Offset + line.size()
+		}
+	}
+}
+
+func TestOutputRedactsAcrossSplit(t *testing.T) {
+	o := newOutputLog(OutputLimit{}, nil)
+	o.redactValues([][]byte{[]byte("SECRET")})
+	o.Write([]byte(strings.Repeat("x", maxLineBytes-3) + "SEC"))
+	o.Write([]byte("RET\n"))
+	o.Close()
+	out := o.String()
+	if strings.Contains(out, "SEC") || strings.Contains(out, "RET") {
+		t.Errorf("secret split over lines is not redacted: %q", out[len(out)-30:])
+	}
+	if !strings.HasSuffix(out, "x"+redacted+"\n") {
+		t.Errorf("output ends in %q, want %q", out[len(out)-30:], "x"+redacted+"\n")
+	}
+}
+
+func TestOutputFlushesPartialLine(t *testing.T) {
+	o := newOutputLog(OutputLimit{}, nil)
+	_, _, changed := o.read(0)
+	o.Write([]byte("Password: "))
+	select {
+	case <-changed:
+	case <-time.After(10 * partialDelay):
+		t.Fatal("output without newline was not flushed")
+	}
+	lines, _, _ := o.read(0)
+	if len(lines) != 1 || lines[0].Text != "Password: " || lines[0].Newline {
+		t.Fatalf("lines = %+v, want the prompt without newline", lines)
+	}
+	o.Write([]byte("ok\n"))
+	o.Close()
+	if got := o.String(); got != "Password: ok\n" {
+		t.Errorf("output = %q, want %q", got, "Password: ok\n")
+	}
+}
+
+func TestOutputFlushesPromptWithSecrets(t *testing.T) {
+	o := newOutputLog(OutputLimit{}, nil)
+	o.redactValues([][]byte{[]byte("a-secret-longer-than-the-prompt")})
+	tests := []struct {
+		write, want string
+	}{
+		// shorter than the secret but can't be the start of it
+		{write: "Password: ", want: "Password: "},
+		// the end could be, it waits for the rest
+		{write: "token a-sec", want: "token "},
+	}
+	for _, tt := range tests {
+		before, _, changed := o.read(0)
+		o.Write([]byte(tt.write))
+		select {
+		case <-changed:
+		case <-time.After(10 * partialDelay):
+			t.Fatalf("%q was not flushed", tt.write)
+		}
+		lines, _, _ := o.read(len(before))
+		if got := texts(lines); len(got) != 1 || got[0] != tt.want {
+			t.Errorf("after writing %q: lines = %q, want %q", tt.write, got, tt.want)
+		}
+	}
+	o.Write([]byte("ret-longer-than-the-prompt\n"))
+	o.Close()
+	if got, want := o.String(), "Password: token "+redacted+"\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
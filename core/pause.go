@@ -0,0 +1,132 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrJobNotPaused     = errors.New("job is not paused")
+	ErrPauseUnsupported = errors.New("pausing jobs requires a cgroup root")
+	ErrJobFreezing      = errors.New("job is already being paused or resumed")
+)
+
+// freeze every process of a running job, its timeout stops counting until Resume
+func (jd *JobDispatcher) PauseJob(jobId string) error {
+	job, cgroup, err := jd.startFreezing(jobId, Running, ErrJobNotRunning)
+	if err != nil {
+		return err
+	}
+	err = setFrozen(cgroup, true)
+	if err != nil {
+		// don't leave the job half frozen
+		setFrozen(cgroup, false)
+	}
+	jd.lock.Lock()
+	job.freezing = false
+	running := job.State == Running
+	if err == nil && running {
+		if job.timeout != nil {
+			job.timeout.pause()
+		}
+		jd.setState(job, Paused)
+	}
+	jd.lock.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to pause the job: %w", err)
+	}
+	if !running {
+		// it finished or was stopped while it was being frozen
+		setFrozen(cgroup, false)
+		return ErrJobNotRunning
+	}
+	slog.Info("job paused", "job_id", jobId)
+	return nil
+}
+
+// thaw a paused job
+func (jd *JobDispatcher) ResumeJob(jobId string) error {
+	job, cgroup, err := jd.startFreezing(jobId, Paused, ErrJobNotPaused)
+	if err != nil {
+		return err
+	}
+	err = setFrozen(cgroup, false)
+	jd.lock.Lock()
+	job.freezing = false
+	paused := job.State == Paused
+	if err == nil && paused {
+		if job.timeout != nil {
+			job.timeout.resume()
+		}
+		jd.setState(job, Running)
+	}
+	jd.lock.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to resume the job: %w", err)
+	}
+	if !paused {
+		return ErrJobNotPaused
+	}
+	slog.Info("job resumed", "job_id", jobId)
+	return nil
+}
+
+// mark a job in state as being frozen or thawed and return its cgroup; the
+// freezer is then used without the lock, it can take up to freezeTimeout
+func (jd *JobDispatcher) startFreezing(jobId, state string, wrongState error) (*Job, string, error) {
+	if err := validateJobId(jobId); err != nil {
+		return nil, "", err
+	}
+	jd.lock.Lock()
+	defer jd.lock.Unlock()
+	job := jd.jobs[jobId]
+	if job == nil {
+		return nil, "", ErrJobNotFound
+	}
+	if job.State != state {
+		return nil, "", wrongState
+	}
+	if job.cgroup == "" {
+		return nil, "", ErrPauseUnsupported
+	}
+	if job.freezing {
+		return nil, "", ErrJobFreezing
+	}
+	job.freezing = true
+	return job, job.cgroup, nil
+}
+
+// a timeout that does not count the time a job spends paused
+// used under the dispatcher lock
+type jobTimer struct {
+	remaining time.Duration
+	started   time.Time
+	timer     *time.Timer
+	f         func()
+}
+
+func newJobTimer(d time.Duration, f func()) *jobTimer {
+	t := &jobTimer{remaining: d, f: f}
+	t.resume()
+	return t
+}
+
+func (t *jobTimer) pause() {
+	if t.timer != nil && t.timer.Stop() {
+		t.remaining -= time.Since(t.started)
+	}
+	t.timer = nil
+}
+
+func (t *jobTimer) resume() {
+	t.started = time.Now()
+	t.timer = time.AfterFunc(t.remaining, t.f)
+}
+
+func (t *jobTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
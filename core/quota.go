@@ -0,0 +1,293 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// CPU time counts against a quota for this long after the job finished
+const cpuWindow = 24 * time.Hour
+
+// limits on what the jobs of a user or group may use, 0 means no limit
+type Quota struct {
+	MaxRunning       int   // jobs running at once, further jobs wait in the created state
+	MaxQueued        int   // jobs waiting for a running slot, further starts are rejected
+	CPUSecondsPerDay int64 // user and system time of the jobs finished in the last 24 hours
+	// output kept for the user's jobs until they are deleted; a new job's output
+	// limit is lowered to what is left, see outputQuotaLocked
+	OutputBytes int64
+}
+
+func (q Quota) Validate() error {
+	if q.MaxRunning < 0 || q.MaxQueued < 0 || q.CPUSecondsPerDay < 0 || q.OutputBytes < 0 {
+		return errors.New("quota limits must not be negative")
+	}
+	return nil
+}
+
+func (q Quota) unlimited() bool {
+	return q == Quota{}
+}
+
+// quotas applied when jobs are admitted
+// a user gets its entry in Users or else Default; a group's quota is shared by
+// all of its members, on top of their own
+type Quotas struct {
+	Default Quota
+	Users   map[string]Quota
+	Groups  map[string]Quota
+}
+
+func (q Quotas) Validate() error {
+	if err := q.Default.Validate(); err != nil {
+		return fmt.Errorf("default quota: %w", err)
+	}
+	for name, quota := range q.Users {
+		if err := quota.Validate(); err != nil {
+			return fmt.Errorf("quota of user %q: %w", name, err)
+		}
+	}
+	for name, quota := range q.Groups {
+		if err := quota.Validate(); err != nil {
+			return fmt.Errorf("quota of group %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// a user or group a quota applies to
+type QuotaScope struct {
+	User  string // set for a user's own quota
+	Group string // set for a group quota
+}
+
+func (s QuotaScope) String() string {
+	if s.Group != "" {
+		return fmt.Sprintf("group %q", s.Group)
+	}
+	if s.User == "" {
+		return "the anonymous user"
+	}
+	return fmt.Sprintf("user %q", s.User)
+}
+
+// what the jobs in a scope use now
+type QuotaUsage struct {
+	Scope       QuotaScope
+	Limit       Quota
+	Running     int
+	Queued      int     // waiting for a running slot of the quota or the server
+	CPUSeconds  float64 // over the last 24 hours
+	OutputBytes int64
+}
+
+// CPU time of a finished job
+type cpuRecord struct {
+	user    string
+	groups  []string
+	at      time.Time
+	seconds float64
+}
+
+// quotas are charged to the verified caller that started a job, a claimed user
+// proves nothing; anonymous callers share the quota of the empty user
+func (j *Job) quotaUser() string {
+	return j.Caller
+}
+
+// set the quotas, groups returns the groups a user belongs to and may be nil
+// when there are no group quotas; call before any job is started
+func (jd *JobDispatcher) SetQuotas(q Quotas, groups func(user string) []string) {
+	jd.lock.Lock()
+	defer jd.lock.Unlock()
+	jd.quotas = q
+	jd.userGroups = groups
+}
+
+// the groups of user that have a quota
+func (jd *JobDispatcher) quotaGroups(user string) []string {
+	jd.lock.RLock()
+	quotas, lookup := jd.quotas.Groups, jd.userGroups
+	jd.lock.RUnlock()
+	if len(quotas) == 0 || lookup == nil {
+		return nil
+	}
+	var groups []string
+	for _, group := range lookup(user) {
+		if _, ok := quotas[group]; ok {
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// the scopes whose quotas apply to a user with the given groups
+func quotaScopes(user string, groups []string) []QuotaScope {
+	scopes := []QuotaScope{{User: user}}
+	for _, group := range groups {
+		scopes = append(scopes, QuotaScope{Group: group})
+	}
+	return scopes
+}
+
+func (jd *JobDispatcher) limitLocked(scope QuotaScope) Quota {
+	if scope.Group != "" {
+		return jd.quotas.Groups[scope.Group]
+	}
+	if q, ok := jd.quotas.Users[scope.User]; ok {
+		return q
+	}
+	return jd.quotas.Default
+}
+
+func (s QuotaScope) covers(user string, groups []string) bool {
+	if s.Group == "" {
+		return s.User == user
+	}
+	for _, group := range groups {
+		if group == s.Group {
+			return true
+		}
+	}
+	return false
+}
+
+// add up what the jobs in scope use, caller holds the lock
+func (jd *JobDispatcher) usageLocked(scope QuotaScope) QuotaUsage {
+	usage := QuotaUsage{Scope: scope, Limit: jd.limitLocked(scope)}
+	for _, job := range jd.jobs {
+		if !scope.covers(job.quotaUser(), job.groups) {
+			continue
+		}
+		switch {
+		case job.State == Finished:
+		case job.waiting:
+			usage.Queued++
+		case job.quotaSlot:
+			usage.Running++
+		}
+		usage.OutputBytes += job.output.size()
+	}
+	jd.pruneCPULocked()
+	for _, rec := range jd.cpuLog {
+		if scope.covers(rec.user, rec.groups) {
+			usage.CPUSeconds += rec.seconds
+		}
+	}
+	return usage
+}
+
+// drop the CPU records that left the window, caller holds the write lock
+func (jd *JobDispatcher) pruneCPULocked() {
+	cutoff := time.Now().Add(-cpuWindow)
+	i := 0
+	for i < len(jd.cpuLog) && jd.cpuLog[i].at.Before(cutoff) {
+		i++
+	}
+	jd.cpuLog = jd.cpuLog[i:]
+}
+
+// reject the job when a quota of its user or groups is used up, caller holds the write lock
+func (jd *JobDispatcher) admitQuotaLocked(job *Job) error {
+	for _, scope := range quotaScopes(job.quotaUser(), job.groups) {
+		limit := jd.limitLocked(scope)
+		if limit.unlimited() {
+			continue
+		}
+		usage := jd.usageLocked(scope)
+		switch {
+		case limit.MaxQueued > 0 && usage.Queued >= limit.MaxQueued:
+			return fmt.Errorf("%w: %s has %d queued jobs, the limit is %d", ErrQuotaExceeded, scope, usage.Queued, limit.MaxQueued)
+		case limit.CPUSecondsPerDay > 0 && usage.CPUSeconds >= float64(limit.CPUSecondsPerDay):
+			return fmt.Errorf("%w: %s used %.0f CPU seconds in the last 24 hours, the limit is %d", ErrQuotaExceeded, scope, usage.CPUSeconds, limit.CPUSecondsPerDay)
+		case limit.OutputBytes > 0 && usage.OutputBytes >= limit.OutputBytes:
+			return fmt.Errorf("%w: %s has %d bytes of job output, the limit is %d", ErrQuotaExceeded, scope, usage.OutputBytes, limit.OutputBytes)
+		}
+	}
+	return nil
+}
+
+// output the job may still keep under the quotas of its user and groups, 0
+// when no quota limits it; jobs running at the same time may each use all of
+// it, caller holds the write lock
+func (jd *JobDispatcher) outputQuotaLocked(job *Job) int64 {
+	var left int64
+	for _, scope := range quotaScopes(job.quotaUser(), job.groups) {
+		limit := jd.limitLocked(scope)
+		if limit.OutputBytes == 0 {
+			continue
+		}
+		if rest := max(limit.OutputBytes-jd.usageLocked(scope).OutputBytes, 1); left == 0 || rest < left {
+			left = rest
+		}
+	}
+	return left
+}
+
+// jobs in scope holding a running slot of its quota, also those still waiting
+// for a slot of the server; caller holds the lock
+func (jd *JobDispatcher) quotaSlotsLocked(scope QuotaScope) int {
+	n := 0
+	for _, job := range jd.jobs {
+		if job.quotaSlot && job.State != Finished && scope.covers(job.quotaUser(), job.groups) {
+			n++
+		}
+	}
+	return n
+}
+
+// block until the running-jobs quotas of the job's user and groups leave room
+// for it, false if the queue was cancelled by a stopping drain or the job was stopped
+func (jd *JobDispatcher) acquireQuotaSlot(job *Job) bool {
+	for {
+		jd.lock.Lock()
+		full := false
+		for _, scope := range quotaScopes(job.quotaUser(), job.groups) {
+			limit := jd.limitLocked(scope)
+			if limit.MaxRunning > 0 && jd.quotaSlotsLocked(scope) >= limit.MaxRunning {
+				full = true
+				break
+			}
+		}
+		job.waiting = full
+		if !full {
+			job.quotaSlot = true
+			jd.lock.Unlock()
+			return true
+		}
+		// a job finishing changes the dispatcher and wakes us up
+		changed := jd.changed
+		jd.lock.Unlock()
+		select {
+		case <-changed:
+		case <-jd.cancelQueued:
+			return false
+		case <-job.stop:
+			return false
+		}
+	}
+}
+
+// count the CPU time of a finished job against its quotas
+func (jd *JobDispatcher) recordCPU(job *Job, seconds float64) {
+	jd.lock.Lock()
+	defer jd.lock.Unlock()
+	jd.cpuLog = append(jd.cpuLog, cpuRecord{user: job.quotaUser(), groups: job.groups, at: time.Now(), seconds: seconds})
+}
+
+// the quotas that apply to user and what its jobs use, the user's own first
+func (jd *JobDispatcher) QuotaReport(user string) []QuotaUsage {
+	groups := jd.quotaGroups(user)
+	jd.lock.Lock()
+	defer jd.lock.Unlock()
+	var report []QuotaUsage
+	for _, scope := range quotaScopes(user, groups) {
+		report = append(report, jd.usageLocked(scope))
+	}
+	return report
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// a dispatcher holding jobs in the given states without running anything
+func quotaDispatcher(q Quotas, jobs ...*Job) *JobDispatcher {
+	jd := NewJobDispatcher()
+	jd.SetQuotas(q, nil)
+	for i, job := range jobs {
+		job.ID = fmt.Sprintf("job-%d", i)
+		if job.output == nil {
+			job.output = newOutputLog(OutputLimit{}, nil)
+		}
+		jd.jobs[job.ID] = job
+	}
+	return jd
+}
+
+func TestQuotaValidate(t *testing.T) {
+	tests := []struct {
+		quotas Quotas
+		err    bool
+	}{
+		{quotas: Quotas{}},
+		{quotas: Quotas{Default: Quota{MaxRunning: 1, MaxQueued: 2, CPUSecondsPerDay: 3, OutputBytes: 4}}},
+		{quotas: Quotas{Default: Quota{MaxRunning: -1}}, err: true},
+		{quotas: Quotas{Users: map[string]Quota{"alice": {OutputBytes: -1}}}, err: true},
+		{quotas: Quotas{Groups: map[string]Quota{"dev": {MaxQueued: -1}}}, err: true},
+	}
+	for _, tt := range tests {
+		if err := tt.quotas.Validate(); (err != nil) != tt.err {
+			t.Errorf("Validate(%+v) = %v, want error %v", tt.quotas, err, tt.err)
+		}
+	}
+}
+
+func TestQuotaUsage(t *testing.T) {
+	jd := quotaDispatcher(Quotas{},
+		&Job{Caller: "alice", State: Running, quotaSlot: true},
+		&Job{Caller: "alice", State: Paused, quotaSlot: true},
+		&Job{Caller: "alice", State: Finished, quotaSlot: true},
+		// admitted but not yet blocked on a slot, not queued
+		&Job{Caller: "alice", State: Created},
+		&Job{Caller: "alice", State: Created, waiting: true},
+		// past its quota, waiting for a slot of the server
+		&Job{Caller: "alice", State: Created, waiting: true, quotaSlot: true},
+		&Job{Caller: "bob", State: Running, quotaSlot: true, groups: []string{"dev"}},
+		// only claims to be bob
+		&Job{User: "bob", State: Running, quotaSlot: true},
+		&Job{Caller: "carol", State: Created, waiting: true, groups: []string{"dev"}},
+	)
+	jd.jobs["job-0"].output.Write([]byte("12345\n"))
+	jd.cpuLog = []cpuRecord{
+		{user: "alice", at: time.Now().Add(-2 * cpuWindow), seconds: 100},
+		{user: "alice", at: time.Now(), seconds: 2},
+		{user: "bob", groups: []string{"dev"}, at: time.Now(), seconds: 3},
+	}
+	tests := []struct {
+		scope QuotaScope
+		want  QuotaUsage
+	}{
+		{scope: QuotaScope{User: "alice"}, want: QuotaUsage{Running: 2, Queued: 2, CPUSeconds: 2, OutputBytes: 6}},
+		{scope: QuotaScope{User: "bob"}, want: QuotaUsage{Running: 1, CPUSeconds: 3}},
+		{scope: QuotaScope{Group: "dev"}, want: QuotaUsage{Running: 1, Queued: 1, CPUSeconds: 3}},
+		{scope: QuotaScope{User: "dave"}},
+		{scope: QuotaScope{}, want: QuotaUsage{Running: 1}},
+	}
+	for _, tt := range tests {
+		jd.lock.Lock()
+		got := jd.usageLocked(tt.scope)
+		jd.lock.Unlock()
+		tt.want.Scope = tt.scope
+		if got != tt.want {
+			t.Errorf("usage of %s = %+v, want %+v", tt.scope, got, tt.want)
+		}
+	}
+	if len(jd.cpuLog) != 2 {
+		t.Errorf("%d CPU records left, want the one outside the window pruned", len(jd.cpuLog))
+	}
+	// the job waiting for the server still holds a slot of alice's quota
+	jd.lock.Lock()
+	slots := jd.quotaSlotsLocked(QuotaScope{User: "alice"})
+	jd.lock.Unlock()
+	if slots != 3 {
+		t.Errorf("alice holds %d quota slots, want 3", slots)
+	}
+}
+
+func TestAdmitQuota(t *testing.T) {
+	quotas := Quotas{
+		Default: Quota{MaxQueued: 1},
+		Users:   map[string]Quota{"alice": {OutputBytes: 10}, "root": {}},
+		Groups:  map[string]Quota{"dev": {CPUSecondsPerDay: 5}},
+	}
+	tests := []struct {
+		name string
+		jobs []*Job
+		cpu  []cpuRecord
+		job  *Job
+		err  bool
+	}{
+		{name: "nothing used", job: &Job{Caller: "bob"}},
+		{name: "queue full", jobs: []*Job{{Caller: "bob", State: Created, waiting: true}}, job: &Job{Caller: "bob"}, err: true},
+		{name: "created but not waiting", jobs: []*Job{{Caller: "bob", State: Created}}, job: &Job{Caller: "bob"}},
+		{name: "other user's queue", jobs: []*Job{{Caller: "carol", State: Created, waiting: true}}, job: &Job{Caller: "bob"}},
+		{name: "claiming another user", jobs: []*Job{{Caller: "bob", State: Created, waiting: true}}, job: &Job{User: "carol", Caller: "bob"}, err: true},
+		{name: "anonymous queue full", jobs: []*Job{{User: "bob", State: Created, waiting: true}}, job: &Job{User: "carol"}, err: true},
+		{name: "unlimited user", jobs: []*Job{{Caller: "root", State: Created, waiting: true}}, job: &Job{Caller: "root"}},
+		{name: "output used up", jobs: []*Job{{Caller: "alice", State: Finished}}, job: &Job{Caller: "alice"}, err: true},
+		{name: "group cpu used up", cpu: []cpuRecord{{user: "carol", groups: []string{"dev"}, at: time.Now(), seconds: 5}}, job: &Job{Caller: "bob", groups: []string{"dev"}}, err: true},
+		{name: "cpu outside the group", cpu: []cpuRecord{{user: "carol", groups: []string{"dev"}, at: time.Now(), seconds: 5}}, job: &Job{Caller: "bob"}},
+	}
+	for _, tt := range tests {
+		jd := quotaDispatcher(quotas, tt.jobs...)
+		for _, job := range tt.jobs {
+			if job.Caller == "alice" {
+				job.output.Write([]byte("0123456789\n"))
+			}
+		}
+		jd.cpuLog = tt.cpu
+		jd.lock.Lock()
+		err := jd.admitQuotaLocked(tt.job)
+		jd.lock.Unlock()
+		if got := errors.Is(err, ErrQuotaExceeded); got != tt.err || (err != nil && !got) {
+			t.Errorf("%s: admit = %v, want quota exceeded %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestOutputQuota(t *testing.T) {
+	quotas := Quotas{
+		Users:  map[string]Quota{"alice": {OutputBytes: 100}},
+		Groups: map[string]Quota{"dev": {OutputBytes: 50}},
+	}
+	jd := quotaDispatcher(quotas, &Job{Caller: "alice", State: Finished})
+	jd.jobs["job-0"].output.Write([]byte("0123456789\n"))
+	tests := []struct {
+		job  *Job
+		want int64
+	}{
+		{job: &Job{Caller: "bob"}, want: 0},
+		{job: &Job{Caller: "alice"}, want: 100 - 11},
+		{job: &Job{Caller: "bob", groups: []string{"dev"}}, want: 50},
+		{job: &Job{Caller: "alice", groups: []string{"dev"}}, want: 50},
+	}
+	for _, tt := range tests {
+		jd.lock.Lock()
+		got := jd.outputQuotaLocked(tt.job)
+		jd.lock.Unlock()
+		if got != tt.want {
+			t.Errorf("output quota of %s in %v = %d, want %d", tt.job.Caller, tt.job.groups, got, tt.want)
+		}
+	}
+}
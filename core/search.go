@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+var ErrInvalidSearch = errors.New("invalid search")
+
+// limits of a search, so a broad pattern can't build up a huge response
+const (
+	DefaultSearchMatches = 100
+	MaxSearchMatches     = 10000
+	maxSearchPattern     = 1024
+	maxMatchText         = 1024 // longer lines are cut in the results
+)
+
+// which output Search looks at
+type SearchQuery struct {
+	Pattern    string   // RE2 regular expression matched against each line
+	JobID      string   // search only this job, Selector is ignored then
+	Selector   Selector // otherwise the jobs matching it, nil means all
+	MaxMatches int      // 0 means DefaultSearchMatches, at most MaxSearchMatches
+}
+
+type SearchMatch struct {
+	JobID string
+	OutputLine
+}
+
+type SearchResult struct {
+	Matches   []SearchMatch // by job ID, then line
+	Truncated bool          // there were more matches than MaxMatches
+	Jobs      int           // jobs searched
+}
+
+// the compiled pattern and the match limit of q
+func (q SearchQuery) compile() (*regexp.Regexp, int, error) {
+	if q.Pattern == "" {
+		return nil, 0, fmt.Errorf("%w: empty pattern", ErrInvalidSearch)
+	}
+	if len(q.Pattern) > maxSearchPattern {
+		return nil, 0, fmt.Errorf("%w: pattern longer than %d bytes", ErrInvalidSearch, maxSearchPattern)
+	}
+	re, err := regexp.Compile(q.Pattern)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidSearch, err)
+	}
+	limit := q.MaxMatches
+	switch {
+	case limit < 0:
+		return nil, 0, fmt.Errorf("%w: negative max matches", ErrInvalidSearch)
+	case limit == 0:
+		limit = DefaultSearchMatches
+	case limit > MaxSearchMatches:
+		limit = MaxSearchMatches
+	}
+	return re, limit, nil
+}
+
+// find the output lines matching q.Pattern in the jobs chosen by q
+// lines are matched as they are stored, after secrets were redacted; only
+// what the processes wrote is searched, not the messages of the dispatcher
+func (jd *JobDispatcher) Search(ctx context.Context, q SearchQuery) (SearchResult, error) {
+	re, limit, err := q.compile()
+	if err != nil {
+		return SearchResult{}, err
+	}
+	var jobs []*Job
+	jd.lock.RLock()
+	if q.JobID != "" {
+		if err := validateJobId(q.JobID); err != nil {
+			jd.lock.RUnlock()
+			return SearchResult{}, err
+		}
+		if job := jd.jobs[q.JobID]; job != nil {
+			jobs = append(jobs, job)
+		}
+	} else {
+		jobs = jd.selectLocked(q.Selector)
+	}
+	jd.lock.RUnlock()
+	if q.JobID != "" && len(jobs) == 0 {
+		return SearchResult{}, ErrJobNotFound
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	result := SearchResult{Jobs: len(jobs)}
+	for _, job := range jobs {
+		if err := ctx.Err(); err != nil {
+			return SearchResult{}, err
+		}
+		// the lines read so far never change, the log is only appended to
+		lines, _, _ := job.output.read(0)
+		for _, line := range lines {
+			// including the marker of dropped lines that read adds
+			if line.Stream == StreamSystem || !re.MatchString(line.Text) {
+				continue
+			}
+			if len(result.Matches) == limit {
+				result.Truncated = true
+				return result, nil
+			}
+			if len(line.Text) > maxMatchText {
+				line.Text = line.Text[:maxMatchText]
+			}
+			result.Matches = append(result.Matches, SearchMatch{JobID: job.ID, OutputLine: line})
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSearchQueryCompile(t *testing.T) {
+	tests := []struct {
+		name  string
+		query SearchQuery
+		limit int
+		err   bool
+	}{
+		{name: "default limit", query: SearchQuery{Pattern: "x"}, limit: DefaultSearchMatches},
+		{name: "own limit", query: SearchQuery{Pattern: "x", MaxMatches: 5}, limit: 5},
+		{name: "capped", query: SearchQuery{Pattern: "x", MaxMatches: MaxSearchMatches + 1}, limit: MaxSearchMatches},
+		{name: "negative limit", query: SearchQuery{Pattern: "x", MaxMatches: -1}, err: true},
+		{name: "empty pattern", query: SearchQuery{}, err: true},
+		{name: "long pattern", query: SearchQuery{Pattern: strings.Repeat("a", maxSearchPattern+1)}, err: true},
+		{name: "bad pattern", query: SearchQuery{Pattern: "("}, err: true},
+	}
+	for _, tt := range tests {
+		_, limit, err := tt.query.compile()
+		if tt.err {
+			if !errors.Is(err, ErrInvalidSearch) {
+				t.Errorf("%s: compile() = %v, want ErrInvalidSearch", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || limit != tt.limit {
+			t.Errorf("%s: compile() = %d, %v, want %d", tt.name, limit, err, tt.limit)
+		}
+	}
+}
+
+// a dispatcher with finished jobs that wrote the given output
+func searchDispatcher(outputs ...string) (*JobDispatcher, []string) {
+	jd := NewJobDispatcher()
+	var ids []string
+	for i, output := range outputs {
+		job := &Job{ID: fmt.Sprintf("00000000-0000-4000-8000-%012d", i), State: Finished, output: newOutputLog(OutputLimit{}, nil)}
+		job.output.Write([]byte(output))
+		job.output.writeLine("error: not a match of the process")
+		job.output.Close()
+		jd.jobs[job.ID] = job
+		ids = append(ids, job.ID)
+	}
+	return jd, ids
+}
+
+func TestSearch(t *testing.T) {
+	long := strings.Repeat("x", maxMatchText+10) + " error"
+	jd, ids := searchDispatcher("ok\nerror: one\n", "error: two\nerror: three\n"+long+"\n", "nothing\n")
+	tests := []struct {
+		name      string
+		query     SearchQuery
+		matches   []string
+		truncated bool
+		jobs      int
+	}{
+		{name: "all jobs", query: SearchQuery{Pattern: "^error"}, matches: []string{"error: one", "error: two", "error: three"}, jobs: 3},
+		{name: "limited", query: SearchQuery{Pattern: "^error", MaxMatches: 2}, matches: []string{"error: one", "error: two"}, truncated: true, jobs: 3},
+		{name: "exactly the limit", query: SearchQuery{Pattern: "^error", MaxMatches: 3}, matches: []string{"error: one", "error: two", "error: three"}, jobs: 3},
+		{name: "one job", query: SearchQuery{Pattern: "^error", JobID: ids[1]}, matches: []string{"error: two", "error: three"}, jobs: 1},
+		{name: "long line cut", query: SearchQuery{Pattern: "x error$"}, matches: []string{long[:maxMatchText]}, jobs: 3},
+	}
+	for _, tt := range tests {
+		result, err := jd.Search(context.Background(), tt.query)
+		if err != nil {
+			t.Errorf("%s: Search() = %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, match := range result.Matches {
+			got = append(got, match.Text)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.matches, "|") || result.Truncated != tt.truncated || result.Jobs != tt.jobs {
+			t.Errorf("%s: Search() = %q truncated %v in %d jobs, want %q truncated %v in %d jobs", tt.name, got, result.Truncated, result.Jobs, tt.matches, tt.truncated, tt.jobs)
+		}
+	}
+	if _, err := jd.Search(context.Background(), SearchQuery{Pattern: "x", JobID: "7c9e6679-7425-40de-944b-e07fc1f90ae7"}); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Search() of a missing job = %v, want ErrJobNotFound", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := jd.Search(ctx, SearchQuery{Pattern: "x"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Search() with a cancelled context = %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// a secret handed to a job, either as an environment variable or as a file in
+// the directory named by $JOB_SECRETS_DIR; only the name is part of the job
+type SecretRef struct {
+	Name string `json:"name"`
+	Env  string `json:"env,omitempty"`
+	File string `json:"file,omitempty"`
+}
+
+// environment variable pointing jobs to their secret files
+const SecretsDirEnv = "JOB_SECRETS_DIR"
+
+// where jobs get secret values from, user is the caller that started the job
+type SecretSource interface {
+	Get(name, user string) ([]byte, error)
+}
+
+func (jd *JobDispatcher) SetSecretSource(source SecretSource) {
+	jd.lock.Lock()
+	defer jd.lock.Unlock()
+	jd.secrets = source
+}
+
+func validateSecretRefs(refs []SecretRef) error {
+	envs, files := make(map[string]bool), make(map[string]bool)
+	for _, ref := range refs {
+		if ref.Name == "" {
+			return fmt.Errorf("%w: secret without a name", ErrInvalidJob)
+		}
+		if (ref.Env == "") == (ref.File == "") {
+			return fmt.Errorf("%w: secret %s needs either an environment variable or a file name", ErrInvalidJob, ref.Name)
+		}
+		if ref.Env != "" {
+			if !envNamePattern.MatchString(ref.Env) || ref.Env == SecretsDirEnv {
+				return fmt.Errorf("%w: environment variable name %q for secret %s", ErrInvalidJob, ref.Env, ref.Name)
+			}
+			if envs[ref.Env] {
+				return fmt.Errorf("%w: environment variable %s set by two secrets", ErrInvalidJob, ref.Env)
+			}
+			envs[ref.Env] = true
+		}
+		if ref.File != "" {
+			if ref.File == "." || ref.File == ".." || strings.ContainsAny(ref.File, "/\x00") {
+				return fmt.Errorf("%w: file name %q for secret %s", ErrInvalidJob, ref.File, ref.Name)
+			}
+			if files[ref.File] {
+				return fmt.Errorf("%w: file %s written by two secrets", ErrInvalidJob, ref.File)
+			}
+			files[ref.File] = true
+		}
+	}
+	return nil
+}
+
+// secret values of a job being started, kept out of the job record
+type jobSecrets struct {
+	env    []string // NAME=value
+	dir    string   // holds the secret files, removed when the job ends
+	values [][]byte // for redacting the output
+}
+
+// fetch the job's secrets and write its secret files, owned by cred when the
+// job runs as another user
+func (jd *JobDispatcher) resolveSecrets(job *Job, cred *syscall.Credential) (*jobSecrets, error) {
+	s := &jobSecrets{}
+	if len(job.Secrets) == 0 {
+		return s, nil
+	}
+	jd.lock.RLock()
+	source := jd.secrets
+	jd.lock.RUnlock()
+	if source == nil {
+		return nil, fmt.Errorf("no secret store configured")
+	}
+	for _, ref := range job.Secrets {
+		// checked again, the secret may have changed hands while the job waited
+		value, err := source.Get(ref.Name, job.Caller)
+		if err != nil {
+			s.cleanup()
+			return nil, err
+		}
+		s.values = append(s.values, value)
+		if ref.Env != "" {
+			s.env = append(s.env, ref.Env+"="+string(value))
+			continue
+		}
+		if s.dir == "" {
+			if s.dir, err = os.MkdirTemp("", "job-secrets-"); err != nil {
+				return nil, err
+			}
+			s.env = append(s.env, SecretsDirEnv+"="+s.dir)
+			if err := chownTo(s.dir, cred); err != nil {
+				s.cleanup()
+				return nil, err
+			}
+		}
+		path := filepath.Join(s.dir, ref.File)
+		if err := os.WriteFile(path, value, 0o600); err != nil {
+			s.cleanup()
+			return nil, fmt.Errorf("writing secret %s: %w", ref.Name, err)
+		}
+		if err := chownTo(path, cred); err != nil {
+			s.cleanup()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *jobSecrets) cleanup() {
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+}
+
+func chownTo(path string, cred *syscall.Credential) error {
+	if cred == nil {
+		return nil
+	}
+	return os.Chown(path, int(cred.Uid), int(cred.Gid))
+}
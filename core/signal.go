@@ -0,0 +1,84 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"syscall"
+)
+
+var (
+	ErrInvalidSignal    = errors.New("invalid signal")
+	ErrSignalNotAllowed = errors.New("signal not allowed")
+)
+
+// signals that can be named in a Signal request; STOP, TSTP and CONT are left
+// out since they would make the job look running while it isn't
+var signalsByName = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"ABRT":  syscall.SIGABRT,
+	"KILL":  syscall.SIGKILL,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"ALRM":  syscall.SIGALRM,
+	"TERM":  syscall.SIGTERM,
+	"WINCH": syscall.SIGWINCH,
+}
+
+// signals allowed when the configuration doesn't say otherwise
+var DefaultAllowedSignals = []string{"HUP", "INT", "QUIT", "TERM", "USR1", "USR2", "WINCH"}
+
+// the signal for a name like HUP, SIGHUP or sighup
+func ParseSignal(name string) (syscall.Signal, error) {
+	sig, ok := signalsByName[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return 0, fmt.Errorf("%w %q", ErrInvalidSignal, name)
+	}
+	return sig, nil
+}
+
+// deliver a signal to a running job's process, or to its whole process group
+func (jd *JobDispatcher) SignalJob(jobId, name string, group bool) error {
+	if err := validateJobId(jobId); err != nil {
+		return err
+	}
+	sig, err := ParseSignal(name)
+	if err != nil {
+		return err
+	}
+	jd.lock.RLock()
+	defer jd.lock.RUnlock()
+	if !jd.signalAllowed(sig) {
+		return fmt.Errorf("%w: %s", ErrSignalNotAllowed, name)
+	}
+	job := jd.jobs[jobId]
+	if job == nil {
+		return ErrJobNotFound
+	}
+	if job.State != Running || job.cmdObj == nil || job.cmdObj.Process == nil {
+		return ErrJobNotRunning
+	}
+	pid := job.cmdObj.Process.Pid
+	slog.Info("signalling job", "job_id", jobId, "pid", pid, "signal", sig.String(), "group", group)
+	if group {
+		// the job leads its own process group, see StartJob
+		pid = -pid
+	}
+	if err := syscall.Kill(pid, sig); err != nil {
+		return fmt.Errorf("failed to signal the process: %w", err)
+	}
+	return nil
+}
+
+// caller holds the lock
+func (jd *JobDispatcher) signalAllowed(sig syscall.Signal) bool {
+	for _, name := range jd.limits.AllowedSignals {
+		if allowed, err := ParseSignal(name); err == nil && allowed == sig {
+			return true
+		}
+	}
+	return false
+}
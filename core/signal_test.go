@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		name string
+		sig  syscall.Signal
+		err  bool
+	}{
+		{name: "HUP", sig: syscall.SIGHUP},
+		{name: "SIGTERM", sig: syscall.SIGTERM},
+		{name: "usr1", sig: syscall.SIGUSR1},
+		{name: "sigint", sig: syscall.SIGINT},
+		{name: "STOP", err: true},
+		{name: "CONT", err: true},
+		{name: "9", err: true},
+		{name: "", err: true},
+	}
+	for _, tt := range tests {
+		sig, err := ParseSignal(tt.name)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidSignal) {
+				t.Errorf("ParseSignal(%q) = %v, want ErrInvalidSignal", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || sig != tt.sig {
+			t.Errorf("ParseSignal(%q) = %v, %v, want %v", tt.name, sig, err, tt.sig)
+		}
+	}
+}
+
+// wait until the job's process runs
+func waitRunning(t *testing.T, jd *JobDispatcher, jobId string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if status, err := jd.QueryJob(jobId); err == nil && status.Job.State == Running {
+			return
+		}
+	}
+	t.Fatalf("job %s did not start", jobId)
+}
+
+func TestSignalJob(t *testing.T) {
+	jd := NewJobDispatcher()
+	jd.SetLimits(Limits{AllowedSignals: DefaultAllowedSignals})
+	job := Job{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", Cmd: `trap 'echo got usr1; exit 3' USR1; while :; do sleep 0.05; done`}
+	if err := jd.SubmitJob(job); err != nil {
+		t.Fatal(err)
+	}
+	defer jd.StopJob(job.ID)
+	waitRunning(t, jd, job.ID)
+	if err := jd.SignalJob(job.ID, "KILL", false); !errors.Is(err, ErrSignalNotAllowed) {
+		t.Errorf("SignalJob(KILL) = %v, want ErrSignalNotAllowed", err)
+	}
+	if err := jd.SignalJob(job.ID, "STOP", false); !errors.Is(err, ErrInvalidSignal) {
+		t.Errorf("SignalJob(STOP) = %v, want ErrInvalidSignal", err)
+	}
+	if err := jd.SignalJob(job.ID, "SIGUSR1", false); err != nil {
+		t.Fatalf("SignalJob(SIGUSR1) = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	statuses, err := jd.WaitJobs(ctx, []string{job.ID}, WaitAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := statuses[0]; status.ExitCode != 3 || !strings.Contains(status.Job.output.String(), "got usr1") {
+		t.Errorf("after the signal: exit code %d, output %q", status.ExitCode, status.Job.output.String())
+	}
+	if err := jd.SignalJob(job.ID, "USR1", false); !errors.Is(err, ErrJobNotRunning) {
+		t.Errorf("SignalJob of a finished job = %v, want ErrJobNotRunning", err)
+	}
+}
@@ -0,0 +1,383 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTemplateExists   = errors.New("template already exists")
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrInvalidTemplate  = errors.New("invalid template")
+	ErrInvalidParams    = errors.New("invalid template parameters")
+	ErrNotTemplateOwner = errors.New("template belongs to another user")
+)
+
+var (
+	templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?$`)
+	paramNamePattern    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	envNamePattern      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// {{name}} in the command, env values, workdir and label values
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([^{}\s]*)\s*\}\}`)
+)
+
+// named, reusable job settings, instantiated with parameter values
+type Template struct {
+	Name    string            `json:"name"`
+	Cmd     string            `json:"cmd"`
+	Env     map[string]string `json:"env,omitempty"`
+	Workdir string            `json:"workdir,omitempty"`
+	Timeout time.Duration     `json:"timeout,omitempty"` // 0 uses the server's default timeout
+	Labels  map[string]string `json:"labels,omitempty"`
+	Params  []TemplateParam   `json:"params,omitempty"`
+	// collected from the working directory, not expanded
+	Artifacts []string    `json:"artifacts,omitempty"`
+	Secrets   []SecretRef `json:"secrets,omitempty"`
+	// verified caller that created it, only they may change it; empty when
+	// created by an anonymous one, then anyone may
+	Owner string `json:"owner,omitempty"`
+}
+
+// a value for a {{name}} placeholder, either required or with a default
+type TemplateParam struct {
+	Name        string `json:"name"`
+	Default     string `json:"default,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+func (t Template) Validate() error {
+	if !templateNamePattern.MatchString(t.Name) {
+		return fmt.Errorf("%w: name %q", ErrInvalidTemplate, t.Name)
+	}
+	if strings.TrimSpace(t.Cmd) == "" {
+		return fmt.Errorf("%w %s: empty command", ErrInvalidTemplate, t.Name)
+	}
+	if t.Timeout < 0 {
+		return fmt.Errorf("%w %s: negative timeout", ErrInvalidTemplate, t.Name)
+	}
+	for name := range t.Env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("%w %s: environment variable name %q", ErrInvalidTemplate, t.Name, name)
+		}
+	}
+	declared := make(map[string]bool)
+	for _, p := range t.Params {
+		if !paramNamePattern.MatchString(p.Name) {
+			return fmt.Errorf("%w %s: parameter name %q", ErrInvalidTemplate, t.Name, p.Name)
+		}
+		if declared[p.Name] {
+			return fmt.Errorf("%w %s: parameter %s declared twice", ErrInvalidTemplate, t.Name, p.Name)
+		}
+		if p.Required && p.Default != "" {
+			return fmt.Errorf("%w %s: required parameter %s has a default", ErrInvalidTemplate, t.Name, p.Name)
+		}
+		declared[p.Name] = true
+	}
+	for _, name := range t.placeholders() {
+		if !declared[name] {
+			return fmt.Errorf("%w %s: placeholder {{%s}} is not a declared parameter", ErrInvalidTemplate, t.Name, name)
+		}
+	}
+	if name, ok := quotedPlaceholder(t.Cmd); ok {
+		return fmt.Errorf("%w %s: placeholder {{%s}} inside quotes or a here-document, its value is quoted already", ErrInvalidTemplate, t.Name, name)
+	}
+	if err := validateSecretRefs(t.Secrets); err != nil {
+		return fmt.Errorf("%w %s: %v", ErrInvalidTemplate, t.Name, err)
+	}
+	if err := ValidateArtifactPatterns(t.Artifacts); err != nil {
+		return fmt.Errorf("%w %s: %v", ErrInvalidTemplate, t.Name, err)
+	}
+	// labels are checked once placeholders are filled, a default stands in for the value here
+	job, _ := t.expand(t.defaults())
+	if err := ValidateLabels(job.Labels); err != nil {
+		return fmt.Errorf("%w %s: %v", ErrInvalidTemplate, t.Name, err)
+	}
+	return nil
+}
+
+// names used in placeholders anywhere in the template
+func (t Template) placeholders() []string {
+	fields := []string{t.Cmd, t.Workdir}
+	for _, v := range t.Env {
+		fields = append(fields, v)
+	}
+	for _, v := range t.Labels {
+		fields = append(fields, v)
+	}
+	var names []string
+	for _, field := range fields {
+		for _, m := range placeholderPattern.FindAllStringSubmatch(field, -1) {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// the first placeholder in cmd within quotes or after a here-document, where
+// the quoted value would not be a shell word of its own
+func quotedPlaceholder(cmd string) (string, bool) {
+	var quote byte // ' or " when inside quotes, $ inside $'...'
+	heredoc := false
+	for i := 0; i < len(cmd); i++ {
+		if cmd[i] == '{' && (quote != 0 || heredoc) {
+			if m := placeholderPattern.FindStringSubmatchIndex(cmd[i:]); m != nil && m[0] == 0 {
+				return cmd[i+m[2] : i+m[3]], true
+			}
+		}
+		switch c := cmd[i]; {
+		case c == '\\' && quote != '\'':
+			i++
+		case quote == 0 && c == '$' && strings.HasPrefix(cmd[i+1:], "'"):
+			quote = '$'
+			i++
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+		case quote == '$' && c == '\'', (quote == '\'' || quote == '"') && c == quote:
+			quote = 0
+		case quote == 0 && strings.HasPrefix(cmd[i:], "<<"):
+			// the body is expanded but not split into words, it is hard to
+			// tell where it ends, so nothing after it may be a placeholder
+			heredoc = true
+		}
+	}
+	return "", false
+}
+
+// parameter values for validation, required parameters get a placeholder value
+func (t Template) defaults() map[string]string {
+	values := make(map[string]string)
+	for _, p := range t.Params {
+		values[p.Name] = p.Default
+		if p.Required {
+			values[p.Name] = "x"
+		}
+	}
+	return values
+}
+
+// a job from the template, values are checked against the declared parameters
+func (t Template) Instantiate(params map[string]string) (Job, error) {
+	values := make(map[string]string)
+	var missing []string
+	for _, p := range t.Params {
+		value, ok := params[p.Name]
+		switch {
+		case ok:
+			values[p.Name] = value
+		case p.Required:
+			missing = append(missing, p.Name)
+		default:
+			values[p.Name] = p.Default
+		}
+	}
+	if len(missing) > 0 {
+		return Job{}, fmt.Errorf("%w: %s requires %s", ErrInvalidParams, t.Name, strings.Join(missing, ", "))
+	}
+	for name := range params {
+		if _, ok := values[name]; !ok {
+			return Job{}, fmt.Errorf("%w: %s has no parameter %s", ErrInvalidParams, t.Name, name)
+		}
+	}
+	job, err := t.expand(values)
+	if err != nil {
+		return Job{}, err
+	}
+	if err := ValidateLabels(job.Labels); err != nil {
+		return Job{}, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	return job, nil
+}
+
+// fill in the placeholders, the command gets every value as a single shell word,
+// Validate makes sure placeholders there are not inside quotes
+func (t Template) expand(values map[string]string) (Job, error) {
+	var err error
+	replace := func(s string, quote bool) string {
+		return placeholderPattern.ReplaceAllStringFunc(s, func(m string) string {
+			name := placeholderPattern.FindStringSubmatch(m)[1]
+			value, ok := values[name]
+			if !ok && err == nil {
+				err = fmt.Errorf("%w: %s has no parameter %s", ErrInvalidParams, t.Name, name)
+			}
+			if quote {
+				return ShellQuote(value)
+			}
+			return value
+		})
+	}
+	job := Job{
+		Cmd:       replace(t.Cmd, true),
+		Dir:       replace(t.Workdir, false),
+		Timeout:   t.Timeout,
+		Artifacts: t.Artifacts,
+		Secrets:   t.Secrets,
+	}
+	if len(t.Env) > 0 {
+		job.Env = make(map[string]string, len(t.Env))
+		for k, v := range t.Env {
+			job.Env[k] = replace(v, false)
+		}
+	}
+	if len(t.Labels) > 0 {
+		job.Labels = make(map[string]string, len(t.Labels))
+		for k, v := range t.Labels {
+			job.Labels[k] = replace(v, false)
+		}
+	}
+	return job, err
+}
+
+// s as a single sh word, whatever it contains
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// templates by name, saved as json in a file so they survive restarts
+type TemplateStore struct {
+	lock      sync.RWMutex
+	path      string
+	templates map[string]Template
+}
+
+// load the templates saved at path, a missing file is an empty store
+func OpenTemplateStore(path string) (*TemplateStore, error) {
+	ts := &TemplateStore{path: path, templates: make(map[string]Template)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ts, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var list []Template
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	for _, t := range list {
+		ts.templates[t.Name] = t
+	}
+	return ts, nil
+}
+
+func (ts *TemplateStore) Create(t Template) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	if _, ok := ts.templates[t.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrTemplateExists, t.Name)
+	}
+	return ts.put(t)
+}
+
+// replace an existing template user may change, it keeps its owner
+func (ts *TemplateStore) Update(t Template, user string) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	old, ok := ts.templates[t.Name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, t.Name)
+	}
+	if !old.changeableBy(user) {
+		return fmt.Errorf("%w: %s", ErrNotTemplateOwner, t.Name)
+	}
+	t.Owner = old.Owner
+	return ts.put(t)
+}
+
+// a template without owner is anyone's
+func (t Template) changeableBy(user string) bool {
+	return t.Owner == "" || t.Owner == user
+}
+
+func (ts *TemplateStore) Get(name string) (Template, error) {
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+	t, ok := ts.templates[name]
+	if !ok {
+		return Template{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	return t, nil
+}
+
+// all templates sorted by name
+func (ts *TemplateStore) List() []Template {
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+	return ts.sorted()
+}
+
+func (ts *TemplateStore) Delete(name, user string) error {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	t, ok := ts.templates[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	if !t.changeableBy(user) {
+		return fmt.Errorf("%w: %s", ErrNotTemplateOwner, name)
+	}
+	delete(ts.templates, name)
+	if err := ts.save(); err != nil {
+		ts.templates[name] = t
+		return err
+	}
+	return nil
+}
+
+// store t and save, the previous version is restored if saving fails, caller holds the lock
+func (ts *TemplateStore) put(t Template) error {
+	old, existed := ts.templates[t.Name]
+	ts.templates[t.Name] = t
+	if err := ts.save(); err != nil {
+		if existed {
+			ts.templates[t.Name] = old
+		} else {
+			delete(ts.templates, t.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+func (ts *TemplateStore) sorted() []Template {
+	list := make([]Template, 0, len(ts.templates))
+	for _, t := range ts.templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// write to a temporary file and rename it, so a crash never leaves a partial file
+func (ts *TemplateStore) save() error {
+	data, err := json.MarshalIndent(ts.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(ts.path), filepath.Base(ts.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), ts.path)
+}
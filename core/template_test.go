@@ -0,0 +1,137 @@
+package core
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	values := []string{"", "plain", "two words", "it's", `"double"`, "$(touch pwned)", "`id`", "a\\b", "semi;colon", "new\nline", "'"}
+	for _, value := range values {
+		out, err := exec.Command("sh", "-c", "printf %s "+ShellQuote(value)).Output()
+		if err != nil {
+			t.Fatalf("sh -c with %q: %v", ShellQuote(value), err)
+		}
+		if string(out) != value {
+			t.Errorf("ShellQuote(%q) reads back as %q", value, out)
+		}
+	}
+}
+
+func TestTemplateInstantiate(t *testing.T) {
+	tmpl := Template{
+		Name:    "greet",
+		Cmd:     "echo {{greeting}} {{ name }}",
+		Workdir: "/tmp/{{name}}",
+		Env:     map[string]string{"WHO": "{{name}}"},
+		Labels:  map[string]string{"who": "{{name}}"},
+		Params: []TemplateParam{
+			{Name: "greeting", Default: "hello"},
+			{Name: "name", Required: true},
+		},
+	}
+	if err := tmpl.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		params map[string]string
+		cmd    string
+		dir    string
+		err    error
+	}{
+		{params: map[string]string{"name": "bob"}, cmd: "echo 'hello' 'bob'", dir: "/tmp/bob"},
+		{params: map[string]string{"name": "bob", "greeting": "it's me,"}, cmd: `echo 'it'\''s me,' 'bob'`, dir: "/tmp/bob"},
+		{params: map[string]string{"name": "x", "greeting": "$(id)"}, cmd: "echo '$(id)' 'x'", dir: "/tmp/x"},
+		{params: map[string]string{}, err: ErrInvalidParams},
+		{params: map[string]string{"name": "bob", "color": "red"}, err: ErrInvalidParams},
+		// not a valid label value
+		{params: map[string]string{"name": "a b"}, err: ErrInvalidParams},
+	}
+	for _, tt := range tests {
+		job, err := tmpl.Instantiate(tt.params)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Instantiate(%v) error = %v, want %v", tt.params, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Instantiate(%v) error = %v", tt.params, err)
+			continue
+		}
+		// only the command is quoted
+		if job.Cmd != tt.cmd || job.Dir != tt.dir || job.Env["WHO"] != tt.params["name"] || job.Labels["who"] != tt.params["name"] {
+			t.Errorf("Instantiate(%v) = cmd %q dir %q env %v labels %v, want cmd %q dir %q", tt.params, job.Cmd, job.Dir, job.Env, job.Labels, tt.cmd, tt.dir)
+		}
+	}
+}
+
+func TestTemplateValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		tmpl Template
+		ok   bool
+	}{
+		{name: "valid", tmpl: Template{Name: "t", Cmd: "echo {{x}}", Params: []TemplateParam{{Name: "x"}}}, ok: true},
+		{name: "bad name", tmpl: Template{Name: "-t", Cmd: "true"}},
+		{name: "empty command", tmpl: Template{Name: "t", Cmd: " "}},
+		{name: "undeclared placeholder", tmpl: Template{Name: "t", Cmd: "echo {{x}}"}},
+		{name: "declared twice", tmpl: Template{Name: "t", Cmd: "true", Params: []TemplateParam{{Name: "x"}, {Name: "x"}}}},
+		{name: "required with default", tmpl: Template{Name: "t", Cmd: "true", Params: []TemplateParam{{Name: "x", Required: true, Default: "d"}}}},
+		{name: "bad env name", tmpl: Template{Name: "t", Cmd: "true", Env: map[string]string{"1X": "v"}}},
+		{name: "bad label default", tmpl: Template{Name: "t", Cmd: "true", Labels: map[string]string{"l": "{{x}}"}, Params: []TemplateParam{{Name: "x", Default: "a b"}}}},
+		{name: "double quoted", tmpl: Template{Name: "t", Cmd: `echo "{{x}}"`, Params: []TemplateParam{{Name: "x"}}}},
+		{name: "inside double quotes", tmpl: Template{Name: "t", Cmd: `echo "hello {{x}}!"`, Params: []TemplateParam{{Name: "x"}}}},
+		{name: "single quoted", tmpl: Template{Name: "t", Cmd: `echo '{{x}}'`, Params: []TemplateParam{{Name: "x"}}}},
+		{name: "ansi-c quoted", tmpl: Template{Name: "t", Cmd: `echo $'\'{{x}}'`, Params: []TemplateParam{{Name: "x"}}}},
+		{name: "here-document", tmpl: Template{Name: "t", Cmd: "cat <<EOF\n{{x}}\nEOF", Params: []TemplateParam{{Name: "x"}}}},
+		{name: "after quotes", tmpl: Template{Name: "t", Cmd: `echo "a" 'b' {{x}} "it's"`, Params: []TemplateParam{{Name: "x"}}}, ok: true},
+		{name: "escaped quote", tmpl: Template{Name: "t", Cmd: `echo \" {{x}} \'`, Params: []TemplateParam{{Name: "x"}}}, ok: true},
+		{name: "quote in double quotes", tmpl: Template{Name: "t", Cmd: `echo "it's" {{x}}`, Params: []TemplateParam{{Name: "x"}}}, ok: true},
+	}
+	for _, tt := range tests {
+		err := tt.tmpl.Validate()
+		if tt.ok && err != nil {
+			t.Errorf("%s: Validate() = %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("%s: Validate() = %v, want ErrInvalidTemplate", tt.name, err)
+		}
+	}
+}
+
+func TestTemplateOwner(t *testing.T) {
+	ts, err := OpenTemplateStore(filepath.Join(t.TempDir(), "templates.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tmpl := range []Template{{Name: "mine", Cmd: "true", Owner: "alice"}, {Name: "shared", Cmd: "true"}} {
+		if err := ts.Create(tmpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name, user string
+		err        error
+	}{
+		{name: "mine", user: "bob", err: ErrNotTemplateOwner},
+		{name: "mine", user: "", err: ErrNotTemplateOwner},
+		{name: "mine", user: "alice"},
+		{name: "shared", user: "bob"},
+		{name: "missing", user: "alice", err: ErrTemplateNotFound},
+	}
+	for _, tt := range tests {
+		before, _ := ts.Get(tt.name)
+		if err := ts.Update(Template{Name: tt.name, Cmd: "false", Owner: "mallory"}, tt.user); !errors.Is(err, tt.err) {
+			t.Errorf("Update(%s) by %q = %v, want %v", tt.name, tt.user, err, tt.err)
+		}
+		if after, _ := ts.Get(tt.name); after.Owner != before.Owner {
+			t.Errorf("Update(%s) changed the owner from %q to %q", tt.name, before.Owner, after.Owner)
+		}
+		if err := ts.Delete(tt.name, tt.user); !errors.Is(err, tt.err) {
+			t.Errorf("Delete(%s) by %q = %v, want %v", tt.name, tt.user, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// how WaitJobs treats several jobs
+const (
+	WaitAll = "all" // wait until every job has finished
+	WaitAny = "any" // wait until one of the jobs has finished
+)
+
+func ValidateWaitMode(mode string) error {
+	switch mode {
+	case WaitAll, WaitAny:
+		return nil
+	}
+	return fmt.Errorf("invalid wait mode %q, expected %s or %s", mode, WaitAll, WaitAny)
+}
+
+// block until the jobs finish according to mode, or ctx ends
+// the statuses are returned in the order of jobIds either way, with ctx.Err() if ctx ended first
+func (jd *JobDispatcher) WaitJobs(ctx context.Context, jobIds []string, mode string) ([]JobStatus, error) {
+	if err := ValidateWaitMode(mode); err != nil {
+		return nil, err
+	}
+	if len(jobIds) == 0 {
+		return nil, fmt.Errorf("%w: no job IDs given", ErrInvalidJobID)
+	}
+	dones := make([]<-chan struct{}, 0, len(jobIds))
+	for _, id := range jobIds {
+		if err := validateJobId(id); err != nil {
+			return nil, err
+		}
+		jd.lock.RLock()
+		job := jd.jobs[id]
+		jd.lock.RUnlock()
+		if job == nil {
+			return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+		}
+		dones = append(dones, job.done)
+	}
+
+	finished := make(chan struct{}, len(dones))
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for _, done := range dones {
+		go func(done <-chan struct{}) {
+			select {
+			case <-done:
+				finished <- struct{}{}
+			case <-waitCtx.Done():
+			}
+		}(done)
+	}
+	need := len(dones)
+	if mode == WaitAny {
+		need = 1
+	}
+	var err error
+	for n := 0; n < need && err == nil; n++ {
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	statuses := make([]JobStatus, 0, len(jobIds))
+	for _, id := range jobIds {
+		jobStatus, queryErr := jd.QueryJob(id)
+		if queryErr != nil {
+			// deleted while we were waiting
+			jobStatus = JobStatus{Job: &Job{ID: id}, ExitCode: -1, ErrorMsg: queryErr.Error()}
+		}
+		statuses = append(statuses, jobStatus)
+	}
+	return statuses, err
+}
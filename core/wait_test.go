@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitJobs(t *testing.T) {
+	jd := NewJobDispatcher()
+	quick := Job{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", Cmd: "exit 2"}
+	slow := Job{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Cmd: "sleep 10"}
+	for _, job := range []Job{quick, slow} {
+		if err := jd.SubmitJob(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer jd.StopJob(slow.ID)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	statuses, err := jd.WaitJobs(ctx, []string{slow.ID, quick.ID}, WaitAny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// in the order asked for, whether finished or not
+	if len(statuses) != 2 || statuses[0].Job.ID != slow.ID || statuses[1].Job.ID != quick.ID {
+		t.Fatalf("WaitJobs(any) = %+v, want both statuses in order", statuses)
+	}
+	if statuses[0].Job.State == Finished || statuses[1].Job.State != Finished || statuses[1].ExitCode != 2 {
+		t.Errorf("WaitJobs(any): slow %s, quick %s exit %d", statuses[0].Job.State, statuses[1].Job.State, statuses[1].ExitCode)
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	statuses, err = jd.WaitJobs(short, []string{quick.ID, slow.ID}, WaitAll)
+	if !errors.Is(err, context.DeadlineExceeded) || len(statuses) != 2 || statuses[1].Job.State == Finished {
+		t.Errorf("WaitJobs(all) past the deadline = %+v, %v", statuses, err)
+	}
+
+	if _, err := jd.WaitJobs(ctx, []string{quick.ID}, "some"); err == nil {
+		t.Error("WaitJobs accepted an invalid mode")
+	}
+	if _, err := jd.WaitJobs(ctx, nil, WaitAll); !errors.Is(err, ErrInvalidJobID) {
+		t.Errorf("WaitJobs without IDs = %v, want ErrInvalidJobID", err)
+	}
+	if _, err := jd.WaitJobs(ctx, []string{"a5d4f9b2-6c1e-4d3a-9b7f-2e8c1d0a3b4c"}, WaitAll); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("WaitJobs of a missing job = %v, want ErrJobNotFound", err)
+	}
+}
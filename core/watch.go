@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"sort"
+)
+
+// kinds of WatchEvent
+const (
+	WatchAdded    = "added"    // the job was created, or existed when the watch started
+	WatchModified = "modified" // the job changed state or got its final status
+	WatchDeleted  = "deleted"  // the job was deleted, only its ID is set
+)
+
+type WatchEvent struct {
+	Type   string
+	Status JobStatus
+	rev    uint64
+}
+
+// wake up watchers after a job was added, changed or deleted, caller holds the lock
+func (jd *JobDispatcher) notifyLocked(job *Job) {
+	jd.revision++
+	job.revision = jd.revision
+	close(jd.changed)
+	jd.changed = make(chan struct{})
+}
+
+// send an added event for every job matching sel, then an event each time one
+// of them changes, until ctx ends; channel is closed on return
+// changes that happen in quick succession may be reported as one event
+func (jd *JobDispatcher) Watch(ctx context.Context, sel Selector, channel chan<- WatchEvent) error {
+	defer close(channel)
+	sent := make(map[string]uint64) // revision of the last event sent per job
+	for {
+		jd.lock.RLock()
+		changed := jd.changed
+		var events []WatchEvent
+		seen := make(map[string]bool)
+		for _, job := range jd.selectLocked(sel) {
+			seen[job.ID] = true
+			rev, ok := sent[job.ID]
+			if ok && rev == job.revision {
+				continue
+			}
+			event := WatchEvent{Type: WatchModified, Status: *jd.JobStatuses[job.ID], rev: job.revision}
+			if !ok {
+				event.Type = WatchAdded
+			}
+			// a copy, the dispatcher keeps changing the job after the lock is released
+			snapshot := *job
+			event.Status.Job = &snapshot
+			events = append(events, event)
+			sent[job.ID] = job.revision
+		}
+		for id := range sent {
+			if !seen[id] {
+				events = append(events, WatchEvent{Type: WatchDeleted, Status: JobStatus{Job: &Job{ID: id}}, rev: jd.revision})
+				delete(sent, id)
+			}
+		}
+		jd.lock.RUnlock()
+		sort.SliceStable(events, func(i, j int) bool { return events[i].rev < events[j].rev })
+		for _, event := range events {
+			select {
+			case channel <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// webhooks a job may have
+const maxWebhooks = 10
+
+// retries of a failed delivery wait at most this long
+const maxWebhookBackoff = 5 * time.Minute
+
+// headers of a webhook request; the signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" with the webhook key, prefixed by "sha256="
+const (
+	WebhookEventHeader     = "X-Jobserver-Event"
+	WebhookDeliveryHeader  = "X-Jobserver-Delivery" // the same for every attempt
+	WebhookTimestampHeader = "X-Jobserver-Timestamp"
+	WebhookSignatureHeader = "X-Jobserver-Signature"
+)
+
+const webhookEventFinished = "job.finished"
+
+// how the dispatcher calls the webhooks of finished jobs
+type WebhookConfig struct {
+	Key         []byte        // signs every payload, nil sends them unsigned
+	MaxAttempts int           // per URL, including the first
+	Backoff     time.Duration // before the first retry, doubled for every further one
+	Timeout     time.Duration // of a single attempt
+	OutputTail  int           // last lines of output in the payload
+	// loopback, private, link-local and other internal addresses are refused,
+	// the cloud metadata service among them, unless they are in one of these
+	AllowNets []netip.Prefix
+}
+
+// one attempt to call a webhook
+type WebhookDelivery struct {
+	URL        string
+	Attempt    int // starting at 1
+	Time       time.Time
+	StatusCode int    // 0 when no response was received
+	Error      string // empty when the webhook answered with a 2xx status
+}
+
+// body of a webhook request
+type webhookPayload struct {
+	Event      string     `json:"event"`
+	Delivery   string     `json:"delivery"`
+	Job        webhookJob `json:"job"`
+	ExitCode   int        `json:"exit_code"`
+	Error      string     `json:"error"`
+	StartedAt  *time.Time `json:"started_at,omitempty"` // nil when the process never started
+	FinishedAt time.Time  `json:"finished_at"`
+	Duration   float64    `json:"duration_seconds"`
+	OutputTail []string   `json:"output_tail"`
+}
+
+type webhookJob struct {
+	ID     string            `json:"id"`
+	Cmd    string            `json:"cmd"`
+	User   string            `json:"user"`
+	State  string            `json:"state"`
+	Labels map[string]string `json:"labels"`
+}
+
+func (jd *JobDispatcher) SetWebhookConfig(c WebhookConfig) {
+	jd.lock.Lock()
+	defer jd.lock.Unlock()
+	jd.webhookConfig = c
+}
+
+// urls must be absolute http or https URLs
+func ValidateWebhooks(urls []string) error {
+	if len(urls) > maxWebhooks {
+		return fmt.Errorf("%w: %d webhooks, at most %d are allowed", ErrInvalidJob, len(urls), maxWebhooks)
+	}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("%w: webhook %q: %v", ErrInvalidJob, raw, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: webhook %q must be an http or https URL", ErrInvalidJob, raw)
+		}
+	}
+	return nil
+}
+
+// start delivering the job's final status to its webhooks, call once it has its final status
+func (jd *JobDispatcher) sendWebhooks(job *Job, status *JobStatus) {
+	if len(job.Webhooks) == 0 {
+		return
+	}
+	jd.lock.RLock()
+	cfg := jd.webhookConfig
+	payload := webhookPayload{
+		Event:    webhookEventFinished,
+		Delivery: uuid.New().String(),
+		Job: webhookJob{
+			ID:     job.ID,
+			Cmd:    job.Cmd,
+			User:   job.User,
+			State:  job.State,
+			Labels: job.Labels,
+		},
+		ExitCode:   status.ExitCode,
+		Error:      status.ErrorMsg,
+		FinishedAt: status.FinishedAt,
+	}
+	if !status.StartedAt.IsZero() {
+		startedAt := status.StartedAt
+		payload.StartedAt = &startedAt
+		payload.Duration = status.FinishedAt.Sub(startedAt).Seconds()
+	}
+	jd.lock.RUnlock()
+	if payload.Job.Labels == nil {
+		payload.Job.Labels = map[string]string{}
+	}
+	payload.OutputTail = job.output.tail(cfg.OutputTail)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("failed to encode webhook payload", "job_id", job.ID, "err", err)
+		return
+	}
+	for _, u := range job.Webhooks {
+		jd.webhooks.Add(1)
+		go func(u string) {
+			defer jd.webhooks.Done()
+			jd.deliverWebhook(job, u, payload.Delivery, body, cfg)
+		}(u)
+	}
+}
+
+// post body to u until it is accepted, the attempts are used up or WaitWebhooks gives up
+func (jd *JobDispatcher) deliverWebhook(job *Job, u, delivery string, body []byte, cfg WebhookConfig) {
+	client := webhookClient(cfg)
+	backoff := cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		code, err := postWebhook(jd.webhookCtx, client, u, delivery, body, cfg.Key)
+		d := WebhookDelivery{URL: u, Attempt: attempt, Time: time.Now(), StatusCode: code}
+		if err != nil {
+			d.Error = err.Error()
+		}
+		jd.recordDelivery(job, d)
+		if err == nil {
+			slog.Info("webhook delivered", "job_id", job.ID, "url", u, "attempt", attempt, "status", code)
+			return
+		}
+		// other client errors won't go away by sending the same payload again, nor a refused address
+		retry := code == 0 && !errors.Is(err, ErrWebhookAddress) || code == http.StatusTooManyRequests || code >= 500
+		if !retry || attempt >= cfg.MaxAttempts {
+			slog.Warn("giving up on webhook", "job_id", job.ID, "url", u, "attempt", attempt, "err", err)
+			return
+		}
+		slog.Info("webhook failed, retrying", "job_id", job.ID, "url", u, "attempt", attempt, "retry_in", backoff, "err", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-jd.webhookCtx.Done():
+			timer.Stop()
+			slog.Warn("giving up on webhook at shutdown", "job_id", job.ID, "url", u, "attempt", attempt)
+			return
+		}
+		backoff = min(2*backoff, maxWebhookBackoff)
+	}
+}
+
+// a client that only connects to the addresses cfg allows, checked after the
+// name was resolved so a name can't point somewhere else later; redirects are
+// checked the same way
+func webhookClient(cfg WebhookConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: cfg.Timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			return checkWebhookAddr(addrPort.Addr(), cfg.AllowNets)
+		},
+	}
+	// no proxy from the environment, it would be the address checked
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: cfg.Timeout,
+	}
+	return &http.Client{Timeout: cfg.Timeout, Transport: transport}
+}
+
+var ErrWebhookAddress = errors.New("webhook address not allowed")
+
+// 100.64.0.0/10, shared address space that is not routed on the internet
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func checkWebhookAddr(addr netip.Addr, allow []netip.Prefix) error {
+	addr = addr.Unmap()
+	for _, prefix := range allow {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() || sharedAddressSpace.Contains(addr) {
+		return fmt.Errorf("%w: %s is an internal address", ErrWebhookAddress, addr)
+	}
+	return nil
+}
+
+// the response status and an error unless it is 2xx
+func postWebhook(ctx context.Context, client *http.Client, u, delivery string, body, key []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, webhookEventFinished)
+	req.Header.Set(WebhookDeliveryHeader, delivery)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	if key != nil {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+signWebhook(key, timestamp, body))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func signWebhook(key []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (jd *JobDispatcher) recordDelivery(job *Job, d WebhookDelivery) {
+	jd.lock.Lock()
+	defer jd.lock.Unlock()
+	if status := jd.JobStatuses[job.ID]; status != nil && status.Job == job {
+		status.Deliveries = append(status.Deliveries, d)
+		jd.notifyLocked(job)
+	}
+}
+
+// block until every webhook delivery in progress has finished or ctx ends,
+// then deliveries still in progress are abandoned
+func (jd *JobDispatcher) WaitWebhooks(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		jd.webhooks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		jd.cancelWebhooks()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var loopback = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+
+// a dispatcher with one job whose deliveries are recorded
+func webhookDispatcher() (*JobDispatcher, *Job) {
+	jd := NewJobDispatcher()
+	job := &Job{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", State: Finished}
+	jd.jobs[job.ID] = job
+	jd.JobStatuses[job.ID] = &JobStatus{Job: job}
+	return jd, job
+}
+
+func deliveries(jd *JobDispatcher, job *Job) []WebhookDelivery {
+	jd.lock.RLock()
+	defer jd.lock.RUnlock()
+	return jd.JobStatuses[job.ID].Deliveries
+}
+
+func TestWebhookSignature(t *testing.T) {
+	key := []byte("key")
+	body := []byte(`{"event":"job.finished"}`)
+	var checked atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(r.Header.Get(WebhookTimestampHeader) + "."))
+		mac.Write(got)
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if r.Header.Get(WebhookSignatureHeader) != want || r.Header.Get(WebhookDeliveryHeader) != "d1" {
+			t.Errorf("signature %q delivery %q, want %q d1", r.Header.Get(WebhookSignatureHeader), r.Header.Get(WebhookDeliveryHeader), want)
+		}
+		checked.Store(true)
+	}))
+	defer srv.Close()
+	jd, job := webhookDispatcher()
+	jd.deliverWebhook(job, srv.URL, "d1", body, WebhookConfig{Key: key, MaxAttempts: 1, Timeout: time.Second, AllowNets: loopback})
+	if !checked.Load() {
+		t.Fatal("webhook was not called")
+	}
+	if d := deliveries(jd, job); len(d) != 1 || d[0].StatusCode != http.StatusOK || d[0].Error != "" {
+		t.Errorf("deliveries = %+v, want one accepted", d)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+	}{
+		{name: "accepted", statuses: []int{200}, attempts: 1},
+		{name: "server error then accepted", statuses: []int{500, 503, 204}, attempts: 3},
+		{name: "too many requests", statuses: []int{429, 200}, attempts: 2},
+		{name: "client error not retried", statuses: []int{400}, attempts: 1},
+		{name: "attempts used up", statuses: []int{500, 500, 500, 500}, attempts: 3},
+	}
+	for _, tt := range tests {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := int(calls.Add(1)) - 1
+			w.WriteHeader(tt.statuses[min(n, len(tt.statuses)-1)])
+		}))
+		jd, job := webhookDispatcher()
+		jd.deliverWebhook(job, srv.URL, "d1", []byte("{}"), WebhookConfig{MaxAttempts: 3, Backoff: time.Millisecond, Timeout: time.Second, AllowNets: loopback})
+		srv.Close()
+		d := deliveries(jd, job)
+		if len(d) != tt.attempts || int(calls.Load()) != tt.attempts {
+			t.Errorf("%s: %d deliveries and %d calls, want %d", tt.name, len(d), calls.Load(), tt.attempts)
+			continue
+		}
+		for i, delivery := range d {
+			if delivery.Attempt != i+1 || delivery.StatusCode != tt.statuses[i] {
+				t.Errorf("%s: delivery %d = %+v", tt.name, i, delivery)
+			}
+		}
+	}
+}
+
+func TestWebhookInternalAddress(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+	jd, job := webhookDispatcher()
+	jd.deliverWebhook(job, srv.URL, "d1", []byte("{}"), WebhookConfig{MaxAttempts: 3, Backoff: time.Millisecond, Timeout: time.Second})
+	// refused without retrying
+	if d := deliveries(jd, job); len(d) != 1 || d[0].Error == "" || calls.Load() != 0 {
+		t.Errorf("deliveries = %+v after %d calls, want one refused", d, calls.Load())
+	}
+}
+
+func TestCheckWebhookAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		ok   bool
+	}{
+		{addr: "93.184.216.34", ok: true},
+		{addr: "2606:2800:220:1::1", ok: true},
+		{addr: "127.0.0.1"},
+		{addr: "::1"},
+		{addr: "10.1.2.3"},
+		{addr: "192.168.0.1"},
+		{addr: "169.254.169.254"},
+		{addr: "::ffff:169.254.169.254"},
+		{addr: "fd00:ec2::254"},
+		{addr: "100.64.0.1"},
+		{addr: "0.0.0.0"},
+		// allowed below
+		{addr: "10.9.0.1", ok: true},
+	}
+	allow := []netip.Prefix{netip.MustParsePrefix("10.9.0.0/16")}
+	for _, tt := range tests {
+		err := checkWebhookAddr(netip.MustParseAddr(tt.addr), allow)
+		if tt.ok && err != nil {
+			t.Errorf("checkWebhookAddr(%s) = %v", tt.addr, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrWebhookAddress) {
+			t.Errorf("checkWebhookAddr(%s) = %v, want ErrWebhookAddress", tt.addr, err)
+		}
+	}
+}
+
+func TestWaitWebhooksEndsRetries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	jd, job := webhookDispatcher()
+	jd.webhooks.Add(1)
+	go func() {
+		defer jd.webhooks.Done()
+		jd.deliverWebhook(job, srv.URL, "d1", []byte("{}"), WebhookConfig{MaxAttempts: 5, Backoff: time.Hour, Timeout: time.Second, AllowNets: loopback})
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := jd.WaitWebhooks(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitWebhooks() = %v, want the deadline", err)
+	}
+	// the delivery waiting for its retry gives up
+	if err := jd.WaitWebhooks(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d := deliveries(jd, job); len(d) != 1 {
+		t.Errorf("%d deliveries, want the first attempt only", len(d))
+	}
+}
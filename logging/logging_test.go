@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	path := filepath.Join(t.TempDir(), "server.log")
+	if err := os.WriteFile(path, []byte("earlier\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	logger, err := Setup("JSON", "warn", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slog.Default() != logger {
+		t.Error("Setup did not install the logger as the default")
+	}
+	slog.Info("dropped")
+	slog.Warn("kept", "job", "a")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || lines[0] != "earlier" {
+		t.Fatalf("log file = %q, want the earlier line and one record", data)
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["msg"] != "kept" || record["job"] != "a" || record["source"] == nil {
+		t.Errorf("record = %v", record)
+	}
+
+	tests := []struct{ format, level, output string }{
+		{format: "xml", level: "info"},
+		{format: "text", level: "loud"},
+		{format: "text", level: "info", output: filepath.Join(t.TempDir(), "missing", "server.log")},
+	}
+	for _, tt := range tests {
+		if _, err := Setup(tt.format, tt.level, tt.output); err == nil {
+			t.Errorf("Setup(%q, %q, %q) succeeded", tt.format, tt.level, tt.output)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("a context without a logger did not give the default")
+	}
+	logger := slog.Default().With("request", "1")
+	if FromContext(WithLogger(context.Background(), logger)) != logger {
+		t.Error("the context's logger was not returned")
+	}
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"main/command"
 
@@ -13,15 +17,39 @@ func main() {
 		Use:   "./main",
 		Short: "Linux Job Dispatcher Service application",
 	}
+	command.Configure(rootCmd)
 
 	// Add subcommands
 	rootCmd.AddCommand(command.ListCommand())
 	rootCmd.AddCommand(command.QueryCommand())
 	rootCmd.AddCommand(command.StopCommand())
+	rootCmd.AddCommand(command.SignalCommand())
+	rootCmd.AddCommand(command.PauseCommand())
+	rootCmd.AddCommand(command.ResumeCommand())
 	rootCmd.AddCommand(command.StartCommand())
+	rootCmd.AddCommand(command.StreamCommand())
+	rootCmd.AddCommand(command.LogsCommand())
+	rootCmd.AddCommand(command.DeleteCommand())
+	rootCmd.AddCommand(command.WaitCommand())
+	rootCmd.AddCommand(command.WatchCommand())
+	rootCmd.AddCommand(command.TemplateCommand())
+	rootCmd.AddCommand(command.ArtifactCommand())
+	rootCmd.AddCommand(command.SecretCommand())
+	rootCmd.AddCommand(command.DrainCommand())
+	rootCmd.AddCommand(command.StatusCommand())
+	rootCmd.AddCommand(command.WorkersCommand())
+	rootCmd.AddCommand(command.QuotaCommand())
+	rootCmd.AddCommand(command.SearchCommand())
+	rootCmd.AddCommand(command.ConfigCommand())
 
-	// Execute the root command
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	// Execute the root command, Ctrl-C cancels the running request
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		if msg := command.ErrorMessage(err); msg != "" {
+			fmt.Fprintln(os.Stderr, "Error:", msg)
+		}
+		stop()
+		os.Exit(command.ExitCode(err))
 	}
 }
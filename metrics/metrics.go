@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "jobserver"
+
+var (
+	// number of jobs currently in each state
+	JobsByState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "jobs",
+		Help:      "Number of jobs by state.",
+	}, []string{"state"})
+
+	JobsStarted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_started_total",
+		Help:      "Number of jobs started.",
+	})
+
+	JobsFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_finished_total",
+		Help:      "Number of jobs finished, by exit code.",
+	}, []string{"exit_code"})
+
+	JobDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Wall clock duration of finished jobs.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 12), // 10ms .. ~12h
+	})
+
+	// jobs accepted by the dispatcher but not running yet
+	QueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Number of jobs waiting to be started.",
+	})
+
+	// coordinator: jobs whose worker stopped sending heartbeats, and how many of them were started again
+	JobsLost = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_lost_total",
+		Help:      "Number of jobs lost with their worker.",
+	})
+
+	JobsRestarted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_restarted_total",
+		Help:      "Number of lost jobs started again on another worker.",
+	})
+
+	ActiveStreams = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_output_streams",
+		Help:      "Number of open StreamOutput calls.",
+	})
+
+	RPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rpc_duration_seconds",
+		Help:      "Latency of gRPC calls by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	RPCErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_errors_total",
+		Help:      "Number of failed gRPC calls by method and status code.",
+	}, []string{"method", "code"})
+)
+
+var registry = prometheus.NewRegistry()
+
+func init() {
+	registry.MustRegister(
+		JobsByState,
+		JobsStarted,
+		JobsFinished,
+		JobDuration,
+		QueueDepth,
+		JobsLost,
+		JobsRestarted,
+		ActiveStreams,
+		RPCDuration,
+		RPCErrors,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+}
+
+// record a job moving from one state to another, old is empty for new jobs
+func StateChanged(old, new string) {
+	if old != "" {
+		JobsByState.WithLabelValues(old).Dec()
+	}
+	JobsByState.WithLabelValues(new).Inc()
+}
+
+func JobFinished(exitCode int, seconds float64) {
+	JobsFinished.WithLabelValues(strconv.Itoa(exitCode)).Inc()
+	JobDuration.Observe(seconds)
+}
+
+// http handler serving the registry in the prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
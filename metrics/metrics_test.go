@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStateChanged(t *testing.T) {
+	StateChanged("", "created")
+	StateChanged("created", "running")
+	StateChanged("", "created")
+	if got := testutil.ToFloat64(JobsByState.WithLabelValues("created")); got != 1 {
+		t.Errorf("created = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(JobsByState.WithLabelValues("running")); got != 1 {
+		t.Errorf("running = %v, want 1", got)
+	}
+}
+
+func TestJobFinished(t *testing.T) {
+	JobFinished(0, 1)
+	JobFinished(2, 0.5)
+	JobFinished(2, 0.5)
+	if got := testutil.ToFloat64(JobsFinished.WithLabelValues("2")); got != 2 {
+		t.Errorf("exit code 2 = %v, want 2", got)
+	}
+	if got := testutil.CollectAndCount(JobDuration); got != 1 {
+		t.Errorf("%d duration series, want 1", got)
+	}
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, want := range []string{`jobserver_jobs_finished_total{exit_code="2"} 2`, "jobserver_job_duration_seconds_count 3", "go_goroutines"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics page is missing %q", want)
+		}
+	}
+}
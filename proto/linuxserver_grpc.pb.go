@@ -19,11 +19,33 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	JobManager_Start_FullMethodName        = "/JobManager/Start"
-	JobManager_Stop_FullMethodName         = "/JobManager/Stop"
-	JobManager_Query_FullMethodName        = "/JobManager/Query"
-	JobManager_List_FullMethodName         = "/JobManager/List"
-	JobManager_StreamOutput_FullMethodName = "/JobManager/StreamOutput"
+	JobManager_Start_FullMethodName            = "/JobManager/Start"
+	JobManager_Stop_FullMethodName             = "/JobManager/Stop"
+	JobManager_Query_FullMethodName            = "/JobManager/Query"
+	JobManager_List_FullMethodName             = "/JobManager/List"
+	JobManager_Watch_FullMethodName            = "/JobManager/Watch"
+	JobManager_StopMatching_FullMethodName     = "/JobManager/StopMatching"
+	JobManager_StreamOutput_FullMethodName     = "/JobManager/StreamOutput"
+	JobManager_Signal_FullMethodName           = "/JobManager/Signal"
+	JobManager_Pause_FullMethodName            = "/JobManager/Pause"
+	JobManager_Resume_FullMethodName           = "/JobManager/Resume"
+	JobManager_Delete_FullMethodName           = "/JobManager/Delete"
+	JobManager_Wait_FullMethodName             = "/JobManager/Wait"
+	JobManager_CreateTemplate_FullMethodName   = "/JobManager/CreateTemplate"
+	JobManager_UpdateTemplate_FullMethodName   = "/JobManager/UpdateTemplate"
+	JobManager_GetTemplate_FullMethodName      = "/JobManager/GetTemplate"
+	JobManager_ListTemplates_FullMethodName    = "/JobManager/ListTemplates"
+	JobManager_DeleteTemplate_FullMethodName   = "/JobManager/DeleteTemplate"
+	JobManager_StartTemplate_FullMethodName    = "/JobManager/StartTemplate"
+	JobManager_ListArtifacts_FullMethodName    = "/JobManager/ListArtifacts"
+	JobManager_DownloadArtifact_FullMethodName = "/JobManager/DownloadArtifact"
+	JobManager_PutSecret_FullMethodName        = "/JobManager/PutSecret"
+	JobManager_ListSecrets_FullMethodName      = "/JobManager/ListSecrets"
+	JobManager_DeleteSecret_FullMethodName     = "/JobManager/DeleteSecret"
+	JobManager_Drain_FullMethodName            = "/JobManager/Drain"
+	JobManager_Status_FullMethodName           = "/JobManager/Status"
+	JobManager_Quota_FullMethodName            = "/JobManager/Quota"
+	JobManager_Search_FullMethodName           = "/JobManager/Search"
 )
 
 // JobManagerClient is the client API for JobManager service.
@@ -33,8 +55,33 @@ type JobManagerClient interface {
 	Start(ctx context.Context, in *Job, opts ...grpc.CallOption) (*Job, error)
 	Stop(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*NilMessage, error)
 	Query(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*JobStatus, error)
-	List(ctx context.Context, in *NilMessage, opts ...grpc.CallOption) (*JobStatusList, error)
-	StreamOutput(ctx context.Context, in *JobID, opts ...grpc.CallOption) (grpc.ServerStreamingClient[JobOutput], error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*JobStatusList, error)
+	Watch(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchEvent], error)
+	StopMatching(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*JobStatusList, error)
+	StreamOutput(ctx context.Context, in *OutputRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[JobOutput], error)
+	Signal(ctx context.Context, in *SignalRequest, opts ...grpc.CallOption) (*NilMessage, error)
+	Pause(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*NilMessage, error)
+	Resume(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*NilMessage, error)
+	Delete(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*NilMessage, error)
+	Wait(ctx context.Context, in *WaitRequest, opts ...grpc.CallOption) (*WaitResponse, error)
+	// named job templates, see JobTemplate
+	CreateTemplate(ctx context.Context, in *JobTemplate, opts ...grpc.CallOption) (*JobTemplate, error)
+	UpdateTemplate(ctx context.Context, in *JobTemplate, opts ...grpc.CallOption) (*JobTemplate, error)
+	GetTemplate(ctx context.Context, in *TemplateName, opts ...grpc.CallOption) (*JobTemplate, error)
+	ListTemplates(ctx context.Context, in *NilMessage, opts ...grpc.CallOption) (*JobTemplateList, error)
+	DeleteTemplate(ctx context.Context, in *TemplateName, opts ...grpc.CallOption) (*NilMessage, error)
+	StartTemplate(ctx context.Context, in *StartTemplateRequest, opts ...grpc.CallOption) (*Job, error)
+	ListArtifacts(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*ArtifactList, error)
+	DownloadArtifact(ctx context.Context, in *ArtifactRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ArtifactChunk], error)
+	// write-only secret store, values are only ever handed to jobs; each worker
+	// has its own, a coordinator answers UNIMPLEMENTED
+	PutSecret(ctx context.Context, in *Secret, opts ...grpc.CallOption) (*SecretInfo, error)
+	ListSecrets(ctx context.Context, in *NilMessage, opts ...grpc.CallOption) (*SecretList, error)
+	DeleteSecret(ctx context.Context, in *SecretName, opts ...grpc.CallOption) (*NilMessage, error)
+	Drain(ctx context.Context, in *DrainRequest, opts ...grpc.CallOption) (*DrainResponse, error)
+	Status(ctx context.Context, in *NilMessage, opts ...grpc.CallOption) (*ServerStatus, error)
+	Quota(ctx context.Context, in *QuotaRequest, opts ...grpc.CallOption) (*QuotaReport, error)
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
 }
 
 type jobManagerClient struct {
@@ -75,7 +122,7 @@ func (c *jobManagerClient) Query(ctx context.Context, in *JobID, opts ...grpc.Ca
 	return out, nil
 }
 
-func (c *jobManagerClient) List(ctx context.Context, in *NilMessage, opts ...grpc.CallOption) (*JobStatusList, error) {
+func (c *jobManagerClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*JobStatusList, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(JobStatusList)
 	err := c.cc.Invoke(ctx, JobManager_List_FullMethodName, in, out, cOpts...)
@@ -85,13 +132,42 @@ func (c *jobManagerClient) List(ctx context.Context, in *NilMessage, opts ...grp
 	return out, nil
 }
 
-func (c *jobManagerClient) StreamOutput(ctx context.Context, in *JobID, opts ...grpc.CallOption) (grpc.ServerStreamingClient[JobOutput], error) {
+func (c *jobManagerClient) Watch(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchEvent], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &JobManager_ServiceDesc.Streams[0], JobManager_StreamOutput_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &JobManager_ServiceDesc.Streams[0], JobManager_Watch_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &grpc.GenericClientStream[JobID, JobOutput]{ClientStream: stream}
+	x := &grpc.GenericClientStream[ListRequest, WatchEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JobManager_WatchClient = grpc.ServerStreamingClient[WatchEvent]
+
+func (c *jobManagerClient) StopMatching(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*JobStatusList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobStatusList)
+	err := c.cc.Invoke(ctx, JobManager_StopMatching_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) StreamOutput(ctx context.Context, in *OutputRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[JobOutput], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &JobManager_ServiceDesc.Streams[1], JobManager_StreamOutput_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[OutputRequest, JobOutput]{ClientStream: stream}
 	if err := x.ClientStream.SendMsg(in); err != nil {
 		return nil, err
 	}
@@ -104,6 +180,215 @@ func (c *jobManagerClient) StreamOutput(ctx context.Context, in *JobID, opts ...
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type JobManager_StreamOutputClient = grpc.ServerStreamingClient[JobOutput]
 
+func (c *jobManagerClient) Signal(ctx context.Context, in *SignalRequest, opts ...grpc.CallOption) (*NilMessage, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NilMessage)
+	err := c.cc.Invoke(ctx, JobManager_Signal_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) Pause(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*NilMessage, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NilMessage)
+	err := c.cc.Invoke(ctx, JobManager_Pause_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) Resume(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*NilMessage, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NilMessage)
+	err := c.cc.Invoke(ctx, JobManager_Resume_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) Delete(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*NilMessage, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NilMessage)
+	err := c.cc.Invoke(ctx, JobManager_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) Wait(ctx context.Context, in *WaitRequest, opts ...grpc.CallOption) (*WaitResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WaitResponse)
+	err := c.cc.Invoke(ctx, JobManager_Wait_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) CreateTemplate(ctx context.Context, in *JobTemplate, opts ...grpc.CallOption) (*JobTemplate, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobTemplate)
+	err := c.cc.Invoke(ctx, JobManager_CreateTemplate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) UpdateTemplate(ctx context.Context, in *JobTemplate, opts ...grpc.CallOption) (*JobTemplate, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobTemplate)
+	err := c.cc.Invoke(ctx, JobManager_UpdateTemplate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) GetTemplate(ctx context.Context, in *TemplateName, opts ...grpc.CallOption) (*JobTemplate, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobTemplate)
+	err := c.cc.Invoke(ctx, JobManager_GetTemplate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) ListTemplates(ctx context.Context, in *NilMessage, opts ...grpc.CallOption) (*JobTemplateList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobTemplateList)
+	err := c.cc.Invoke(ctx, JobManager_ListTemplates_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) DeleteTemplate(ctx context.Context, in *TemplateName, opts ...grpc.CallOption) (*NilMessage, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NilMessage)
+	err := c.cc.Invoke(ctx, JobManager_DeleteTemplate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) StartTemplate(ctx context.Context, in *StartTemplateRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, JobManager_StartTemplate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) ListArtifacts(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*ArtifactList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ArtifactList)
+	err := c.cc.Invoke(ctx, JobManager_ListArtifacts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) DownloadArtifact(ctx context.Context, in *ArtifactRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ArtifactChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &JobManager_ServiceDesc.Streams[2], JobManager_DownloadArtifact_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ArtifactRequest, ArtifactChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JobManager_DownloadArtifactClient = grpc.ServerStreamingClient[ArtifactChunk]
+
+func (c *jobManagerClient) PutSecret(ctx context.Context, in *Secret, opts ...grpc.CallOption) (*SecretInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SecretInfo)
+	err := c.cc.Invoke(ctx, JobManager_PutSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) ListSecrets(ctx context.Context, in *NilMessage, opts ...grpc.CallOption) (*SecretList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SecretList)
+	err := c.cc.Invoke(ctx, JobManager_ListSecrets_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) DeleteSecret(ctx context.Context, in *SecretName, opts ...grpc.CallOption) (*NilMessage, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NilMessage)
+	err := c.cc.Invoke(ctx, JobManager_DeleteSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) Drain(ctx context.Context, in *DrainRequest, opts ...grpc.CallOption) (*DrainResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DrainResponse)
+	err := c.cc.Invoke(ctx, JobManager_Drain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) Status(ctx context.Context, in *NilMessage, opts ...grpc.CallOption) (*ServerStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ServerStatus)
+	err := c.cc.Invoke(ctx, JobManager_Status_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) Quota(ctx context.Context, in *QuotaRequest, opts ...grpc.CallOption) (*QuotaReport, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QuotaReport)
+	err := c.cc.Invoke(ctx, JobManager_Quota_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobManagerClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, JobManager_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // JobManagerServer is the server API for JobManager service.
 // All implementations must embed UnimplementedJobManagerServer
 // for forward compatibility.
@@ -111,8 +396,33 @@ type JobManagerServer interface {
 	Start(context.Context, *Job) (*Job, error)
 	Stop(context.Context, *JobID) (*NilMessage, error)
 	Query(context.Context, *JobID) (*JobStatus, error)
-	List(context.Context, *NilMessage) (*JobStatusList, error)
-	StreamOutput(*JobID, grpc.ServerStreamingServer[JobOutput]) error
+	List(context.Context, *ListRequest) (*JobStatusList, error)
+	Watch(*ListRequest, grpc.ServerStreamingServer[WatchEvent]) error
+	StopMatching(context.Context, *ListRequest) (*JobStatusList, error)
+	StreamOutput(*OutputRequest, grpc.ServerStreamingServer[JobOutput]) error
+	Signal(context.Context, *SignalRequest) (*NilMessage, error)
+	Pause(context.Context, *JobID) (*NilMessage, error)
+	Resume(context.Context, *JobID) (*NilMessage, error)
+	Delete(context.Context, *JobID) (*NilMessage, error)
+	Wait(context.Context, *WaitRequest) (*WaitResponse, error)
+	// named job templates, see JobTemplate
+	CreateTemplate(context.Context, *JobTemplate) (*JobTemplate, error)
+	UpdateTemplate(context.Context, *JobTemplate) (*JobTemplate, error)
+	GetTemplate(context.Context, *TemplateName) (*JobTemplate, error)
+	ListTemplates(context.Context, *NilMessage) (*JobTemplateList, error)
+	DeleteTemplate(context.Context, *TemplateName) (*NilMessage, error)
+	StartTemplate(context.Context, *StartTemplateRequest) (*Job, error)
+	ListArtifacts(context.Context, *JobID) (*ArtifactList, error)
+	DownloadArtifact(*ArtifactRequest, grpc.ServerStreamingServer[ArtifactChunk]) error
+	// write-only secret store, values are only ever handed to jobs; each worker
+	// has its own, a coordinator answers UNIMPLEMENTED
+	PutSecret(context.Context, *Secret) (*SecretInfo, error)
+	ListSecrets(context.Context, *NilMessage) (*SecretList, error)
+	DeleteSecret(context.Context, *SecretName) (*NilMessage, error)
+	Drain(context.Context, *DrainRequest) (*DrainResponse, error)
+	Status(context.Context, *NilMessage) (*ServerStatus, error)
+	Quota(context.Context, *QuotaRequest) (*QuotaReport, error)
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
 	mustEmbedUnimplementedJobManagerServer()
 }
 
@@ -132,12 +442,78 @@ func (UnimplementedJobManagerServer) Stop(context.Context, *JobID) (*NilMessage,
 func (UnimplementedJobManagerServer) Query(context.Context, *JobID) (*JobStatus, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Query not implemented")
 }
-func (UnimplementedJobManagerServer) List(context.Context, *NilMessage) (*JobStatusList, error) {
+func (UnimplementedJobManagerServer) List(context.Context, *ListRequest) (*JobStatusList, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
 }
-func (UnimplementedJobManagerServer) StreamOutput(*JobID, grpc.ServerStreamingServer[JobOutput]) error {
+func (UnimplementedJobManagerServer) Watch(*ListRequest, grpc.ServerStreamingServer[WatchEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedJobManagerServer) StopMatching(context.Context, *ListRequest) (*JobStatusList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopMatching not implemented")
+}
+func (UnimplementedJobManagerServer) StreamOutput(*OutputRequest, grpc.ServerStreamingServer[JobOutput]) error {
 	return status.Errorf(codes.Unimplemented, "method StreamOutput not implemented")
 }
+func (UnimplementedJobManagerServer) Signal(context.Context, *SignalRequest) (*NilMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Signal not implemented")
+}
+func (UnimplementedJobManagerServer) Pause(context.Context, *JobID) (*NilMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Pause not implemented")
+}
+func (UnimplementedJobManagerServer) Resume(context.Context, *JobID) (*NilMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Resume not implemented")
+}
+func (UnimplementedJobManagerServer) Delete(context.Context, *JobID) (*NilMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedJobManagerServer) Wait(context.Context, *WaitRequest) (*WaitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Wait not implemented")
+}
+func (UnimplementedJobManagerServer) CreateTemplate(context.Context, *JobTemplate) (*JobTemplate, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateTemplate not implemented")
+}
+func (UnimplementedJobManagerServer) UpdateTemplate(context.Context, *JobTemplate) (*JobTemplate, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateTemplate not implemented")
+}
+func (UnimplementedJobManagerServer) GetTemplate(context.Context, *TemplateName) (*JobTemplate, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTemplate not implemented")
+}
+func (UnimplementedJobManagerServer) ListTemplates(context.Context, *NilMessage) (*JobTemplateList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTemplates not implemented")
+}
+func (UnimplementedJobManagerServer) DeleteTemplate(context.Context, *TemplateName) (*NilMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteTemplate not implemented")
+}
+func (UnimplementedJobManagerServer) StartTemplate(context.Context, *StartTemplateRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartTemplate not implemented")
+}
+func (UnimplementedJobManagerServer) ListArtifacts(context.Context, *JobID) (*ArtifactList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListArtifacts not implemented")
+}
+func (UnimplementedJobManagerServer) DownloadArtifact(*ArtifactRequest, grpc.ServerStreamingServer[ArtifactChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadArtifact not implemented")
+}
+func (UnimplementedJobManagerServer) PutSecret(context.Context, *Secret) (*SecretInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutSecret not implemented")
+}
+func (UnimplementedJobManagerServer) ListSecrets(context.Context, *NilMessage) (*SecretList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSecrets not implemented")
+}
+func (UnimplementedJobManagerServer) DeleteSecret(context.Context, *SecretName) (*NilMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSecret not implemented")
+}
+func (UnimplementedJobManagerServer) Drain(context.Context, *DrainRequest) (*DrainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Drain not implemented")
+}
+func (UnimplementedJobManagerServer) Status(context.Context, *NilMessage) (*ServerStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedJobManagerServer) Quota(context.Context, *QuotaRequest) (*QuotaReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Quota not implemented")
+}
+func (UnimplementedJobManagerServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
 func (UnimplementedJobManagerServer) mustEmbedUnimplementedJobManagerServer() {}
 func (UnimplementedJobManagerServer) testEmbeddedByValue()                    {}
 
@@ -214,7 +590,7 @@ func _JobManager_Query_Handler(srv interface{}, ctx context.Context, dec func(in
 }
 
 func _JobManager_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(NilMessage)
+	in := new(ListRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -226,52 +602,708 @@ func _JobManager_List_Handler(srv interface{}, ctx context.Context, dec func(int
 		FullMethod: JobManager_List_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(JobManagerServer).List(ctx, req.(*NilMessage))
+		return srv.(JobManagerServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(JobManagerServer).Watch(m, &grpc.GenericServerStream[ListRequest, WatchEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JobManager_WatchServer = grpc.ServerStreamingServer[WatchEvent]
+
+func _JobManager_StopMatching_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).StopMatching(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_StopMatching_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).StopMatching(ctx, req.(*ListRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
 func _JobManager_StreamOutput_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(JobID)
+	m := new(OutputRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(JobManagerServer).StreamOutput(m, &grpc.GenericServerStream[JobID, JobOutput]{ServerStream: stream})
+	return srv.(JobManagerServer).StreamOutput(m, &grpc.GenericServerStream[OutputRequest, JobOutput]{ServerStream: stream})
 }
 
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type JobManager_StreamOutputServer = grpc.ServerStreamingServer[JobOutput]
 
-// JobManager_ServiceDesc is the grpc.ServiceDesc for JobManager service.
-// It's only intended for direct use with grpc.RegisterService,
-// and not to be introspected or modified (even as a copy)
-var JobManager_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "JobManager",
-	HandlerType: (*JobManagerServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Start",
-			Handler:    _JobManager_Start_Handler,
-		},
-		{
-			MethodName: "Stop",
-			Handler:    _JobManager_Stop_Handler,
-		},
-		{
-			MethodName: "Query",
-			Handler:    _JobManager_Query_Handler,
-		},
-		{
-			MethodName: "List",
-			Handler:    _JobManager_List_Handler,
-		},
-	},
-	Streams: []grpc.StreamDesc{
-		{
-			StreamName:    "StreamOutput",
-			Handler:       _JobManager_StreamOutput_Handler,
-			ServerStreams: true,
+func _JobManager_Signal_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignalRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).Signal(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_Signal_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).Signal(ctx, req.(*SignalRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_Pause_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).Pause(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_Pause_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).Pause(ctx, req.(*JobID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_Resume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).Resume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_Resume_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).Resume(ctx, req.(*JobID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).Delete(ctx, req.(*JobID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_Wait_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WaitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).Wait(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_Wait_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).Wait(ctx, req.(*WaitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_CreateTemplate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobTemplate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).CreateTemplate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_CreateTemplate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).CreateTemplate(ctx, req.(*JobTemplate))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_UpdateTemplate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobTemplate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).UpdateTemplate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_UpdateTemplate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).UpdateTemplate(ctx, req.(*JobTemplate))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_GetTemplate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TemplateName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).GetTemplate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_GetTemplate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).GetTemplate(ctx, req.(*TemplateName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_ListTemplates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NilMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).ListTemplates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_ListTemplates_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).ListTemplates(ctx, req.(*NilMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_DeleteTemplate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TemplateName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).DeleteTemplate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_DeleteTemplate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).DeleteTemplate(ctx, req.(*TemplateName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_StartTemplate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartTemplateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).StartTemplate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_StartTemplate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).StartTemplate(ctx, req.(*StartTemplateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_ListArtifacts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).ListArtifacts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_ListArtifacts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).ListArtifacts(ctx, req.(*JobID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_DownloadArtifact_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ArtifactRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(JobManagerServer).DownloadArtifact(m, &grpc.GenericServerStream[ArtifactRequest, ArtifactChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JobManager_DownloadArtifactServer = grpc.ServerStreamingServer[ArtifactChunk]
+
+func _JobManager_PutSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Secret)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).PutSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_PutSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).PutSecret(ctx, req.(*Secret))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_ListSecrets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NilMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).ListSecrets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_ListSecrets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).ListSecrets(ctx, req.(*NilMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_DeleteSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SecretName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).DeleteSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_DeleteSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).DeleteSecret(ctx, req.(*SecretName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_Drain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DrainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).Drain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_Drain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).Drain(ctx, req.(*DrainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NilMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).Status(ctx, req.(*NilMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_Quota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).Quota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_Quota_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).Quota(ctx, req.(*QuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobManager_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobManagerServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobManager_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobManagerServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// JobManager_ServiceDesc is the grpc.ServiceDesc for JobManager service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var JobManager_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "JobManager",
+	HandlerType: (*JobManagerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Start",
+			Handler:    _JobManager_Start_Handler,
+		},
+		{
+			MethodName: "Stop",
+			Handler:    _JobManager_Stop_Handler,
+		},
+		{
+			MethodName: "Query",
+			Handler:    _JobManager_Query_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _JobManager_List_Handler,
+		},
+		{
+			MethodName: "StopMatching",
+			Handler:    _JobManager_StopMatching_Handler,
+		},
+		{
+			MethodName: "Signal",
+			Handler:    _JobManager_Signal_Handler,
+		},
+		{
+			MethodName: "Pause",
+			Handler:    _JobManager_Pause_Handler,
+		},
+		{
+			MethodName: "Resume",
+			Handler:    _JobManager_Resume_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _JobManager_Delete_Handler,
+		},
+		{
+			MethodName: "Wait",
+			Handler:    _JobManager_Wait_Handler,
+		},
+		{
+			MethodName: "CreateTemplate",
+			Handler:    _JobManager_CreateTemplate_Handler,
+		},
+		{
+			MethodName: "UpdateTemplate",
+			Handler:    _JobManager_UpdateTemplate_Handler,
+		},
+		{
+			MethodName: "GetTemplate",
+			Handler:    _JobManager_GetTemplate_Handler,
+		},
+		{
+			MethodName: "ListTemplates",
+			Handler:    _JobManager_ListTemplates_Handler,
+		},
+		{
+			MethodName: "DeleteTemplate",
+			Handler:    _JobManager_DeleteTemplate_Handler,
+		},
+		{
+			MethodName: "StartTemplate",
+			Handler:    _JobManager_StartTemplate_Handler,
+		},
+		{
+			MethodName: "ListArtifacts",
+			Handler:    _JobManager_ListArtifacts_Handler,
+		},
+		{
+			MethodName: "PutSecret",
+			Handler:    _JobManager_PutSecret_Handler,
+		},
+		{
+			MethodName: "ListSecrets",
+			Handler:    _JobManager_ListSecrets_Handler,
+		},
+		{
+			MethodName: "DeleteSecret",
+			Handler:    _JobManager_DeleteSecret_Handler,
+		},
+		{
+			MethodName: "Drain",
+			Handler:    _JobManager_Drain_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _JobManager_Status_Handler,
+		},
+		{
+			MethodName: "Quota",
+			Handler:    _JobManager_Quota_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _JobManager_Search_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _JobManager_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamOutput",
+			Handler:       _JobManager_StreamOutput_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "DownloadArtifact",
+			Handler:       _JobManager_DownloadArtifact_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "linuxserver.proto",
+}
+
+const (
+	Coordinator_Register_FullMethodName    = "/Coordinator/Register"
+	Coordinator_Heartbeat_FullMethodName   = "/Coordinator/Heartbeat"
+	Coordinator_ListWorkers_FullMethodName = "/Coordinator/ListWorkers"
+)
+
+// CoordinatorClient is the client API for Coordinator service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// served by a coordinator, workers register and report their load, the
+// coordinator starts jobs on them through their JobManager service
+type CoordinatorClient interface {
+	Register(ctx context.Context, in *WorkerInfo, opts ...grpc.CallOption) (*RegisterResponse, error)
+	Heartbeat(ctx context.Context, in *WorkerInfo, opts ...grpc.CallOption) (*HeartbeatResponse, error)
+	ListWorkers(ctx context.Context, in *NilMessage, opts ...grpc.CallOption) (*WorkerList, error)
+}
+
+type coordinatorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCoordinatorClient(cc grpc.ClientConnInterface) CoordinatorClient {
+	return &coordinatorClient{cc}
+}
+
+func (c *coordinatorClient) Register(ctx context.Context, in *WorkerInfo, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, Coordinator_Register_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) Heartbeat(ctx context.Context, in *WorkerInfo, opts ...grpc.CallOption) (*HeartbeatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HeartbeatResponse)
+	err := c.cc.Invoke(ctx, Coordinator_Heartbeat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) ListWorkers(ctx context.Context, in *NilMessage, opts ...grpc.CallOption) (*WorkerList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WorkerList)
+	err := c.cc.Invoke(ctx, Coordinator_ListWorkers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CoordinatorServer is the server API for Coordinator service.
+// All implementations must embed UnimplementedCoordinatorServer
+// for forward compatibility.
+//
+// served by a coordinator, workers register and report their load, the
+// coordinator starts jobs on them through their JobManager service
+type CoordinatorServer interface {
+	Register(context.Context, *WorkerInfo) (*RegisterResponse, error)
+	Heartbeat(context.Context, *WorkerInfo) (*HeartbeatResponse, error)
+	ListWorkers(context.Context, *NilMessage) (*WorkerList, error)
+	mustEmbedUnimplementedCoordinatorServer()
+}
+
+// UnimplementedCoordinatorServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCoordinatorServer struct{}
+
+func (UnimplementedCoordinatorServer) Register(context.Context, *WorkerInfo) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedCoordinatorServer) Heartbeat(context.Context, *WorkerInfo) (*HeartbeatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
+func (UnimplementedCoordinatorServer) ListWorkers(context.Context, *NilMessage) (*WorkerList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWorkers not implemented")
+}
+func (UnimplementedCoordinatorServer) mustEmbedUnimplementedCoordinatorServer() {}
+func (UnimplementedCoordinatorServer) testEmbeddedByValue()                     {}
+
+// UnsafeCoordinatorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CoordinatorServer will
+// result in compilation errors.
+type UnsafeCoordinatorServer interface {
+	mustEmbedUnimplementedCoordinatorServer()
+}
+
+func RegisterCoordinatorServer(s grpc.ServiceRegistrar, srv CoordinatorServer) {
+	// If the following call pancis, it indicates UnimplementedCoordinatorServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Coordinator_ServiceDesc, srv)
+}
+
+func _Coordinator_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WorkerInfo)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).Register(ctx, req.(*WorkerInfo))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WorkerInfo)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_Heartbeat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).Heartbeat(ctx, req.(*WorkerInfo))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_ListWorkers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NilMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).ListWorkers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_ListWorkers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).ListWorkers(ctx, req.(*NilMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Coordinator_ServiceDesc is the grpc.ServiceDesc for Coordinator service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Coordinator_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "Coordinator",
+	HandlerType: (*CoordinatorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _Coordinator_Register_Handler,
+		},
+		{
+			MethodName: "Heartbeat",
+			Handler:    _Coordinator_Heartbeat_Handler,
+		},
+		{
+			MethodName: "ListWorkers",
+			Handler:    _Coordinator_ListWorkers_Handler,
 		},
 	},
+	Streams:  []grpc.StreamDesc{},
 	Metadata: "linuxserver.proto",
 }
@@ -0,0 +1,241 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("secret not found")
+	ErrInvalidName = errors.New("invalid secret name")
+	ErrNotOwner    = errors.New("secret belongs to another user")
+)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?$`)
+
+// size of the AES-256 key
+const keySize = 32
+
+// what callers may see of a secret, never the value
+type Info struct {
+	Name    string
+	Owner   string // empty when put by an anonymous caller, then anyone may use it
+	Created time.Time
+	Updated time.Time
+}
+
+// value encrypted with AES-GCM, the secret's name is the additional data so a
+// value can't be moved to another name in the file
+type entry struct {
+	Nonce   []byte    `json:"nonce"`
+	Value   []byte    `json:"value"`
+	Owner   string    `json:"owner,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// secrets by name, saved encrypted as json in a file
+type Store struct {
+	lock    sync.RWMutex
+	path    string
+	aead    cipher.AEAD
+	entries map[string]entry
+}
+
+// read the base64 key at path, generating one if the file does not exist
+func LoadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		// O_EXCL: never replace a key another process just wrote, the store would become unreadable
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		_, err = f.WriteString(base64.StdEncoding.EncodeToString(key) + "\n")
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return key, err
+	} else if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("reading key %s: %w", path, err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key %s has %d bytes, expected %d", path, len(key), keySize)
+	}
+	return key, nil
+}
+
+// load the secrets saved at path, a missing file is an empty store
+func Open(path string, key []byte) (*Store, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{path: path, aead: aead, entries: make(map[string]entry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	// fail at startup rather than when a job needs the secret
+	for name, e := range s.entries {
+		if _, err := aead.Open(nil, e.Nonce, e.Value, []byte(name)); err != nil {
+			return nil, fmt.Errorf("decrypting secret %s from %s, wrong key?", name, path)
+		}
+	}
+	return s, nil
+}
+
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%w %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// create a secret owned by owner, or replace one owner may use; a replaced
+// secret keeps its owner
+func (s *Store) Put(name string, value []byte, owner string) (Info, error) {
+	if err := ValidateName(name); err != nil {
+		return Info{}, err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return Info{}, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now().UTC()
+	old, existed := s.entries[name]
+	if existed && !old.usableBy(owner) {
+		return Info{}, fmt.Errorf("%w: %s", ErrNotOwner, name)
+	}
+	e := entry{Nonce: nonce, Value: s.aead.Seal(nil, nonce, value, []byte(name)), Owner: owner, Created: now, Updated: now}
+	if existed {
+		e.Owner, e.Created = old.Owner, old.Created
+	}
+	s.entries[name] = e
+	if err := s.save(); err != nil {
+		if existed {
+			s.entries[name] = old
+		} else {
+			delete(s.entries, name)
+		}
+		return Info{}, err
+	}
+	return Info{Name: name, Owner: e.Owner, Created: e.Created, Updated: e.Updated}, nil
+}
+
+// a secret without owner is anyone's
+func (e entry) usableBy(user string) bool {
+	return e.Owner == "" || e.Owner == user
+}
+
+// the decrypted value, only ever handed to jobs of a user that may use it
+func (s *Store) Get(name, user string) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	e, ok := s.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if !e.usableBy(user) {
+		return nil, fmt.Errorf("%w: %s", ErrNotOwner, name)
+	}
+	return s.aead.Open(nil, e.Nonce, e.Value, []byte(name))
+}
+
+// whether user may hand the secret to a job
+func (s *Store) Check(name, user string) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	e, ok := s.entries[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if !e.usableBy(user) {
+		return fmt.Errorf("%w: %s", ErrNotOwner, name)
+	}
+	return nil
+}
+
+// the secrets user may use sorted by name
+func (s *Store) List(user string) []Info {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	list := make([]Info, 0, len(s.entries))
+	for name, e := range s.entries {
+		if e.usableBy(user) {
+			list = append(list, Info{Name: name, Owner: e.Owner, Created: e.Created, Updated: e.Updated})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (s *Store) Delete(name, user string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e, ok := s.entries[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if !e.usableBy(user) {
+		return fmt.Errorf("%w: %s", ErrNotOwner, name)
+	}
+	delete(s.entries, name)
+	if err := s.save(); err != nil {
+		s.entries[name] = e
+		return err
+	}
+	return nil
+}
+
+// write to a temporary file and rename it, caller holds the lock
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
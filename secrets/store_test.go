@@ -0,0 +1,86 @@
+package secrets
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func openTestStore(t *testing.T) (*Store, string, []byte) {
+	t.Helper()
+	dir := t.TempDir()
+	key, err := LoadKey(filepath.Join(dir, "secret.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "secrets.json")
+	s, err := Open(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path, key
+}
+
+func TestStoreOwners(t *testing.T) {
+	s, _, _ := openTestStore(t)
+	if _, err := s.Put("db", []byte("alice's"), "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put("shared", []byte("anyone's"), ""); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, user string
+		err        error
+	}{
+		{name: "db", user: "alice"},
+		{name: "db", user: "bob", err: ErrNotOwner},
+		{name: "db", user: "", err: ErrNotOwner},
+		{name: "shared", user: "bob"},
+		{name: "shared", user: ""},
+		{name: "missing", user: "alice", err: ErrNotFound},
+	}
+	for _, tt := range tests {
+		if err := s.Check(tt.name, tt.user); !errors.Is(err, tt.err) {
+			t.Errorf("Check(%s, %q) = %v, want %v", tt.name, tt.user, err, tt.err)
+		}
+		if _, err := s.Get(tt.name, tt.user); !errors.Is(err, tt.err) {
+			t.Errorf("Get(%s, %q) = %v, want %v", tt.name, tt.user, err, tt.err)
+		}
+	}
+	if _, err := s.Put("db", []byte("bob's"), "bob"); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("bob replacing alice's secret = %v, want ErrNotOwner", err)
+	}
+	if err := s.Delete("db", "bob"); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("bob deleting alice's secret = %v, want ErrNotOwner", err)
+	}
+	// a replaced secret keeps its owner
+	info, err := s.Put("db", []byte("new"), "alice")
+	if err != nil || info.Owner != "alice" {
+		t.Errorf("Put by the owner = %+v, %v", info, err)
+	}
+	if list := s.List("bob"); len(list) != 1 || list[0].Name != "shared" {
+		t.Errorf("List(bob) = %+v, want only the shared secret", list)
+	}
+	if list := s.List("alice"); len(list) != 2 {
+		t.Errorf("List(alice) = %+v, want both secrets", list)
+	}
+}
+
+func TestStoreReopen(t *testing.T) {
+	s, path, key := openTestStore(t)
+	if _, err := s.Put("db", []byte("value"), "alice"); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := s.Get("db", "alice"); err != nil || string(value) != "value" {
+		t.Errorf("Get after reopening = %q, %v", value, err)
+	}
+	other := make([]byte, keySize)
+	if _, err := Open(path, other); err == nil {
+		t.Error("opened the store with another key")
+	}
+}
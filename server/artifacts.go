@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"main/logging"
+	pb "main/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// size of the chunks DownloadArtifact sends
+const artifactChunkSize = 64 << 10
+
+func (s *server) ListArtifacts(ctx context.Context, in *pb.JobID) (*pb.ArtifactList, error) {
+	if registry != nil {
+		w, err := jobWorker(ctx, in.Id)
+		if err != nil {
+			return nil, err
+		}
+		return w.Client.ListArtifacts(ctx, in)
+	}
+	artifacts, err := jobDispatcher.Artifacts(ctx, in.Id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	list := &pb.ArtifactList{}
+	for _, artifact := range artifacts {
+		list.Artifacts = append(list.Artifacts, &pb.Artifact{Path: artifact.Path, Size: artifact.Size})
+	}
+	return list, nil
+}
+
+func (s *server) DownloadArtifact(in *pb.ArtifactRequest, stream pb.JobManager_DownloadArtifactServer) error {
+	logging.FromContext(stream.Context()).Info("received artifact download request", "job_id", in.Id, "path", in.Path, "offset", in.Offset)
+	if registry != nil {
+		return downloadFromWorker(in, stream)
+	}
+	f, artifact, err := jobDispatcher.OpenArtifact(stream.Context(), in.Id, in.Path)
+	if err != nil {
+		return statusError(err)
+	}
+	defer f.Close()
+	if in.Offset < 0 || in.Offset > artifact.Size {
+		return status.Errorf(codes.OutOfRange, "offset %d outside of %s (%d bytes)", in.Offset, in.Path, artifact.Size)
+	}
+	if _, err := f.Seek(in.Offset, io.SeekStart); err != nil {
+		return statusError(err)
+	}
+	buf := make([]byte, artifactChunkSize)
+	offset := in.Offset
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&pb.ArtifactChunk{Data: buf[:n], Offset: offset}); err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return statusError(err)
+		}
+	}
+}
@@ -0,0 +1,752 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"main/auth"
+	"main/cluster"
+	"main/config"
+	"main/core"
+	"main/logging"
+	"main/metrics"
+	pb "main/proto"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// set in main when the server runs as a coordinator, jobs then run on the registered workers
+var registry *cluster.Registry
+
+// role of this server, one of the config.Role constants
+var clusterRole string
+
+// worker: common name of the coordinator's client certificate, empty when any caller is accepted
+var coordinatorName string
+
+// coordinator: common names of the client certificates workers may register with
+var workerNames map[string]bool
+
+type coordinator struct {
+	pb.UnimplementedCoordinatorServer
+}
+
+func (c *coordinator) Register(ctx context.Context, in *pb.WorkerInfo) (*pb.RegisterResponse, error) {
+	if err := checkWorkerCert(ctx); err != nil {
+		return nil, err
+	}
+	in.Address = workerAddress(ctx, in.Address)
+	logging.FromContext(ctx).Info("worker registered", "worker", in.Id, "address", in.Address, "labels", in.Labels, "capacity", in.Capacity)
+	if err := registry.Register(in); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.RegisterResponse{HeartbeatIntervalMillis: int32(registry.HeartbeatInterval().Milliseconds())}, nil
+}
+
+func (c *coordinator) Heartbeat(ctx context.Context, in *pb.WorkerInfo) (*pb.HeartbeatResponse, error) {
+	if err := checkWorkerCert(ctx); err != nil {
+		return nil, err
+	}
+	in.Address = workerAddress(ctx, in.Address)
+	logging.FromContext(ctx).Debug("worker heartbeat", "worker", in.Id, "active", in.Active)
+	revoked, err := registry.Heartbeat(in)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if len(revoked) > 0 {
+		logging.FromContext(ctx).Warn("revoking job leases", "worker", in.Id, "job_ids", revoked)
+	}
+	return &pb.HeartbeatResponse{Revoked: revoked}, nil
+}
+
+func (c *coordinator) ListWorkers(ctx context.Context, in *pb.NilMessage) (*pb.WorkerList, error) {
+	// worker addresses are only for callers the server knows
+	if _, ok := auth.Caller(ctx); !ok {
+		return nil, status.Error(codes.Unauthenticated, "listing workers needs an authenticated caller")
+	}
+	return &pb.WorkerList{Workers: registry.List()}, nil
+}
+
+// only workers with a client certificate named in -worker-names take part
+func checkWorkerCert(ctx context.Context) error {
+	name, ok := auth.CertName(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "workers need a client certificate")
+	}
+	if !workerNames[name] {
+		return status.Errorf(codes.PermissionDenied, "%q is not a worker of this coordinator", name)
+	}
+	return nil
+}
+
+// a worker advertising ":8080" or "0.0.0.0:8080" is reached at the address it connects from
+func workerAddress(ctx context.Context, address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || (host != "" && !net.ParseIP(host).IsUnspecified()) {
+		return address
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return address
+	}
+	peerHost, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return address
+	}
+	return net.JoinHostPort(peerHost, port)
+}
+
+// start in on the worker chosen by its node selector and free capacity
+func startOnWorker(ctx context.Context, in *pb.Job) (*pb.Job, error) {
+	sel, err := core.ParseSelector(in.NodeSelector)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if err := cluster.ValidateRestartPolicy(in.RestartPolicy, in.MaxRestarts); err != nil {
+		return nil, statusError(err)
+	}
+	w, err := registry.Pick(sel)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	caller, _ := auth.Caller(ctx)
+	res, err := w.Client.Start(withCaller(ctx, caller), in)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to start the job on a worker", "job_id", in.ID, "worker", w.ID, "err", err)
+		return nil, err
+	}
+	registry.Assign(in, w, caller)
+	logging.FromContext(ctx).Info("job assigned", "job_id", res.ID, "worker", w.ID)
+	res.Worker = w.ID
+	return res, nil
+}
+
+// the user a job runs as: the verified caller when the socket or a client
+// certificate tells us who it is, else the claimed user. A worker that knows
+// its coordinator keeps the user the coordinator verified and takes jobs from
+// nobody else.
+func jobUser(ctx context.Context, claimed string) (string, error) {
+	if fromCoordinator(ctx) {
+		return claimed, nil
+	}
+	if clusterRole == config.RoleWorker && coordinatorName != "" {
+		return "", status.Error(codes.PermissionDenied, "this worker only takes jobs from its coordinator")
+	}
+	if user, ok := auth.Caller(ctx); ok {
+		return user, nil
+	}
+	return claimed, nil
+}
+
+// metadata telling a worker who the coordinator verified, a job's user may
+// only be claimed; the worker checks secrets against it
+const callerHeader = "x-jobserver-caller"
+
+func withCaller(ctx context.Context, caller string) context.Context {
+	if caller == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, callerHeader, caller)
+}
+
+// the caller the coordinator verified, empty for an anonymous one or
+// when not called by the coordinator
+func coordinatorCaller(ctx context.Context) string {
+	if !fromCoordinator(ctx) {
+		return ""
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(callerHeader); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// the verified caller, on a call from the coordinator the one it verified;
+// empty for an anonymous one
+func verifiedCaller(ctx context.Context) string {
+	if fromCoordinator(ctx) {
+		return coordinatorCaller(ctx)
+	}
+	user, _ := auth.Caller(ctx)
+	return user
+}
+
+// whether a worker is called by the coordinator it was configured with
+func fromCoordinator(ctx context.Context) bool {
+	if clusterRole != config.RoleWorker || coordinatorName == "" {
+		return false
+	}
+	name, ok := auth.CertName(ctx)
+	return ok && name == coordinatorName
+}
+
+// where a job runs, asking the live workers when the coordinator doesn't
+// know the job, e.g. because the coordinator restarted
+func locateJob(ctx context.Context, jobId string) (cluster.Placement, error) {
+	if placement, ok := registry.Locate(jobId); ok {
+		return placement, nil
+	}
+	for _, w := range registry.Alive() {
+		if _, err := w.Client.Query(ctx, &pb.JobID{Id: jobId}); err == nil {
+			registry.Adopt(jobId, w)
+			return cluster.Placement{Worker: w}, nil
+		}
+	}
+	return cluster.Placement{}, statusError(core.ErrJobNotFound)
+}
+
+// the worker running a job, ErrJobLost while the job is lost
+func jobWorker(ctx context.Context, jobId string) (*cluster.Worker, error) {
+	placement, err := locateJob(ctx, jobId)
+	if err != nil {
+		return nil, err
+	}
+	if placement.Lost != nil {
+		return nil, statusError(fmt.Errorf("%w: %s", cluster.ErrJobLost, jobId))
+	}
+	return placement.Worker, nil
+}
+
+func queryOnWorker(ctx context.Context, in *pb.JobID) (*pb.JobStatus, error) {
+	placement, err := locateJob(ctx, in.Id)
+	if err != nil {
+		return nil, err
+	}
+	if placement.Lost != nil {
+		return placement.Lost, nil
+	}
+	res, err := placement.Worker.Client.Query(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	res.Job.Worker = placement.Worker.ID
+	res.Job.Restarts = placement.Restarts
+	return res, nil
+}
+
+// stopping a lost job cancels its restart
+func stopOnWorker(ctx context.Context, in *pb.JobID) (*pb.NilMessage, error) {
+	placement, err := locateJob(ctx, in.Id)
+	if err != nil {
+		return nil, err
+	}
+	if placement.Lost != nil {
+		if err := registry.CancelRestart(in.Id); err != nil {
+			return nil, statusError(err)
+		}
+		return &pb.NilMessage{}, nil
+	}
+	return placement.Worker.Client.Stop(ctx, in)
+}
+
+// the jobs of every live worker, a worker that fails to answer is left out
+func listOnWorkers(ctx context.Context, in *pb.ListRequest) (*pb.JobStatusList, error) {
+	sel, err := core.ParseSelector(in.Selector)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	list := &pb.JobStatusList{JobStatusList: registry.LostJobs(sel)}
+	for _, w := range registry.Alive() {
+		res, err := w.Client.List(ctx, in)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to list the jobs of a worker", "worker", w.ID, "err", err)
+			continue
+		}
+		for _, jobStatus := range res.JobStatusList {
+			jobStatus.Job.Worker = w.ID
+			if placement, ok := registry.Locate(jobStatus.Job.ID); ok {
+				if placement.Worker != w {
+					// an old copy on a worker that lost the lease, it is stopped on its next heartbeat
+					continue
+				}
+				jobStatus.Job.Restarts = placement.Restarts
+			} else {
+				registry.Adopt(jobStatus.Job.ID, w)
+			}
+			list.JobStatusList = append(list.JobStatusList, jobStatus)
+		}
+	}
+	sort.Slice(list.JobStatusList, func(i, j int) bool {
+		return list.JobStatusList[i].Job.ID < list.JobStatusList[j].Job.ID
+	})
+	return list, nil
+}
+
+// search the worker running the job, or every live worker and merge their matches
+func searchOnWorkers(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	if in.Id != "" {
+		w, err := jobWorker(ctx, in.Id)
+		if err != nil {
+			return nil, err
+		}
+		return w.Client.Search(ctx, in)
+	}
+	if _, err := core.ParseSelector(in.Selector); err != nil {
+		return nil, statusError(err)
+	}
+	limit := int(in.MaxMatches)
+	if limit <= 0 {
+		limit = core.DefaultSearchMatches
+	}
+	limit = min(limit, core.MaxSearchMatches)
+	res := &pb.SearchResponse{}
+	for _, w := range registry.Alive() {
+		part, err := w.Client.Search(ctx, in)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to search the output of a worker", "worker", w.ID, "err", err)
+			continue
+		}
+		res.JobsSearched += part.JobsSearched
+		res.Truncated = res.Truncated || part.Truncated
+		for _, m := range part.Matches {
+			// skip old copies on a worker that lost the lease
+			if placement, ok := registry.Locate(m.Id); ok && placement.Worker != w {
+				continue
+			}
+			res.Matches = append(res.Matches, m)
+		}
+	}
+	sort.SliceStable(res.Matches, func(i, j int) bool { return res.Matches[i].Id < res.Matches[j].Id })
+	if len(res.Matches) > limit {
+		res.Matches = res.Matches[:limit]
+		res.Truncated = true
+	}
+	return res, nil
+}
+
+// delete the job on its worker, or forget it when it was lost
+func deleteOnWorker(ctx context.Context, in *pb.JobID) (*pb.NilMessage, error) {
+	placement, err := locateJob(ctx, in.Id)
+	if err != nil {
+		return nil, err
+	}
+	if placement.Lost == nil {
+		if _, err := placement.Worker.Client.Delete(ctx, in); err != nil {
+			return nil, err
+		}
+	}
+	registry.Forget(in.Id)
+	return &pb.NilMessage{}, nil
+}
+
+// wait on the workers running the jobs at the same time; with mode any the
+// first job to finish on any of them ends the wait. A lost job fails the
+// request, it can't finish until it is restarted.
+func waitOnWorkers(ctx context.Context, in *pb.WaitRequest, mode string) (*pb.WaitResponse, error) {
+	byWorker := make(map[*cluster.Worker][]string)
+	for _, id := range in.Ids {
+		w, err := jobWorker(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		byWorker[w] = append(byWorker[w], id)
+	}
+	type result struct {
+		res *pb.WaitResponse
+		err error
+	}
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan result, len(byWorker))
+	for w, ids := range byWorker {
+		go func(w *cluster.Worker, ids []string) {
+			res, err := w.Client.Wait(waitCtx, &pb.WaitRequest{Ids: ids, Mode: mode, TimeoutSeconds: in.TimeoutSeconds})
+			if err == nil {
+				for _, jobStatus := range res.JobStatusList {
+					jobStatus.Job.Worker = w.ID
+				}
+			}
+			results <- result{res: res, err: err}
+		}(w, ids)
+	}
+	statuses := make(map[string]*pb.JobStatus)
+	finished, timedOut := false, false
+	for range byWorker {
+		r := <-results
+		if r.err != nil {
+			// the other workers are cancelled once a job finished with mode any
+			if finished && waitCtx.Err() != nil {
+				continue
+			}
+			return nil, r.err
+		}
+		for _, jobStatus := range r.res.JobStatusList {
+			statuses[jobStatus.Job.ID] = jobStatus
+		}
+		timedOut = timedOut || r.res.TimedOut
+		if mode == core.WaitAny && !r.res.TimedOut {
+			finished = true
+			cancel()
+		}
+	}
+	if mode == core.WaitAny {
+		timedOut = !finished
+	}
+	res := &pb.WaitResponse{TimedOut: timedOut}
+	for _, id := range in.Ids {
+		jobStatus := statuses[id]
+		if jobStatus == nil {
+			// its worker was cancelled, report where it is now
+			var err error
+			if jobStatus, err = queryOnWorker(ctx, &pb.JobID{Id: id}); err != nil {
+				return nil, err
+			}
+		}
+		res.JobStatusList = append(res.JobStatusList, jobStatus)
+	}
+	return res, nil
+}
+
+// merge the watch streams of every live worker, after an added event for each
+// matching lost job; workers registering later are not watched, and the watch
+// ends when the stream of any worker does
+func watchOnWorkers(in *pb.ListRequest, stream pb.JobManager_WatchServer) error {
+	sel, err := core.ParseSelector(in.Selector)
+	if err != nil {
+		return statusError(err)
+	}
+	for _, jobStatus := range registry.LostJobs(sel) {
+		if err := stream.Send(&pb.WatchEvent{Type: core.WatchAdded, JobStatus: jobStatus}); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	workers := registry.Alive()
+	events := make(chan *pb.WatchEvent)
+	errs := make(chan error, len(workers))
+	for _, w := range workers {
+		go func(w *cluster.Worker) {
+			from, err := w.Client.Watch(ctx, in)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for {
+				event, err := from.Recv()
+				if errors.Is(err, io.EOF) {
+					errs <- status.Errorf(codes.Unavailable, "worker %s stopped watching", w.ID)
+					return
+				} else if err != nil {
+					errs <- err
+					return
+				}
+				if job := event.JobStatus.GetJob(); job != nil {
+					// skip old copies on a worker that lost the lease
+					if placement, ok := registry.Locate(job.ID); ok && placement.Worker != w {
+						continue
+					}
+					job.Worker = w.ID
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(w)
+	}
+	for {
+		select {
+		case event := <-events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// stop the matching jobs on every live worker and cancel the restart of the
+// matching lost ones, a worker that fails to answer is left out
+func stopMatchingOnWorkers(ctx context.Context, in *pb.ListRequest, sel core.Selector) (*pb.JobStatusList, error) {
+	list := &pb.JobStatusList{}
+	for _, jobStatus := range registry.LostJobs(sel) {
+		if registry.CancelRestart(jobStatus.Job.ID) == nil {
+			list.JobStatusList = append(list.JobStatusList, jobStatus)
+		}
+	}
+	for _, w := range registry.Alive() {
+		res, err := w.Client.StopMatching(ctx, in)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to stop the jobs of a worker", "worker", w.ID, "err", err)
+			continue
+		}
+		for _, jobStatus := range res.JobStatusList {
+			jobStatus.Job.Worker = w.ID
+			list.JobStatusList = append(list.JobStatusList, jobStatus)
+		}
+	}
+	sort.Slice(list.JobStatusList, func(i, j int) bool {
+		return list.JobStatusList[i].Job.ID < list.JobStatusList[j].Job.ID
+	})
+	return list, nil
+}
+
+// add the jobs of every live worker and the lost jobs to the coordinator's status
+func statusOnWorkers(ctx context.Context, res *pb.ServerStatus) {
+	for _, w := range registry.Alive() {
+		part, err := w.Client.Status(ctx, &pb.NilMessage{})
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to get the status of a worker", "worker", w.ID, "err", err)
+			continue
+		}
+		for state, n := range part.JobsByState {
+			res.JobsByState[state] += n
+		}
+		res.QueueDepth += part.QueueDepth
+	}
+	if lost := len(registry.LostJobs(nil)); lost > 0 {
+		res.JobsByState[cluster.Lost] += int32(lost)
+	}
+}
+
+// drain every live worker at the same time, the coordinator itself already
+// refuses new jobs; the first error is returned once all of them are done
+func drainOnWorkers(ctx context.Context, in *pb.DrainRequest) (*pb.DrainResponse, error) {
+	workers := registry.Alive()
+	type result struct {
+		res *pb.DrainResponse
+		err error
+	}
+	results := make(chan result, len(workers))
+	for _, w := range workers {
+		go func(w *cluster.Worker) {
+			res, err := w.Client.Drain(ctx, in)
+			if err != nil {
+				logging.FromContext(ctx).Warn("failed to drain a worker", "worker", w.ID, "err", err)
+			}
+			results <- result{res: res, err: err}
+		}(w)
+	}
+	res := &pb.DrainResponse{}
+	var firstErr error
+	for range workers {
+		r := <-results
+		if r.err != nil {
+			firstErr = cmp.Or(firstErr, r.err)
+			continue
+		}
+		res.ActiveJobs += r.res.ActiveJobs
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return res, nil
+}
+
+// add up what the user's jobs use on every live worker; each worker enforces
+// the limits on its own jobs, so the limits are the ones of a single worker
+func quotaOnWorkers(ctx context.Context, in *pb.QuotaRequest) (*pb.QuotaReport, error) {
+	report := &pb.QuotaReport{}
+	byScope := make(map[[2]string]*pb.QuotaUsage)
+	for _, w := range registry.Alive() {
+		part, err := w.Client.Quota(ctx, in)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to get the quotas of a worker", "worker", w.ID, "err", err)
+			continue
+		}
+		for _, usage := range part.Quotas {
+			key := [2]string{usage.User, usage.Group}
+			total := byScope[key]
+			if total == nil {
+				byScope[key] = usage
+				report.Quotas = append(report.Quotas, usage)
+				continue
+			}
+			total.Running += usage.Running
+			total.Queued += usage.Queued
+			total.CpuSeconds += usage.CpuSeconds
+			total.OutputBytes += usage.OutputBytes
+		}
+	}
+	return report, nil
+}
+
+// relay an artifact download from the worker running the job
+func downloadFromWorker(in *pb.ArtifactRequest, stream pb.JobManager_DownloadArtifactServer) error {
+	w, err := jobWorker(stream.Context(), in.Id)
+	if err != nil {
+		return err
+	}
+	from, err := w.Client.DownloadArtifact(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+	for {
+		chunk, err := from.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+	}
+}
+
+// relay the output stream of the worker running the job
+func streamFromWorker(in *pb.OutputRequest, stream pb.JobManager_StreamOutputServer) error {
+	w, err := jobWorker(stream.Context(), in.Id)
+	if err != nil {
+		return err
+	}
+	from, err := w.Client.StreamOutput(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+	for {
+		output, err := from.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := stream.Send(output); err != nil {
+			return err
+		}
+	}
+}
+
+// methods a coordinator doesn't serve: secrets are kept by each worker, which
+// checks the secrets of its jobs against its own store, so they are put on the
+// workers directly
+var coordinatorUnsupported = map[string]bool{
+	pb.JobManager_PutSecret_FullMethodName:    true,
+	pb.JobManager_ListSecrets_FullMethodName:  true,
+	pb.JobManager_DeleteSecret_FullMethodName: true,
+}
+
+func coordinatorAllows(method string) error {
+	if !coordinatorUnsupported[method] {
+		return nil
+	}
+	return status.Errorf(codes.Unimplemented, "%s is not available on a coordinator, call the workers directly", method)
+}
+
+func coordinatorUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := coordinatorAllows(info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func coordinatorStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := coordinatorAllows(info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// mark the jobs of silent workers lost and start the ones allowed to restart
+// on a healthy worker, until ctx is done
+func superviseLeases(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, jobStatus := range registry.Expire() {
+			metrics.JobsLost.Inc()
+			slog.Warn("job lost", "job_id", jobStatus.Job.ID, "worker", jobStatus.Job.Worker, "restart_policy", jobStatus.Job.RestartPolicy)
+		}
+		for _, job := range registry.Pending() {
+			restartJob(ctx, job, interval)
+		}
+	}
+}
+
+// start a lost job again, it stays pending for the next round when no worker takes it
+func restartJob(ctx context.Context, job *pb.Job, timeout time.Duration) {
+	sel, _ := core.ParseSelector(job.NodeSelector)
+	w, err := registry.Pick(sel)
+	if err != nil {
+		slog.Warn("no worker to restart a lost job on", "job_id", job.ID, "err", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	job.Worker = ""
+	job.State = ""
+	if _, err := w.Client.Start(withCaller(ctx, registry.Caller(job.ID)), job); err != nil {
+		slog.Warn("failed to restart a lost job", "job_id", job.ID, "worker", w.ID, "err", err)
+		return
+	}
+	if !registry.Restarted(job.ID, w) {
+		// stopped while we were starting it
+		w.Client.Stop(ctx, &pb.JobID{Id: job.ID})
+		return
+	}
+	metrics.JobsRestarted.Inc()
+	slog.Info("lost job restarted", "job_id", job.ID, "worker", w.ID)
+}
+
+// register this worker with the configured coordinator and keep reporting its load
+func runAgent(ctx context.Context, cfg config.ServerConfig) error {
+	id, address, err := cfg.WorkerAddress()
+	if err != nil {
+		return err
+	}
+	labels, err := cfg.WorkerLabels()
+	if err != nil {
+		return err
+	}
+	creds, err := cfg.Cluster.TLS.Credentials()
+	if err != nil {
+		return err
+	}
+	// reconnect quickly after a coordinator restart instead of backing off for minutes
+	connectParams := grpc.ConnectParams{Backoff: backoff.DefaultConfig, MinConnectTimeout: 5 * time.Second}
+	connectParams.Backoff.MaxDelay = 10 * time.Second
+	conn, err := grpc.NewClient(cfg.Cluster.Coordinator, grpc.WithTransportCredentials(creds), grpc.WithConnectParams(connectParams))
+	if err != nil {
+		return err
+	}
+	// tells the coordinator that jobs from before a restart are gone
+	incarnation := uuid.New().String()
+	slog.Info("worker agent started", "worker", id, "coordinator", cfg.Cluster.Coordinator, "address", address)
+	go func() {
+		defer conn.Close()
+		cluster.RunAgent(ctx, pb.NewCoordinatorClient(conn), func() *pb.WorkerInfo {
+			jobs := jobDispatcher.ActiveJobIDs()
+			return &pb.WorkerInfo{
+				Id:          id,
+				Address:     address,
+				Labels:      labels,
+				Capacity:    int32(cfg.Jobs.MaxConcurrent),
+				Active:      int32(len(jobs)),
+				Jobs:        jobs,
+				Incarnation: incarnation,
+			}
+		}, func(jobIds []string) {
+			for _, jobId := range jobIds {
+				if err := jobDispatcher.StopJob(jobId); err != nil && !errors.Is(err, core.ErrJobNotRunning) {
+					slog.Error("failed to stop a job with a revoked lease", "job_id", jobId, "err", err)
+				}
+			}
+		})
+	}()
+	return nil
+}
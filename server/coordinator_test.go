@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"main/config"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckWorkerCert(t *testing.T) {
+	defer func(names map[string]bool) { workerNames = names }(workerNames)
+	workerNames = map[string]bool{"worker-1": true}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+	}{
+		{name: "worker", ctx: certContext("worker-1"), code: codes.OK},
+		{name: "other certificate", ctx: certContext("worker-2"), code: codes.PermissionDenied},
+		{name: "no certificate", ctx: context.Background(), code: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		if err := checkWorkerCert(tt.ctx); status.Code(err) != tt.code {
+			t.Errorf("%s: checkWorkerCert() = %v, want code %s", tt.name, err, tt.code)
+		}
+	}
+}
+
+func TestJobUser(t *testing.T) {
+	defer func(role, name string) { clusterRole, coordinatorName = role, name }(clusterRole, coordinatorName)
+	tests := []struct {
+		name string
+		role string
+		ctx  context.Context
+		user string
+		code codes.Code
+	}{
+		{name: "anonymous", ctx: context.Background(), user: "claimed"},
+		{name: "certificate", ctx: certContext("alice"), user: "alice"},
+		{name: "from coordinator", role: config.RoleWorker, ctx: certContext("coord"), user: "claimed"},
+		{name: "around coordinator", role: config.RoleWorker, ctx: certContext("alice"), code: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		clusterRole, coordinatorName = tt.role, ""
+		if tt.role == config.RoleWorker {
+			coordinatorName = "coord"
+		}
+		user, err := jobUser(tt.ctx, "claimed")
+		if user != tt.user || status.Code(err) != tt.code {
+			t.Errorf("%s: jobUser() = %q, %v, want %q and code %s", tt.name, user, err, tt.user, tt.code)
+		}
+	}
+}
+
+func TestWorkerAddress(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 7), Port: 41000}})
+	tests := []struct{ address, want string }{
+		{address: ":8080", want: "10.0.0.7:8080"},
+		{address: "0.0.0.0:8080", want: "10.0.0.7:8080"},
+		{address: "worker-1:8080", want: "worker-1:8080"},
+		{address: "10.0.0.9:8080", want: "10.0.0.9:8080"},
+	}
+	for _, tt := range tests {
+		if got := workerAddress(ctx, tt.address); got != tt.want {
+			t.Errorf("workerAddress(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"main/cluster"
+	"main/core"
+	"main/secrets"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpc status for an error returned by the dispatcher
+func statusError(err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, core.ErrInvalidJobID), errors.Is(err, core.ErrInvalidJob), errors.Is(err, core.ErrInvalidLabel),
+		errors.Is(err, core.ErrInvalidSelector), errors.Is(err, core.ErrInvalidTemplate), errors.Is(err, core.ErrInvalidParams),
+		errors.Is(err, secrets.ErrInvalidName), errors.Is(err, core.ErrInvalidSignal), errors.Is(err, cluster.ErrInvalidWorker),
+		errors.Is(err, core.ErrInvalidSearch):
+		code = codes.InvalidArgument
+	case errors.Is(err, core.ErrSignalNotAllowed), errors.Is(err, secrets.ErrNotOwner), errors.Is(err, core.ErrNotTemplateOwner):
+		code = codes.PermissionDenied
+	case errors.Is(err, core.ErrJobNotFound), errors.Is(err, core.ErrTemplateNotFound), errors.Is(err, core.ErrArtifactNotFound),
+		errors.Is(err, secrets.ErrNotFound), errors.Is(err, cluster.ErrUnknownWorker):
+		code = codes.NotFound
+	case errors.Is(err, core.ErrTemplateExists), errors.Is(err, core.ErrJobExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, core.ErrJobNotRunning), errors.Is(err, core.ErrJobActive), errors.Is(err, core.ErrJobNotPaused),
+		errors.Is(err, core.ErrPauseUnsupported), errors.Is(err, core.ErrJobFreezing), errors.Is(err, cluster.ErrJobLost):
+		code = codes.FailedPrecondition
+	case errors.Is(err, core.ErrDraining), errors.Is(err, cluster.ErrNoWorker):
+		code = codes.Unavailable
+	case errors.Is(err, core.ErrQuotaExceeded):
+		code = codes.ResourceExhausted
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,618 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"main/core"
+	pb "main/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// http/json front end of the JobManager service
+// every request runs through the same interceptors and handlers as its grpc counterpart
+type gateway struct {
+	srv *server
+}
+
+func newGateway(srv *server) http.Handler {
+	g := &gateway{srv: srv}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/jobs", g.start)
+	mux.HandleFunc("GET /v1/jobs", g.list)
+	mux.HandleFunc("POST /v1/jobs/stop", g.stopMatching)
+	mux.HandleFunc("GET /v1/watch", g.watch)
+	mux.HandleFunc("GET /v1/jobs/{id}", g.query)
+	mux.HandleFunc("POST /v1/jobs/{id}/stop", g.stop)
+	mux.HandleFunc("POST /v1/jobs/{id}/signal", g.signal)
+	mux.HandleFunc("POST /v1/jobs/{id}/pause", g.pause)
+	mux.HandleFunc("POST /v1/jobs/{id}/resume", g.resume)
+	mux.HandleFunc("DELETE /v1/jobs/{id}", g.delete)
+	mux.HandleFunc("GET /v1/jobs/{id}/output", g.output)
+	mux.HandleFunc("GET /v1/jobs/{id}/artifacts", g.listArtifacts)
+	mux.HandleFunc("GET /v1/jobs/{id}/artifacts/{path...}", g.downloadArtifact)
+	mux.HandleFunc("POST /v1/wait", g.wait)
+	mux.HandleFunc("PUT /v1/secrets/{name}", g.putSecret)
+	mux.HandleFunc("GET /v1/secrets", g.listSecrets)
+	mux.HandleFunc("DELETE /v1/secrets/{name}", g.deleteSecret)
+	mux.HandleFunc("POST /v1/templates", g.createTemplate)
+	mux.HandleFunc("GET /v1/templates", g.listTemplates)
+	mux.HandleFunc("GET /v1/templates/{name}", g.getTemplate)
+	mux.HandleFunc("PUT /v1/templates/{name}", g.updateTemplate)
+	mux.HandleFunc("DELETE /v1/templates/{name}", g.deleteTemplate)
+	mux.HandleFunc("POST /v1/templates/{name}/start", g.startTemplate)
+	mux.HandleFunc("GET /v1/quota", g.quota)
+	mux.HandleFunc("POST /v1/search", g.search)
+	return mux
+}
+
+func (g *gateway) start(w http.ResponseWriter, r *http.Request) {
+	in := &pb.Job{}
+	if err := decodeBody(r, in); err != nil {
+		writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	g.unary(w, r, pb.JobManager_Start_FullMethodName, in, func(ctx context.Context, req any) (any, error) {
+		return g.srv.Start(ctx, req.(*pb.Job))
+	})
+}
+
+// ?selector= filters by labels, see ListRequest
+func (g *gateway) list(w http.ResponseWriter, r *http.Request) {
+	in := &pb.ListRequest{Selector: r.URL.Query().Get("selector")}
+	g.unary(w, r, pb.JobManager_List_FullMethodName, in, func(ctx context.Context, req any) (any, error) {
+		return g.srv.List(ctx, req.(*pb.ListRequest))
+	})
+}
+
+func (g *gateway) stopMatching(w http.ResponseWriter, r *http.Request) {
+	in := &pb.ListRequest{Selector: r.URL.Query().Get("selector")}
+	g.unary(w, r, pb.JobManager_StopMatching_FullMethodName, in, func(ctx context.Context, req any) (any, error) {
+		return g.srv.StopMatching(ctx, req.(*pb.ListRequest))
+	})
+}
+
+func (g *gateway) query(w http.ResponseWriter, r *http.Request) {
+	g.unary(w, r, pb.JobManager_Query_FullMethodName, &pb.JobID{Id: r.PathValue("id")}, func(ctx context.Context, req any) (any, error) {
+		return g.srv.Query(ctx, req.(*pb.JobID))
+	})
+}
+
+func (g *gateway) stop(w http.ResponseWriter, r *http.Request) {
+	g.unary(w, r, pb.JobManager_Stop_FullMethodName, &pb.JobID{Id: r.PathValue("id")}, func(ctx context.Context, req any) (any, error) {
+		return g.srv.Stop(ctx, req.(*pb.JobID))
+	})
+}
+
+// the body is a SignalRequest without the id, e.g. {"signal": "HUP"}
+func (g *gateway) signal(w http.ResponseWriter, r *http.Request) {
+	in := &pb.SignalRequest{}
+	if err := decodeBody(r, in); err != nil {
+		writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	in.Id = r.PathValue("id")
+	g.unary(w, r, pb.JobManager_Signal_FullMethodName, in, func(ctx context.Context, req any) (any, error) {
+		return g.srv.Signal(ctx, req.(*pb.SignalRequest))
+	})
+}
+
+func (g *gateway) pause(w http.ResponseWriter, r *http.Request) {
+	g.unary(w, r, pb.JobManager_Pause_FullMethodName, &pb.JobID{Id: r.PathValue("id")}, func(ctx context.Context, req any) (any, error) {
+		return g.srv.Pause(ctx, req.(*pb.JobID))
+	})
+}
+
+func (g *gateway) resume(w http.ResponseWriter, r *http.Request) {
+	g.unary(w, r, pb.JobManager_Resume_FullMethodName, &pb.JobID{Id: r.PathValue("id")}, func(ctx context.Context, req any) (any, error) {
+		return g.srv.Resume(ctx, req.(*pb.JobID))
+	})
+}
+
+func (g *gateway) delete(w http.ResponseWriter, r *http.Request) {
+	g.unary(w, r, pb.JobManager_Delete_FullMethodName, &pb.JobID{Id: r.PathValue("id")}, func(ctx context.Context, req any) (any, error) {
+		return g.srv.Delete(ctx, req.(*pb.JobID))
+	})
+}
+
+func (g *gateway) listArtifacts(w http.ResponseWriter, r *http.Request) {
+	g.unary(w, r, pb.JobManager_ListArtifacts_FullMethodName, &pb.JobID{Id: r.PathValue("id")}, func(ctx context.Context, req any) (any, error) {
+		return g.srv.ListArtifacts(ctx, req.(*pb.JobID))
+	})
+}
+
+// the artifact as the response body, ?offset= skips bytes like ArtifactRequest.offset
+func (g *gateway) downloadArtifact(w http.ResponseWriter, r *http.Request) {
+	in := &pb.ArtifactRequest{Id: r.PathValue("id"), Path: r.PathValue("path")}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, status.Errorf(codes.InvalidArgument, "invalid offset: %v", err))
+			return
+		}
+		in.Offset = offset
+	}
+	ss := &bodyStream{ctx: grpcContext(r), w: w}
+	info := &grpc.StreamServerInfo{FullMethod: pb.JobManager_DownloadArtifact_FullMethodName, IsServerStream: true}
+	err := chainStream(streamInterceptors)(g.srv, ss, info, func(srv any, stream grpc.ServerStream) error {
+		return g.srv.DownloadArtifact(in, &grpc.GenericServerStream[pb.ArtifactRequest, pb.ArtifactChunk]{ServerStream: stream})
+	})
+	if err != nil && !ss.started {
+		writeError(w, err)
+	} else if !ss.started {
+		// an empty file
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// the body is a Secret, its value base64 encoded as protojson does for bytes
+func (g *gateway) putSecret(w http.ResponseWriter, r *http.Request) {
+	in := &pb.Secret{}
+	if err := decodeBody(r, in); err != nil {
+		writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	in.Name = r.PathValue("name")
+	g.unary(w, r, pb.JobManager_PutSecret_FullMethodName, in, func(ctx context.Context, req any) (any, error) {
+		return g.srv.PutSecret(ctx, req.(*pb.Secret))
+	})
+}
+
+func (g *gateway) listSecrets(w http.ResponseWriter, r *http.Request) {
+	g.unary(w, r, pb.JobManager_ListSecrets_FullMethodName, &pb.NilMessage{}, func(ctx context.Context, req any) (any, error) {
+		return g.srv.ListSecrets(ctx, req.(*pb.NilMessage))
+	})
+}
+
+func (g *gateway) deleteSecret(w http.ResponseWriter, r *http.Request) {
+	g.unary(w, r, pb.JobManager_DeleteSecret_FullMethodName, &pb.SecretName{Name: r.PathValue("name")}, func(ctx context.Context, req any) (any, error) {
+		return g.srv.DeleteSecret(ctx, req.(*pb.SecretName))
+	})
+}
+
+func (g *gateway) search(w http.ResponseWriter, r *http.Request) {
+	in := &pb.SearchRequest{}
+	if err := decodeBody(r, in); err != nil {
+		writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	g.unary(w, r, pb.JobManager_Search_FullMethodName, in, func(ctx context.Context, req any) (any, error) {
+		return g.srv.Search(ctx, req.(*pb.SearchRequest))
+	})
+}
+
+// ?user= chooses the user when the caller is not identified
+func (g *gateway) quota(w http.ResponseWriter, r *http.Request) {
+	in := &pb.QuotaRequest{User: r.URL.Query().Get("user")}
+	g.unary(w, r, pb.JobManager_Quota_FullMethodName, in, func(ctx context.Context, req any) (any, error) {
+		return g.srv.Quota(ctx, req.(*pb.QuotaRequest))
+	})
+}
+
+func (g *gateway) wait(w http.ResponseWriter, r *http.Request) {
+	in := &pb.WaitRequest{}
+	if err := decodeBody(r, in); err != nil {
+		writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	g.unary(w, r, pb.JobManager_Wait_FullMethodName, in, func(ctx context.Context, req any) (any, error) {
+		return g.srv.Wait(ctx, req.(*pb.WaitRequest))
+	})
+}
+
+func (g *gateway) createTemplate(w http.ResponseWriter, r *http.Request) {
+	in := &pb.JobTemplate{}
+	if err := decodeBody(r, in); err != nil {
+		writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	g.unary(w, r, pb.JobManager_CreateTemplate_FullMethodName, in, func(ctx context.Context, req any) (any, error) {
+		return g.srv.CreateTemplate(ctx, req.(*pb.JobTemplate))
+	})
+}
+
+func (g *gateway) listTemplates(w http.ResponseWriter, r *http.Request) {
+	g.unary(w, r, pb.JobManager_ListTemplates_FullMethodName, &pb.NilMessage{}, func(ctx context.Context, req any) (any, error) {
+		return g.srv.ListTemplates(ctx, req.(*pb.NilMessage))
+	})
+}
+
+func (g *gateway) getTemplate(w http.ResponseWriter, r *http.Request) {
+	g.unary(w, r, pb.JobManager_GetTemplate_FullMethodName, &pb.TemplateName{Name: r.PathValue("name")}, func(ctx context.Context, req any) (any, error) {
+		return g.srv.GetTemplate(ctx, req.(*pb.TemplateName))
+	})
+}
+
+// the name in the path wins over one in the body
+func (g *gateway) updateTemplate(w http.ResponseWriter, r *http.Request) {
+	in := &pb.JobTemplate{}
+	if err := decodeBody(r, in); err != nil {
+		writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	in.Name = r.PathValue("name")
+	g.unary(w, r, pb.JobManager_UpdateTemplate_FullMethodName, in, func(ctx context.Context, req any) (any, error) {
+		return g.srv.UpdateTemplate(ctx, req.(*pb.JobTemplate))
+	})
+}
+
+func (g *gateway) deleteTemplate(w http.ResponseWriter, r *http.Request) {
+	g.unary(w, r, pb.JobManager_DeleteTemplate_FullMethodName, &pb.TemplateName{Name: r.PathValue("name")}, func(ctx context.Context, req any) (any, error) {
+		return g.srv.DeleteTemplate(ctx, req.(*pb.TemplateName))
+	})
+}
+
+func (g *gateway) startTemplate(w http.ResponseWriter, r *http.Request) {
+	in := &pb.StartTemplateRequest{}
+	if err := decodeBody(r, in); err != nil {
+		writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	in.Name = r.PathValue("name")
+	g.unary(w, r, pb.JobManager_StartTemplate_FullMethodName, in, func(ctx context.Context, req any) (any, error) {
+		return g.srv.StartTemplate(ctx, req.(*pb.StartTemplateRequest))
+	})
+}
+
+// stream the output of a job as server-sent events, one "output" event per
+// line followed by an "end" event, or an "error" event if the stream fails.
+// Output without a newline comes as "partial" events, the rest of its line
+// follows in further events.
+// Query parameters mirror OutputRequest: offset, tail, since (RFC 3339 time or
+// a duration like 10m) and follow (default true). The event id is the line
+// number, so a reconnecting EventSource resumes through Last-Event-ID.
+func (g *gateway) output(w http.ResponseWriter, r *http.Request) {
+	in, err := outputRequest(r)
+	if err != nil {
+		writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, status.Error(codes.Unimplemented, "streaming is not supported by this connection"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ss := &sseStream{ctx: grpcContext(r), w: w, flusher: flusher}
+	info := &grpc.StreamServerInfo{FullMethod: pb.JobManager_StreamOutput_FullMethodName, IsServerStream: true}
+	err = chainStream(streamInterceptors)(g.srv, ss, info, func(srv any, stream grpc.ServerStream) error {
+		return g.srv.StreamOutput(in, &grpc.GenericServerStream[pb.OutputRequest, pb.JobOutput]{ServerStream: stream})
+	})
+	if err != nil {
+		writeEvent(w, "error", status.Convert(err).Message())
+	} else {
+		writeEvent(w, "end", "")
+	}
+	flusher.Flush()
+}
+
+// watch the jobs matching ?selector= as server-sent events named after the
+// WatchEvent type, the data is the job status as json
+func (g *gateway) watch(w http.ResponseWriter, r *http.Request) {
+	in := &pb.ListRequest{Selector: r.URL.Query().Get("selector")}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, status.Error(codes.Unimplemented, "streaming is not supported by this connection"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ss := &sseStream{ctx: grpcContext(r), w: w, flusher: flusher}
+	info := &grpc.StreamServerInfo{FullMethod: pb.JobManager_Watch_FullMethodName, IsServerStream: true}
+	err := chainStream(streamInterceptors)(g.srv, ss, info, func(srv any, stream grpc.ServerStream) error {
+		return g.srv.Watch(in, &grpc.GenericServerStream[pb.ListRequest, pb.WatchEvent]{ServerStream: stream})
+	})
+	// a watch only ends when the client goes away or the server stops
+	if err != nil && r.Context().Err() == nil {
+		writeEvent(w, "error", status.Convert(err).Message())
+		flusher.Flush()
+	}
+}
+
+func outputRequest(r *http.Request) (*pb.OutputRequest, error) {
+	q := r.URL.Query()
+	in := &pb.OutputRequest{Id: r.PathValue("id"), Follow: true}
+	var err error
+	if v := q.Get("offset"); v != "" {
+		if in.Offset, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid offset: %w", err)
+		}
+	}
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		last, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Last-Event-ID: %w", err)
+		}
+		in.Offset = last + 1
+	}
+	if v := q.Get("tail"); v != "" {
+		tail, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tail: %w", err)
+		}
+		in.Tail = int32(tail)
+	}
+	if v := q.Get("since"); v != "" {
+		since, err := core.ParseSince(v)
+		if err != nil {
+			return nil, err
+		}
+		in.Since = timestamppb.New(since)
+	}
+	if v := q.Get("follow"); v != "" {
+		if in.Follow, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid follow: %w", err)
+		}
+	}
+	return in, nil
+}
+
+// run a handler behind the unary interceptors and write its result as json
+func (g *gateway) unary(w http.ResponseWriter, r *http.Request, method string, req proto.Message, handler grpc.UnaryHandler) {
+	info := &grpc.UnaryServerInfo{Server: g.srv, FullMethod: method}
+	// headers set by the interceptors or the handler, e.g. retry-after, become http headers
+	ts := &headerStream{method: method, w: w}
+	ctx := grpc.NewContextWithServerTransportStream(grpcContext(r), ts)
+	resp, err := chainUnary(unaryInterceptors)(ctx, req, info, handler)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(resp.(proto.Message))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+// context as a grpc handler would see it: the peer carries the tls state so
+// callers are identified the same way on both paths, headers become metadata
+func grpcContext(r *http.Request) context.Context {
+	p := &peer.Peer{Addr: remoteAddr(r)}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS, CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity}}
+	}
+	md := metadata.MD{}
+	for k, v := range r.Header {
+		// only the coordinator names the caller, and it calls over grpc
+		if k = strings.ToLower(k); k == callerHeader {
+			continue
+		}
+		md.Append(k, v...)
+	}
+	return metadata.NewIncomingContext(peer.NewContext(r.Context(), p), md)
+}
+
+func remoteAddr(r *http.Request) net.Addr {
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		return addr
+	}
+	return &net.TCPAddr{}
+}
+
+func decodeBody(r *http.Request, m proto.Message) error {
+	data, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return protojson.Unmarshal(data, m)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus(st.Code()))
+	json.NewEncoder(w).Encode(map[string]string{"code": st.Code().String(), "message": st.Message()})
+}
+
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Canceled:
+		return 499 // client closed request
+	}
+	return http.StatusInternalServerError
+}
+
+// one sse event, multi-line data is split over several data fields
+func writeEvent(w io.Writer, event, data string) {
+	writeEventWithID(w, "", event, data)
+}
+
+// \r\n, \r and \n all end a line in sse
+var lineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+func writeEventWithID(w io.Writer, id, event, data string) {
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\n", event)
+	for _, line := range strings.Split(lineBreaks.Replace(data), "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+// grpc.ServerTransportStream of a gateway call, copying headers to the response
+type headerStream struct {
+	method string
+	w      http.ResponseWriter
+}
+
+func (s *headerStream) Method() string { return s.method }
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	setHTTPHeaders(s.w, md)
+	return nil
+}
+
+func (s *headerStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+func (s *headerStream) SetTrailer(metadata.MD) error    { return nil }
+
+func setHTTPHeaders(w http.ResponseWriter, md metadata.MD) {
+	for k, v := range md {
+		for _, value := range v {
+			w.Header().Add(k, value)
+		}
+	}
+}
+
+// grpc.ServerStream writing every sent message as an sse event: job output
+// as "output" events, watch events named after their type
+type sseStream struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (s *sseStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *sseStream) SendMsg(m any) error {
+	if err := s.ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	switch m := m.(type) {
+	case *pb.JobOutput:
+		event := "output"
+		if !m.Newline {
+			event = "partial"
+		}
+		writeEventWithID(s.w, strconv.FormatInt(m.Line, 10), event, string(m.Output))
+	case *pb.WatchEvent:
+		data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(m.JobStatus)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		writeEvent(s.w, m.Type, string(data))
+	default:
+		return status.Errorf(codes.Internal, "unexpected message %T", m)
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseStream) RecvMsg(any) error {
+	return io.EOF
+}
+
+func (s *sseStream) SetHeader(metadata.MD) error  { return nil }
+func (s *sseStream) SendHeader(metadata.MD) error { return nil }
+func (s *sseStream) SetTrailer(metadata.MD)       {}
+
+// grpc.ServerStream writing artifact chunks to the response body
+// the headers go out with the first chunk, so earlier errors can still become a json error
+type bodyStream struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	started bool
+}
+
+func (s *bodyStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *bodyStream) SendMsg(m any) error {
+	chunk, ok := m.(*pb.ArtifactChunk)
+	if !ok {
+		return status.Errorf(codes.Internal, "unexpected message %T", m)
+	}
+	if !s.started {
+		s.w.Header().Set("Content-Type", "application/octet-stream")
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	if _, err := s.w.Write(chunk.Data); err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return nil
+}
+
+func (s *bodyStream) RecvMsg(any) error {
+	return io.EOF
+}
+
+func (s *bodyStream) SetHeader(md metadata.MD) error {
+	if !s.started {
+		setHTTPHeaders(s.w, md)
+	}
+	return nil
+}
+
+func (s *bodyStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+func (s *bodyStream) SetTrailer(metadata.MD)          {}
+
+// the grpc package chains interceptors internally only, these do the same for the gateway
+func chainUnary(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(ctx context.Context, req any) (any, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+func chainStream(interceptors []grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(srv any, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, next)
+			}
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"main/core"
+	pb "main/proto"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGrpcContextDropsCallerHeader(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/jobs", nil)
+	r.Header.Set("X-Jobserver-Caller", "root")
+	r.Header.Set("X-Request-Id", "42")
+	md, _ := metadata.FromIncomingContext(grpcContext(r))
+	if values := md.Get(callerHeader); len(values) != 0 {
+		t.Errorf("caller header passed on as %q", values)
+	}
+	if values := md.Get("x-request-id"); len(values) != 1 || values[0] != "42" {
+		t.Errorf("x-request-id = %q, want 42", values)
+	}
+}
+
+func TestSSEOutputEvents(t *testing.T) {
+	w := httptest.NewRecorder()
+	ss := &sseStream{ctx: context.Background(), w: w, flusher: w}
+	for _, m := range []*pb.JobOutput{
+		{Output: []byte("Password: "), Line: 0},
+		{Output: []byte("ok"), Line: 1, Newline: true},
+	} {
+		if err := ss.SendMsg(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "id: 0\nevent: partial\ndata: Password: \n\nid: 1\nevent: output\ndata: ok\n\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want int
+	}{
+		{code: codes.OK, want: http.StatusOK},
+		{code: codes.InvalidArgument, want: http.StatusBadRequest},
+		{code: codes.Unauthenticated, want: http.StatusUnauthorized},
+		{code: codes.PermissionDenied, want: http.StatusForbidden},
+		{code: codes.NotFound, want: http.StatusNotFound},
+		{code: codes.AlreadyExists, want: http.StatusConflict},
+		{code: codes.FailedPrecondition, want: http.StatusPreconditionFailed},
+		{code: codes.ResourceExhausted, want: http.StatusTooManyRequests},
+		{code: codes.Unavailable, want: http.StatusServiceUnavailable},
+		{code: codes.DeadlineExceeded, want: http.StatusGatewayTimeout},
+		{code: codes.Canceled, want: 499},
+		{code: codes.Internal, want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := httpStatus(tt.code); got != tt.want {
+			t.Errorf("httpStatus(%s) = %d, want %d", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestOutputRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/jobs/a/output?offset=3&tail=10&follow=false&since=5m", nil)
+	r.SetPathValue("id", "a")
+	in, err := outputRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Id != "a" || in.Offset != 3 || in.Tail != 10 || in.Follow || in.Since == nil {
+		t.Errorf("request = %v", in)
+	}
+
+	// a reconnecting event source picks up after the last event it saw
+	r = httptest.NewRequest("GET", "/v1/jobs/a/output?offset=3", nil)
+	r.Header.Set("Last-Event-ID", "41")
+	if in, err := outputRequest(r); err != nil || in.Offset != 42 || !in.Follow {
+		t.Errorf("request = %v, %v, want offset 42 and follow", in, err)
+	}
+
+	for _, query := range []string{"offset=x", "tail=x", "follow=x", "since=yesterday"} {
+		if _, err := outputRequest(httptest.NewRequest("GET", "/v1/jobs/a/output?"+query, nil)); err == nil {
+			t.Errorf("?%s was accepted", query)
+		}
+	}
+}
+
+func TestGateway(t *testing.T) {
+	defer func(jd *core.JobDispatcher) { jobDispatcher = jd }(jobDispatcher)
+	jobDispatcher = core.NewJobDispatcher()
+	h := newGateway(&server{})
+	do := func(method, target, body string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		var res map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("%s %s: %q is not json", method, target, w.Body)
+		}
+		return w.Code, res
+	}
+
+	code, job := do("POST", "/v1/jobs", `{"cmd": "true", "labels": {"team": "a"}}`)
+	id, _ := job["ID"].(string)
+	if code != http.StatusOK || id == "" || job["State"] != core.Created {
+		t.Fatalf("start = %d %v", code, job)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := jobDispatcher.WaitJobs(ctx, []string{id}, core.WaitAll); err != nil {
+		t.Fatal(err)
+	}
+	code, res := do("GET", "/v1/jobs/"+id, "")
+	if code != http.StatusOK || res["job"].(map[string]any)["State"] != core.Finished {
+		t.Errorf("query = %d %v", code, res)
+	}
+	code, res = do("GET", "/v1/jobs?selector=team%3Da", "")
+	if list, _ := res["jobStatusList"].([]any); code != http.StatusOK || len(list) != 1 {
+		t.Errorf("list = %d %v", code, res)
+	}
+
+	tests := []struct {
+		method, target, body string
+		code                 int
+		grpcCode             codes.Code
+	}{
+		{method: "POST", target: "/v1/jobs", body: `{"cmd": `, code: http.StatusBadRequest, grpcCode: codes.InvalidArgument},
+		{method: "POST", target: "/v1/jobs", body: `{"cmd": "true", "timeoutSeconds": -1}`, code: http.StatusBadRequest, grpcCode: codes.InvalidArgument},
+		{method: "POST", target: "/v1/jobs", body: `{"ID": "` + id + `", "cmd": "true"}`, code: http.StatusConflict, grpcCode: codes.AlreadyExists},
+		{method: "GET", target: "/v1/jobs/a5d4f9b2-6c1e-4d3a-9b7f-2e8c1d0a3b4c", code: http.StatusNotFound, grpcCode: codes.NotFound},
+		{method: "GET", target: "/v1/jobs?selector=team%3Da%2C%2C", code: http.StatusBadRequest, grpcCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		code, res := do(tt.method, tt.target, tt.body)
+		if code != tt.code || res["code"] != tt.grpcCode.String() || res["message"] == "" {
+			t.Errorf("%s %s %s = %d %v, want %d and code %s", tt.method, tt.target, tt.body, code, res, tt.code, tt.grpcCode)
+		}
+	}
+}
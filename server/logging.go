@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"main/auth"
+	"main/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// logger carrying the rpc method and the caller address
+func requestLogger(ctx context.Context, method string) *slog.Logger {
+	caller := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		caller = p.Addr.String()
+	}
+	logger := slog.Default().With("method", method, "caller", caller)
+	if cred, ok := auth.PeerCred(ctx); ok {
+		logger = logger.With("uid", cred.UID, "gid", cred.GID, "pid", cred.PID)
+	}
+	return logger
+}
+
+func loggingUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	logger := requestLogger(ctx, info.FullMethod)
+	resp, err := handler(logging.WithLogger(ctx, logger), req)
+	if err != nil {
+		logger.Warn("rpc failed", "err", err)
+	}
+	return resp, err
+}
+
+// wraps the server stream so handlers see the logger in stream.Context()
+type loggingServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *loggingServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func loggingStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	logger := requestLogger(ss.Context(), info.FullMethod)
+	err := handler(srv, &loggingServerStream{ServerStream: ss, ctx: logging.WithLogger(ss.Context(), logger)})
+	if err != nil {
+		logger.Warn("rpc failed", "err", err)
+	}
+	return err
+}
@@ -2,10 +2,33 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"log/slog"
+	"main/auth"
+	"main/cluster"
+	"main/config"
 	core "main/core"
+	"main/logging"
+	"main/metrics"
 	pb "main/proto"
+	"main/secrets"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 )
 
 type server struct {
@@ -15,82 +38,519 @@ type server struct {
 // global jobDispatcher
 var jobDispatcher = core.NewJobDispatcher()
 
+// shared by the grpc listeners and the http gateway so both paths see the same checks
+var (
+	unaryInterceptors  = []grpc.UnaryServerInterceptor{metricsUnaryInterceptor, rateLimitUnaryInterceptor, loggingUnaryInterceptor}
+	streamInterceptors = []grpc.StreamServerInterceptor{metricsStreamInterceptor, rateLimitStreamInterceptor, loggingStreamInterceptor}
+)
+
 func (s *server) Start(ctx context.Context, in *pb.Job) (*pb.Job, error) {
-	println("Received start request")
+	logging.FromContext(ctx).Info("received start request", "job_id", in.ID, "user", in.User, "labels", in.Labels)
+	return startJob(ctx, in)
+}
+
+// start the job described by in and fill in the server-assigned fields, shared by Start and StartTemplate
+func startJob(ctx context.Context, in *pb.Job) (*pb.Job, error) {
+	user, err := jobUser(ctx, in.User)
+	if err != nil {
+		return nil, err
+	}
+	in.User = user
+	if in.ID == "" {
+		in.ID = uuid.New().String()
+	}
 	// map the input to core.Job
 	job := core.Job{
-		ID:    in.ID,
-		Cmd:   in.Cmd,
-		User:  in.User,
-		State: in.State,
+		ID:          in.ID,
+		Cmd:         in.Cmd,
+		User:        in.User,
+		Caller:      verifiedCaller(ctx),
+		State:       in.State,
+		Labels:      in.Labels,
+		Env:         in.Env,
+		Dir:         in.Workdir,
+		Timeout:     time.Duration(in.TimeoutSeconds) * time.Second,
+		Artifacts:   in.Artifacts,
+		Secrets:     toCoreSecretRefs(in.Secrets),
+		Webhooks:    in.Webhooks,
+		OutputLimit: core.OutputLimit{Bytes: in.OutputLimit, Policy: in.OutputPolicy},
+	}
+	if err := core.ValidateJob(job); err != nil {
+		return nil, statusError(err)
 	}
-	go jobDispatcher.StartJob(job)
+	if jobDispatcher.Draining() {
+		return nil, statusError(core.ErrDraining)
+	}
+	if registry != nil {
+		if _, ok := registry.Locate(in.ID); ok {
+			return nil, statusError(fmt.Errorf("%w: %s", core.ErrJobExists, in.ID))
+		}
+		// the worker checks the secrets against its own store
+		return startOnWorker(ctx, in)
+	}
+	if err := checkSecretRefs(ctx, job.Secrets); err != nil {
+		return nil, err
+	}
+	// quotas are checked here, so a rejected job never shows up
+	if err := jobDispatcher.SubmitJob(job); err != nil {
+		return nil, statusError(err)
+	}
+	in.State = core.Created
 	return in, nil
 }
 
 func (s *server) Query(ctx context.Context, in *pb.JobID) (*pb.JobStatus, error) {
-	println("Received query request")
-	jobStatus := jobDispatcher.QueryJob(in.Id)
-	pbJob := pb.Job{
-		ID:    jobStatus.Job.ID,
-		Cmd:   jobStatus.Job.Cmd,
-		User:  jobStatus.Job.User,
-		State: jobStatus.Job.State,
+	logging.FromContext(ctx).Debug("received query request", "job_id", in.Id)
+	if registry != nil {
+		return queryOnWorker(ctx, in)
 	}
-	return &pb.JobStatus{
-		Job:          &pbJob,
-		ExitCode:     int32(jobStatus.ExitCode),
-		ErrorMessage: jobStatus.ErrorMsg,
-	}, nil
+	jobStatus, err := jobDispatcher.QueryJob(in.Id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toPbJobStatus(jobStatus), nil
 }
 
 func (s *server) Stop(ctx context.Context, in *pb.JobID) (*pb.NilMessage, error) {
-	println("Received stop request")
-	jobDispatcher.StopJob(in.Id)
+	logging.FromContext(ctx).Info("received stop request", "job_id", in.Id)
+	if registry != nil {
+		return stopOnWorker(ctx, in)
+	}
+	if err := jobDispatcher.StopJob(in.Id); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.NilMessage{}, nil
+}
+
+func (s *server) Signal(ctx context.Context, in *pb.SignalRequest) (*pb.NilMessage, error) {
+	logging.FromContext(ctx).Info("received signal request", "job_id", in.Id, "signal", in.Signal, "group", in.Group)
+	if registry != nil {
+		w, err := jobWorker(ctx, in.Id)
+		if err != nil {
+			return nil, err
+		}
+		return w.Client.Signal(ctx, in)
+	}
+	if err := jobDispatcher.SignalJob(in.Id, in.Signal, in.Group); err != nil {
+		return nil, statusError(err)
+	}
 	return &pb.NilMessage{}, nil
 }
 
-func (s *server) List(ctx context.Context, in *pb.NilMessage) (*pb.JobStatusList, error) {
-	println("Received list request")
-	jobList := jobDispatcher.ListJobs()
+func (s *server) Pause(ctx context.Context, in *pb.JobID) (*pb.NilMessage, error) {
+	logging.FromContext(ctx).Info("received pause request", "job_id", in.Id)
+	if registry != nil {
+		w, err := jobWorker(ctx, in.Id)
+		if err != nil {
+			return nil, err
+		}
+		return w.Client.Pause(ctx, in)
+	}
+	if err := jobDispatcher.PauseJob(in.Id); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.NilMessage{}, nil
+}
+
+func (s *server) Resume(ctx context.Context, in *pb.JobID) (*pb.NilMessage, error) {
+	logging.FromContext(ctx).Info("received resume request", "job_id", in.Id)
+	if registry != nil {
+		w, err := jobWorker(ctx, in.Id)
+		if err != nil {
+			return nil, err
+		}
+		return w.Client.Resume(ctx, in)
+	}
+	if err := jobDispatcher.ResumeJob(in.Id); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.NilMessage{}, nil
+}
+
+func (s *server) StopMatching(ctx context.Context, in *pb.ListRequest) (*pb.JobStatusList, error) {
+	logging.FromContext(ctx).Info("received bulk stop request", "selector", in.Selector)
+	sel, err := core.ParseSelector(in.Selector)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	// an empty selector would stop everything, make the caller say so
+	if len(sel) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "a label selector is required")
+	}
+	if registry != nil {
+		return stopMatchingOnWorkers(ctx, in, sel)
+	}
+	stopped, err := jobDispatcher.StopJobs(sel)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toPbJobStatusList(stopped), nil
+}
+
+func (s *server) Delete(ctx context.Context, in *pb.JobID) (*pb.NilMessage, error) {
+	logging.FromContext(ctx).Info("received delete request", "job_id", in.Id)
+	if registry != nil {
+		return deleteOnWorker(ctx, in)
+	}
+	if err := jobDispatcher.DeleteJob(in.Id); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.NilMessage{}, nil
+}
+
+func (s *server) List(ctx context.Context, in *pb.ListRequest) (*pb.JobStatusList, error) {
+	if registry != nil {
+		return listOnWorkers(ctx, in)
+	}
+	sel, err := core.ParseSelector(in.Selector)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	jobList := jobDispatcher.SelectJobs(sel)
+	logging.FromContext(ctx).Debug("received list request", "selector", in.Selector, "jobs", len(jobList))
+	return toPbJobStatusList(jobList), nil
+}
+
+func toPbJobStatusList(jobList []core.JobStatus) *pb.JobStatusList {
 	var pbJobStatusList []*pb.JobStatus
-	println("jobList:", len(jobList))
 	for _, jobStatus := range jobList {
-		pbJob := pb.Job{
-			ID:    jobStatus.Job.ID,
-			Cmd:   jobStatus.Job.Cmd,
-			User:  jobStatus.Job.User,
-			State: jobStatus.Job.State,
-		}
-		pbJobStatus := pb.JobStatus{
-			Job:          &pbJob,
-			ExitCode:     int32(jobStatus.ExitCode),
-			ErrorMessage: jobStatus.ErrorMsg,
-		}
-		pbJobStatusList = append(pbJobStatusList, &pbJobStatus)
-	}
-	return &pb.JobStatusList{JobStatusList: pbJobStatusList}, nil
-}
-
-func (s *server) StreamOutput(in *pb.JobID, stream pb.JobManager_StreamOutputServer) error {
-	println("Received stream request")
-	//jobDispatcher.StreamOutput(in.Id, stream)
-	resultChan := make(chan string)
-	go jobDispatcher.Output(in.Id, resultChan)
-	for elem := range resultChan { // read data form core's channel
-		// make string to []byte
-		output := &pb.JobOutput{Output: []byte(elem)}
+		pbJobStatusList = append(pbJobStatusList, toPbJobStatus(jobStatus))
+	}
+	return &pb.JobStatusList{JobStatusList: pbJobStatusList}
+}
+
+func toPbJobStatus(jobStatus core.JobStatus) *pb.JobStatus {
+	pbJob := pb.Job{
+		ID:             jobStatus.Job.ID,
+		Cmd:            jobStatus.Job.Cmd,
+		User:           jobStatus.Job.User,
+		State:          jobStatus.Job.State,
+		Labels:         jobStatus.Job.Labels,
+		Env:            jobStatus.Job.Env,
+		Workdir:        jobStatus.Job.Dir,
+		TimeoutSeconds: int32(jobStatus.Job.Timeout / time.Second),
+		Artifacts:      jobStatus.Job.Artifacts,
+		Secrets:        toPbSecretRefs(jobStatus.Job.Secrets),
+		Webhooks:       jobStatus.Job.Webhooks,
+		OutputLimit:    jobStatus.Job.OutputLimit.Bytes,
+		OutputPolicy:   jobStatus.Job.OutputLimit.Policy,
+	}
+	pbStatus := &pb.JobStatus{
+		Job:          &pbJob,
+		ExitCode:     int32(jobStatus.ExitCode),
+		ErrorMessage: jobStatus.ErrorMsg,
+		Truncated:    jobStatus.Truncated(),
+	}
+	if !jobStatus.StartedAt.IsZero() {
+		pbStatus.StartedAt = timestamppb.New(jobStatus.StartedAt)
+	}
+	if !jobStatus.FinishedAt.IsZero() {
+		pbStatus.FinishedAt = timestamppb.New(jobStatus.FinishedAt)
+	}
+	for _, d := range jobStatus.Deliveries {
+		pbStatus.WebhookDeliveries = append(pbStatus.WebhookDeliveries, &pb.WebhookDelivery{
+			Url:        d.URL,
+			Attempt:    int32(d.Attempt),
+			Time:       timestamppb.New(d.Time),
+			StatusCode: int32(d.StatusCode),
+			Error:      d.Error,
+		})
+	}
+	return pbStatus
+}
+
+func (s *server) StreamOutput(in *pb.OutputRequest, stream pb.JobManager_StreamOutputServer) error {
+	logging.FromContext(stream.Context()).Info("received stream request", "job_id", in.Id, "offset", in.Offset, "tail", in.Tail, "follow", in.Follow, "chunks", in.Chunks, "byte_offset", in.ByteOffset)
+	if registry != nil {
+		return streamFromWorker(in, stream)
+	}
+	if _, err := jobDispatcher.QueryJob(in.Id); err != nil {
+		return statusError(err)
+	}
+	if in.Offset < 0 || in.Tail < 0 || in.ByteOffset < 0 {
+		return status.Error(codes.InvalidArgument, "offset, tail and byte offset must not be negative")
+	}
+	metrics.ActiveStreams.Inc()
+	defer metrics.ActiveStreams.Dec()
+	opts := core.OutputOptions{
+		Offset:     int(in.Offset),
+		ByteOffset: in.ByteOffset,
+		Tail:       int(in.Tail),
+		Follow:     in.Follow,
+	}
+	if in.Since != nil {
+		opts.Since = in.Since.AsTime()
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	if in.Chunks {
+		return streamChunks(ctx, in.Id, opts, stream)
+	}
+	resultChan := make(chan core.OutputLine)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- jobDispatcher.Output(ctx, in.Id, opts, resultChan)
+	}()
+	for line := range resultChan { // read data form core's channel
+		output := &pb.JobOutput{
+			Output:  []byte(line.Text),
+			Line:    int64(line.Line),
+			Time:    timestamppb.New(line.Time),
+			Offset:  line.Offset,
+			Stream:  line.Stream,
+			Newline: line.Newline,
+		}
 		err := stream.Send(output) // send data to client
 		if err != nil {
 			return err
 		}
 	}
+	if err := <-errChan; err != nil {
+		return statusError(err)
+	}
+	return nil
+}
+
+func streamChunks(ctx context.Context, id string, opts core.OutputOptions, stream pb.JobManager_StreamOutputServer) error {
+	resultChan := make(chan core.OutputChunk)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- jobDispatcher.OutputChunks(ctx, id, opts, resultChan)
+	}()
+	for chunk := range resultChan {
+		output := &pb.JobOutput{
+			Output:  chunk.Data,
+			Line:    int64(chunk.Line),
+			Time:    timestamppb.New(chunk.Time),
+			Offset:  chunk.Offset,
+			Stream:  chunk.Stream,
+			Dropped: chunk.Dropped,
+		}
+		if err := stream.Send(output); err != nil {
+			return err
+		}
+	}
+	if err := <-errChan; err != nil {
+		return statusError(err)
+	}
 	return nil
 }
 
 func main() {
-	listen, _ := net.Listen("tcp", ":8080")
-	s := grpc.NewServer()
+	cfg, args, err := config.LoadServerConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
+	}
+	if config.IsPrintCommand(args) {
+		if err := config.Print(cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	} else if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments %q\n", args)
+		os.Exit(2)
+	}
+	if _, err := logging.Setup(cfg.Log.Format, cfg.Log.Level, cfg.Log.Output); err != nil {
+		slog.Error("invalid logging configuration", "err", err)
+		os.Exit(2)
+	}
+	// the keys are kept here, only the server's user may read it
+	if err := os.MkdirAll(cfg.DataDir, 0o700); err != nil {
+		slog.Error("failed to create data directory", "dir", cfg.DataDir, "err", err)
+		os.Exit(1)
+	}
+	if err := os.Chmod(cfg.DataDir, 0o700); err != nil {
+		slog.Error("failed to restrict the data directory", "dir", cfg.DataDir, "err", err)
+		os.Exit(1)
+	}
+	if cfg.Jobs.RunAs == "" {
+		slog.Warn("jobs run as the server's user and can read the secret and webhook keys, set -job-user to run them as another user")
+	}
+	authRequired = cfg.TLS.ClientCA != ""
+	templates, err = core.OpenTemplateStore(filepath.Join(cfg.DataDir, "templates.json"))
+	if err != nil {
+		slog.Error("failed to load job templates", "err", err)
+		os.Exit(1)
+	}
+	key, err := secrets.LoadKey(cfg.SecretsKeyFile())
+	if err != nil {
+		slog.Error("failed to load secret store key", "err", err)
+		os.Exit(1)
+	}
+	secretStore, err = secrets.Open(filepath.Join(cfg.DataDir, "secrets.json"), key)
+	if err != nil {
+		slog.Error("failed to open secret store", "err", err)
+		os.Exit(1)
+	}
+	jobDispatcher.SetSecretSource(secretStore)
+	webhookKey, err := secrets.LoadKey(cfg.WebhookKeyFile())
+	if err != nil {
+		slog.Error("failed to load webhook key", "err", err)
+		os.Exit(1)
+	}
+	allowNets, err := cfg.WebhookAllowNets()
+	if err != nil {
+		slog.Error("invalid webhook allowed networks", "err", err)
+		os.Exit(1)
+	}
+	jobDispatcher.SetWebhookConfig(core.WebhookConfig{
+		AllowNets:   allowNets,
+		Key:         webhookKey,
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		Backoff:     cfg.Webhooks.Backoff,
+		Timeout:     cfg.Webhooks.Timeout,
+		OutputTail:  cfg.Webhooks.OutputTail,
+	})
+	jobDispatcher.SetLimits(cfg.Limits())
+	setQuotas(cfg.JobQuotas())
+	jobDispatcher.SetArtifactDir(filepath.Join(cfg.DataDir, "artifacts"))
+	if cfg.Jobs.CgroupRoot != "" {
+		if err := jobDispatcher.SetCgroupRoot(cfg.Jobs.CgroupRoot); err != nil {
+			slog.Error("failed to set up the job cgroup root", "dir", cfg.Jobs.CgroupRoot, "err", err)
+			os.Exit(1)
+		}
+	}
+	clusterRole = cfg.Cluster.Role
+	coordinatorName = cfg.Cluster.CoordinatorName
+	workerNames = make(map[string]bool)
+	for _, name := range cfg.WorkerNames() {
+		workerNames[name] = true
+	}
+	if clusterRole == config.RoleWorker && coordinatorName == "" {
+		slog.Warn("worker takes jobs from any client that reaches its listener, set -coordinator-name to only accept its coordinator")
+	}
+	if rateLimiter, err = newCallLimiter(cfg.RateLimits()); err != nil {
+		slog.Error("invalid rate limits", "err", err)
+		os.Exit(2)
+	}
+	if clusterRole == config.RoleCoordinator {
+		creds, err := cfg.Cluster.TLS.Credentials()
+		if err != nil {
+			slog.Error("failed to load cluster tls credentials", "err", err)
+			os.Exit(1)
+		}
+		registry = cluster.NewRegistry(cfg.Cluster.HeartbeatInterval, creds)
+		defer registry.Close()
+		unaryInterceptors = append(unaryInterceptors, coordinatorUnaryInterceptor)
+		streamInterceptors = append(streamInterceptors, coordinatorStreamInterceptor)
+	}
+	if cfg.MetricsAddr != "" {
+		go serveMetrics(cfg.MetricsAddr)
+	}
+	var servers []*grpc.Server
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	serve := func(s *grpc.Server, listen net.Listener) {
+		servers = append(servers, s)
+		go func() {
+			if err := s.Serve(listen); err != nil {
+				slog.Error("grpc server failed", "addr", listen.Addr().String(), "err", err)
+				stop()
+			}
+		}()
+	}
+	if cfg.Listen != "" {
+		creds, err := cfg.TLS.Credentials()
+		if err != nil {
+			slog.Error("failed to load tls credentials", "err", err)
+			os.Exit(1)
+		}
+		listen, err := net.Listen("tcp", cfg.Listen)
+		if err != nil {
+			slog.Error("failed to listen", "addr", cfg.Listen, "err", err)
+			os.Exit(1)
+		}
+		slog.Info("job server listening", "addr", listen.Addr().String(), "tls", cfg.TLS.Cert != "")
+		serve(newGRPCServer(creds, cfg.Reflection), listen)
+	}
+	if cfg.UnixSocket != "" {
+		listen, err := listenUnix(cfg.UnixSocket)
+		if err != nil {
+			slog.Error("failed to listen", "socket", cfg.UnixSocket, "err", err)
+			os.Exit(1)
+		}
+		slog.Info("job server listening", "socket", cfg.UnixSocket)
+		serve(newGRPCServer(auth.NewPeerCredentials(), cfg.Reflection), listen)
+	}
+	var httpServer *http.Server
+	if cfg.HTTPListen != "" {
+		tlsConfig, err := cfg.TLS.Config()
+		if err != nil {
+			slog.Error("failed to load tls credentials", "err", err)
+			os.Exit(1)
+		}
+		listen, err := net.Listen("tcp", cfg.HTTPListen)
+		if err != nil {
+			slog.Error("failed to listen", "addr", cfg.HTTPListen, "err", err)
+			os.Exit(1)
+		}
+		httpServer = &http.Server{Handler: newGateway(&server{}), TLSConfig: tlsConfig}
+		slog.Info("http gateway listening", "addr", listen.Addr().String(), "tls", tlsConfig != nil)
+		go func() {
+			var err error
+			if tlsConfig != nil {
+				err = httpServer.ServeTLS(listen, "", "")
+			} else {
+				err = httpServer.Serve(listen)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("http gateway failed", "err", err)
+				stop()
+			}
+		}()
+	}
+	if registry != nil {
+		go superviseLeases(ctx, cfg.Cluster.HeartbeatInterval)
+	}
+	if clusterRole == config.RoleWorker {
+		if err := runAgent(ctx, cfg); err != nil {
+			slog.Error("failed to start the worker agent", "err", err)
+			os.Exit(1)
+		}
+	}
+	setServing(true)
+	<-ctx.Done()
+	stop() // a second signal kills the process right away
+	shutdown(servers, httpServer, cfg.Shutdown.Policy, cfg.Shutdown.Timeout)
+}
+
+func newGRPCServer(creds credentials.TransportCredentials, withReflection bool) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.Creds(creds),
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
 	pb.RegisterJobManagerServer(s, &server{})
-	s.Serve(listen)
+	if registry != nil {
+		pb.RegisterCoordinatorServer(s, &coordinator{})
+	}
+	healthpb.RegisterHealthServer(s, healthServer)
+	if withReflection {
+		reflection.Register(s)
+	}
+	return s
+}
+
+// listen on a unix socket, replacing a stale socket file left by a previous run
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listen, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// group members may connect, the kernel tells us who they are
+	if err := os.Chmod(path, 0o660); err != nil {
+		listen.Close()
+		return nil, err
+	}
+	return listen, nil
 }
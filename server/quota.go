@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"main/core"
+	"main/logging"
+	pb "main/proto"
+	"os/user"
+)
+
+func (s *server) Quota(ctx context.Context, in *pb.QuotaRequest) (*pb.QuotaReport, error) {
+	// quotas are charged to verified callers, they only see their own; anonymous
+	// ones share a quota. A worker trusts the user its coordinator identified
+	if !fromCoordinator(ctx) {
+		in.User = verifiedCaller(ctx)
+	}
+	logging.FromContext(ctx).Debug("received quota request", "user", in.User)
+	if registry != nil {
+		return quotaOnWorkers(ctx, in)
+	}
+	report := &pb.QuotaReport{}
+	for _, usage := range jobDispatcher.QuotaReport(in.User) {
+		report.Quotas = append(report.Quotas, &pb.QuotaUsage{
+			User:  usage.Scope.User,
+			Group: usage.Scope.Group,
+			Limits: &pb.QuotaLimits{
+				MaxRunning:       int32(usage.Limit.MaxRunning),
+				MaxQueued:        int32(usage.Limit.MaxQueued),
+				CpuSecondsPerDay: usage.Limit.CPUSecondsPerDay,
+				OutputBytes:      usage.Limit.OutputBytes,
+			},
+			Running:     int32(usage.Running),
+			Queued:      int32(usage.Queued),
+			CpuSeconds:  usage.CPUSeconds,
+			OutputBytes: usage.OutputBytes,
+		})
+	}
+	return report, nil
+}
+
+// names of the system groups name belongs to, for group quotas
+func userGroups(name string) []string {
+	u, err := user.Lookup(name)
+	if err != nil {
+		// not a system user, e.g. the common name of a client certificate
+		return nil
+	}
+	ids, err := u.GroupIds()
+	if err != nil {
+		slog.Warn("failed to look up the groups of a user", "user", name, "err", err)
+		return nil
+	}
+	var groups []string
+	for _, id := range ids {
+		if g, err := user.LookupGroupId(id); err == nil {
+			groups = append(groups, g.Name)
+		}
+	}
+	return groups
+}
+
+// apply the configured quotas, groups are only looked up when a group has a quota
+func setQuotas(quotas core.Quotas) {
+	var groups func(string) []string
+	if len(quotas.Groups) > 0 {
+		groups = userGroups
+	}
+	jobDispatcher.SetQuotas(quotas, groups)
+}
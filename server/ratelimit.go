@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"main/auth"
+	"main/config"
+	pb "main/proto"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// nil when no method is rate limited
+var rateLimiter *callLimiter
+
+// buckets idle this long are full again and can be dropped
+const bucketSweepInterval = time.Minute
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type bucketKey struct {
+	caller string
+	method string
+}
+
+// token buckets per caller and JobManager method
+type callLimiter struct {
+	mu        sync.Mutex
+	limits    map[string]config.RateLimit // by full method name, "" for the others
+	buckets   map[bucketKey]*tokenBucket
+	lastSweep time.Time
+}
+
+// nil when every limit is 0; limits is keyed by method name as in config.RateLimits
+func newCallLimiter(limits map[string]config.RateLimit) (*callLimiter, error) {
+	l := &callLimiter{limits: make(map[string]config.RateLimit), buckets: make(map[bucketKey]*tokenBucket), lastSweep: time.Now()}
+	limited := false
+	for name, limit := range limits {
+		if limit.Burst == 0 {
+			limit.Burst = int(math.Ceil(limit.Rate))
+		}
+		method := ""
+		if name != "" {
+			method = "/" + pb.JobManager_ServiceDesc.ServiceName + "/" + name
+			if !jobManagerMethod(name) {
+				return nil, fmt.Errorf("rate limit for unknown method %q", name)
+			}
+		}
+		l.limits[method] = limit
+		limited = limited || limit.Rate > 0
+	}
+	if !limited {
+		return nil, nil
+	}
+	return l, nil
+}
+
+func jobManagerMethod(name string) bool {
+	for _, m := range pb.JobManager_ServiceDesc.Methods {
+		if m.MethodName == name {
+			return true
+		}
+	}
+	for _, s := range pb.JobManager_ServiceDesc.Streams {
+		if s.StreamName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *callLimiter) limit(method string) config.RateLimit {
+	if limit, ok := l.limits[method]; ok {
+		return limit
+	}
+	return l.limits[""]
+}
+
+// take a token for the call, or return how long until one is available
+func (l *callLimiter) take(caller, method string) (time.Duration, bool) {
+	limit := l.limit(method)
+	if limit.Rate <= 0 {
+		return 0, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.sweepLocked(now)
+	key := bucketKey{caller: caller, method: method}
+	b := l.buckets[key]
+	if b == nil {
+		b = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second)), false
+}
+
+// drop the buckets that refilled completely, caller holds the lock
+func (l *callLimiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		limit := l.limit(key.method)
+		if b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// who the call is counted against: the identified user, else the caller's address
+func rateLimitCaller(ctx context.Context) string {
+	if user, ok := auth.Caller(ctx); ok {
+		return "user:" + user
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return "addr:" + host
+		}
+		return "addr:" + p.Addr.String()
+	}
+	return ""
+}
+
+// ResourceExhausted when the caller used up its tokens for the method, the
+// retry-after header tells it how many seconds to wait; calls a worker gets
+// from its coordinator are not limited, the coordinator limited the actual callers
+func checkRateLimit(ctx context.Context, method string, setHeader func(metadata.MD) error) error {
+	if rateLimiter == nil || fromCoordinator(ctx) || !strings.HasPrefix(method, "/"+pb.JobManager_ServiceDesc.ServiceName+"/") {
+		return nil
+	}
+	wait, ok := rateLimiter.take(rateLimitCaller(ctx), method)
+	if ok {
+		return nil
+	}
+	setHeader(metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(wait.Seconds())))))
+	return status.Errorf(codes.ResourceExhausted, "rate limit exceeded for %s, retry in %s", method, wait.Round(time.Millisecond))
+}
+
+func rateLimitUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	err := checkRateLimit(ctx, info.FullMethod, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) })
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func rateLimitStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := checkRateLimit(ss.Context(), info.FullMethod, ss.SetHeader); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"main/config"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const startMethod = "/JobManager/Start"
+
+func TestNewCallLimiter(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits map[string]config.RateLimit
+		nilL   bool
+		err    bool
+	}{
+		{name: "none", nilL: true},
+		{name: "all zero", limits: map[string]config.RateLimit{"": {}, "Start": {}}, nilL: true},
+		{name: "default", limits: map[string]config.RateLimit{"": {Rate: 1}}},
+		{name: "method", limits: map[string]config.RateLimit{"Start": {Rate: 1}}},
+		{name: "unknown method", limits: map[string]config.RateLimit{"Launch": {Rate: 1}}, err: true},
+	}
+	for _, tt := range tests {
+		l, err := newCallLimiter(tt.limits)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if !tt.err && (l == nil) != tt.nilL {
+			t.Errorf("%s: limiter = %v, want nil %v", tt.name, l, tt.nilL)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	l, err := newCallLimiter(map[string]config.RateLimit{"": {Rate: 10, Burst: 2}, "Start": {Rate: 0.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the burst is available right away, then a token every 100ms
+	for i := 0; i < 2; i++ {
+		if _, ok := l.take("alice", "/JobManager/List"); !ok {
+			t.Fatalf("call %d within the burst was limited", i+1)
+		}
+	}
+	wait, ok := l.take("alice", "/JobManager/List")
+	if ok || wait <= 0 || wait > 100*time.Millisecond {
+		t.Fatalf("call past the burst: ok %v, wait %v, want a wait of up to 100ms", ok, wait)
+	}
+	if _, ok := l.take("bob", "/JobManager/List"); !ok {
+		t.Error("another caller shares the bucket")
+	}
+	if _, ok := l.take("alice", "/JobManager/Query"); !ok {
+		t.Error("another method shares the bucket")
+	}
+
+	// refill by moving the bucket back in time
+	b := l.buckets[bucketKey{caller: "alice", method: "/JobManager/List"}]
+	b.last = b.last.Add(-150 * time.Millisecond)
+	if _, ok := l.take("alice", "/JobManager/List"); !ok {
+		t.Error("no token after 150ms at 10 per second")
+	}
+	if _, ok := l.take("alice", "/JobManager/List"); ok {
+		t.Error("more than one token refilled in 150ms")
+	}
+	// never more than the burst, however long the bucket was idle
+	b.last = b.last.Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		_, ok := l.take("alice", "/JobManager/List")
+		if want := i < 2; ok != want {
+			t.Errorf("call %d after an idle hour: ok %v, want %v", i+1, ok, want)
+		}
+	}
+
+	// the burst of a method limit defaults to its rate rounded up
+	if _, ok := l.take("alice", startMethod); !ok {
+		t.Error("first Start was limited")
+	}
+	if wait, ok := l.take("alice", startMethod); ok || wait < time.Second {
+		t.Errorf("second Start: ok %v, wait %v, want a wait of about 2s", ok, wait)
+	}
+}
+
+func TestBucketSweep(t *testing.T) {
+	l, err := newCallLimiter(map[string]config.RateLimit{"": {Rate: 1, Burst: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.take("alice", startMethod)
+	l.take("bob", startMethod)
+	l.buckets[bucketKey{caller: "alice", method: startMethod}].last = time.Now().Add(-time.Minute)
+	l.lastSweep = time.Now().Add(-2 * bucketSweepInterval)
+	l.take("carol", startMethod)
+	if _, ok := l.buckets[bucketKey{caller: "alice", method: startMethod}]; ok {
+		t.Error("a full bucket was not swept")
+	}
+	if _, ok := l.buckets[bucketKey{caller: "bob", method: startMethod}]; !ok {
+		t.Error("a bucket still refilling was swept")
+	}
+}
+
+// a context of a call made with a verified client certificate named cn
+func certContext(cn string) context.Context {
+	state := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}}}
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1)}, AuthInfo: credentials.TLSInfo{State: state}})
+}
+
+func TestWorkerRateLimit(t *testing.T) {
+	defer func(role, name string, l *callLimiter) { clusterRole, coordinatorName, rateLimiter = role, name, l }(clusterRole, coordinatorName, rateLimiter)
+	tests := []struct {
+		name            string
+		coordinatorName string
+		ctx             context.Context
+		limited         bool
+	}{
+		{name: "coordinator", coordinatorName: "coord", ctx: certContext("coord")},
+		{name: "other certificate", coordinatorName: "coord", ctx: certContext("mallory"), limited: true},
+		{name: "no certificate", coordinatorName: "coord", ctx: context.Background(), limited: true},
+		// without a name every caller could be the coordinator
+		{name: "coordinator name unset", ctx: certContext("coord"), limited: true},
+	}
+	for _, tt := range tests {
+		clusterRole, coordinatorName = config.RoleWorker, tt.coordinatorName
+		var err error
+		if rateLimiter, err = newCallLimiter(map[string]config.RateLimit{"": {Rate: 0.001, Burst: 1}}); err != nil {
+			t.Fatal(err)
+		}
+		var last error
+		for i := 0; i < 2; i++ {
+			last = checkRateLimit(tt.ctx, startMethod, func(metadata.MD) error { return nil })
+		}
+		if limited := status.Code(last) == codes.ResourceExhausted; limited != tt.limited {
+			t.Errorf("%s: second call = %v, want limited %v", tt.name, last, tt.limited)
+		}
+	}
+}
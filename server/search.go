@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"main/core"
+	"main/logging"
+	pb "main/proto"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *server) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	logging.FromContext(ctx).Info("received search request", "pattern", in.Pattern, "job_id", in.Id, "selector", in.Selector, "max_matches", in.MaxMatches)
+	if registry != nil {
+		return searchOnWorkers(ctx, in)
+	}
+	q := core.SearchQuery{Pattern: in.Pattern, JobID: in.Id, MaxMatches: int(in.MaxMatches)}
+	if in.Id == "" {
+		sel, err := core.ParseSelector(in.Selector)
+		if err != nil {
+			return nil, statusError(err)
+		}
+		q.Selector = sel
+	}
+	result, err := jobDispatcher.Search(ctx, q)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	res := &pb.SearchResponse{Truncated: result.Truncated, JobsSearched: int32(result.Jobs)}
+	for _, m := range result.Matches {
+		res.Matches = append(res.Matches, &pb.SearchMatch{
+			Id:     m.JobID,
+			Line:   int64(m.Line),
+			Stream: m.Stream,
+			Time:   timestamppb.New(m.Time),
+			Text:   []byte(m.Text),
+		})
+	}
+	return res, nil
+}
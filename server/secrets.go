@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"main/core"
+	"main/logging"
+	pb "main/proto"
+	"main/secrets"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// global secret store, opened in main from the data directory
+var secretStore *secrets.Store
+
+// set in main when callers have to present a client certificate, an
+// anonymous caller then neither owns nor uses secrets
+var authRequired bool
+
+// who a secret belongs to: the verified caller, empty for an anonymous one
+func secretOwner(ctx context.Context) (string, error) {
+	user := verifiedCaller(ctx)
+	if user == "" && authRequired {
+		return "", status.Error(codes.Unauthenticated, "secrets need an authenticated caller")
+	}
+	return user, nil
+}
+
+// only the name is logged, the value never leaves the store except into a job
+func (s *server) PutSecret(ctx context.Context, in *pb.Secret) (*pb.SecretInfo, error) {
+	logging.FromContext(ctx).Info("received put secret request", "secret", in.Name)
+	owner, err := secretOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info, err := secretStore.Put(in.Name, in.Value, owner)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toPbSecretInfo(info), nil
+}
+
+func (s *server) ListSecrets(ctx context.Context, in *pb.NilMessage) (*pb.SecretList, error) {
+	owner, err := secretOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := &pb.SecretList{}
+	for _, info := range secretStore.List(owner) {
+		list.Secrets = append(list.Secrets, toPbSecretInfo(info))
+	}
+	return list, nil
+}
+
+func (s *server) DeleteSecret(ctx context.Context, in *pb.SecretName) (*pb.NilMessage, error) {
+	logging.FromContext(ctx).Info("received delete secret request", "secret", in.Name)
+	owner, err := secretOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := secretStore.Delete(in.Name, owner); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.NilMessage{}, nil
+}
+
+func toPbSecretInfo(info secrets.Info) *pb.SecretInfo {
+	return &pb.SecretInfo{Name: info.Name, Owner: info.Owner, Created: timestamppb.New(info.Created), Updated: timestamppb.New(info.Updated)}
+}
+
+// check that every referenced secret exists and the caller may use it, so a
+// typo fails the request rather than the job
+func checkSecretRefs(ctx context.Context, refs []core.SecretRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	user, err := secretOwner(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if err := secretStore.Check(ref.Name, user); err != nil {
+			return statusError(err)
+		}
+	}
+	return nil
+}
+
+func toCoreSecretRefs(refs []*pb.SecretRef) []core.SecretRef {
+	var out []core.SecretRef
+	for _, ref := range refs {
+		out = append(out, core.SecretRef{Name: ref.Name, Env: ref.Env, File: ref.File})
+	}
+	return out
+}
+
+func toPbSecretRefs(refs []core.SecretRef) []*pb.SecretRef {
+	var out []*pb.SecretRef
+	for _, ref := range refs {
+		out = append(out, &pb.SecretRef{Name: ref.Name, Env: ref.Env, File: ref.File})
+	}
+	return out
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSecretOwnerAnonymous(t *testing.T) {
+	defer func(required bool) { authRequired = required }(authRequired)
+	tests := []struct {
+		required bool
+		code     codes.Code
+	}{
+		{required: false, code: codes.OK},
+		{required: true, code: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		authRequired = tt.required
+		owner, err := secretOwner(context.Background())
+		if status.Code(err) != tt.code || owner != "" {
+			t.Errorf("auth required %v: secretOwner() = %q, %v, want code %s", tt.required, owner, err, tt.code)
+		}
+	}
+}
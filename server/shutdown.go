@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"main/core"
+	"main/logging"
+	pb "main/proto"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *server) Drain(ctx context.Context, in *pb.DrainRequest) (*pb.DrainResponse, error) {
+	logging.FromContext(ctx).Info("received drain request", "policy", in.Policy, "timeout_seconds", in.TimeoutSeconds)
+	if err := core.ValidateDrainPolicy(in.Policy); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	setServing(false)
+	if in.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(in.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+	if err := jobDispatcher.Drain(ctx, in.Policy); err != nil {
+		return nil, statusError(err)
+	}
+	if registry != nil {
+		return drainOnWorkers(ctx, in)
+	}
+	return &pb.DrainResponse{ActiveJobs: int32(jobDispatcher.ActiveJobs())}, nil
+}
+
+// drain the dispatcher with the given policy, then let in-flight rpcs finish
+// both phases share timeout, after which remaining rpcs are cancelled
+func shutdown(servers []*grpc.Server, httpServer *http.Server, policy string, timeout time.Duration) {
+	slog.Info("shutting down", "policy", policy, "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	setServing(false)
+	if err := jobDispatcher.Drain(ctx, policy); err != nil {
+		slog.Warn("jobs still running at shutdown", "err", err, "active", jobDispatcher.ActiveJobs())
+	}
+	if err := jobDispatcher.WaitWebhooks(ctx); err != nil {
+		slog.Warn("webhook deliveries still pending at shutdown", "err", err)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		for _, s := range servers {
+			s.GracefulStop()
+		}
+		close(stopped)
+	}()
+	if httpServer != nil {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			httpServer.Close()
+		}
+	}
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Warn("in-flight rpcs did not finish in time, closing them")
+		for _, s := range servers {
+			s.Stop()
+		}
+	}
+	slog.Info("server stopped", "active_jobs", jobDispatcher.ActiveJobs())
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"main/core"
+	"main/logging"
+	pb "main/proto"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+var startTime = time.Now()
+
+// shared by every grpc listener, flipped to NOT_SERVING while draining
+var healthServer = health.NewServer()
+
+func setServing(serving bool) {
+	status := healthpb.HealthCheckResponse_SERVING
+	if !serving {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	healthServer.SetServingStatus("", status)
+	healthServer.SetServingStatus(pb.JobManager_ServiceDesc.ServiceName, status)
+}
+
+func (s *server) Status(ctx context.Context, in *pb.NilMessage) (*pb.ServerStatus, error) {
+	logging.FromContext(ctx).Debug("received status request")
+	counts := jobDispatcher.CountByState()
+	jobsByState := make(map[string]int32, len(counts))
+	for state, n := range counts {
+		jobsByState[state] = int32(n)
+	}
+	res := &pb.ServerStatus{
+		Version:       version,
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+		JobsByState:   jobsByState,
+		QueueDepth:    int32(counts[core.Created]),
+		Draining:      jobDispatcher.Draining(),
+	}
+	if registry != nil {
+		statusOnWorkers(ctx, res)
+	}
+	return res, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"main/core"
+	pb "main/proto"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func checkHealth(t *testing.T, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	res, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.Status
+}
+
+func TestStatusAndDrain(t *testing.T) {
+	defer func(jd *core.JobDispatcher) { jobDispatcher = jd }(jobDispatcher)
+	defer setServing(true)
+	jobDispatcher = core.NewJobDispatcher()
+	jobDispatcher.SetLimits(core.Limits{MaxConcurrent: 1})
+	s := &server{}
+	running := core.Job{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", Cmd: "sleep 10"}
+	if err := jobDispatcher.SubmitJob(running); err != nil {
+		t.Fatal(err)
+	}
+	defer jobDispatcher.StopJob(running.ID)
+	for deadline := time.Now().Add(5 * time.Second); jobDispatcher.CountByState()[core.Running] != 1; {
+		if time.Now().After(deadline) {
+			t.Fatal("the first job did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := jobDispatcher.SubmitJob(core.Job{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Cmd: "true"}); err != nil {
+		t.Fatal(err)
+	}
+
+	setServing(true)
+	res, err := s.Status(context.Background(), &pb.NilMessage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Version != version || res.QueueDepth != 1 || res.JobsByState[core.Running] != 1 || res.Draining {
+		t.Errorf("status = %v, want one running and one queued job", res)
+	}
+
+	if _, err := s.Drain(context.Background(), &pb.DrainRequest{Policy: "later"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Drain with an invalid policy = %v, want InvalidArgument", err)
+	}
+	if got := checkHealth(t, ""); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health after a refused drain = %s, want SERVING", got)
+	}
+
+	drained, err := s.Drain(context.Background(), &pb.DrainRequest{Policy: core.DrainDetach})
+	if err != nil || drained.ActiveJobs != 2 {
+		t.Errorf("Drain = %v, %v, want 2 active jobs", drained, err)
+	}
+	for _, service := range []string{"", pb.JobManager_ServiceDesc.ServiceName} {
+		if got := checkHealth(t, service); got != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("health of %q while draining = %s, want NOT_SERVING", service, got)
+		}
+	}
+	if res, _ := s.Status(context.Background(), &pb.NilMessage{}); !res.Draining {
+		t.Error("status does not report draining")
+	}
+}
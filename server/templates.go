@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"main/core"
+	"main/logging"
+	pb "main/proto"
+	"time"
+)
+
+// global template store, opened in main from the data directory
+var templates *core.TemplateStore
+
+func (s *server) CreateTemplate(ctx context.Context, in *pb.JobTemplate) (*pb.JobTemplate, error) {
+	logging.FromContext(ctx).Info("received create template request", "template", in.Name)
+	t := toCoreTemplate(in)
+	t.Owner = verifiedCaller(ctx)
+	if err := templates.Create(t); err != nil {
+		return nil, statusError(err)
+	}
+	return toPbTemplate(t), nil
+}
+
+func (s *server) UpdateTemplate(ctx context.Context, in *pb.JobTemplate) (*pb.JobTemplate, error) {
+	logging.FromContext(ctx).Info("received update template request", "template", in.Name)
+	if err := templates.Update(toCoreTemplate(in), verifiedCaller(ctx)); err != nil {
+		return nil, statusError(err)
+	}
+	t, err := templates.Get(in.Name)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toPbTemplate(t), nil
+}
+
+func (s *server) GetTemplate(ctx context.Context, in *pb.TemplateName) (*pb.JobTemplate, error) {
+	t, err := templates.Get(in.Name)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toPbTemplate(t), nil
+}
+
+func (s *server) ListTemplates(ctx context.Context, in *pb.NilMessage) (*pb.JobTemplateList, error) {
+	list := &pb.JobTemplateList{}
+	for _, t := range templates.List() {
+		list.Templates = append(list.Templates, toPbTemplate(t))
+	}
+	return list, nil
+}
+
+func (s *server) DeleteTemplate(ctx context.Context, in *pb.TemplateName) (*pb.NilMessage, error) {
+	logging.FromContext(ctx).Info("received delete template request", "template", in.Name)
+	if err := templates.Delete(in.Name, verifiedCaller(ctx)); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.NilMessage{}, nil
+}
+
+func (s *server) StartTemplate(ctx context.Context, in *pb.StartTemplateRequest) (*pb.Job, error) {
+	// parameter values are not logged, they may be anything
+	logging.FromContext(ctx).Info("received start template request", "template", in.Name, "job_id", in.ID)
+	t, err := templates.Get(in.Name)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	job, err := t.Instantiate(in.Params)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	labels := job.Labels
+	if len(in.Labels) > 0 && labels == nil {
+		labels = make(map[string]string, len(in.Labels))
+	}
+	for k, v := range in.Labels {
+		labels[k] = v
+	}
+	return startJob(ctx, &pb.Job{
+		ID:             in.ID,
+		NodeSelector:   in.NodeSelector,
+		RestartPolicy:  in.RestartPolicy,
+		MaxRestarts:    in.MaxRestarts,
+		Webhooks:       in.Webhooks,
+		Cmd:            job.Cmd,
+		Labels:         labels,
+		Env:            job.Env,
+		Workdir:        job.Dir,
+		TimeoutSeconds: int32(job.Timeout / time.Second),
+		Artifacts:      job.Artifacts,
+		Secrets:        toPbSecretRefs(job.Secrets),
+	})
+}
+
+func toCoreTemplate(in *pb.JobTemplate) core.Template {
+	t := core.Template{
+		Name:      in.Name,
+		Cmd:       in.Cmd,
+		Env:       in.Env,
+		Workdir:   in.Workdir,
+		Timeout:   time.Duration(in.TimeoutSeconds) * time.Second,
+		Labels:    in.Labels,
+		Artifacts: in.Artifacts,
+		Secrets:   toCoreSecretRefs(in.Secrets),
+	}
+	for _, p := range in.Params {
+		t.Params = append(t.Params, core.TemplateParam{Name: p.Name, Default: p.Default, Required: p.Required, Description: p.Description})
+	}
+	return t
+}
+
+func toPbTemplate(t core.Template) *pb.JobTemplate {
+	out := &pb.JobTemplate{
+		Name:           t.Name,
+		Cmd:            t.Cmd,
+		Env:            t.Env,
+		Workdir:        t.Workdir,
+		TimeoutSeconds: int32(t.Timeout / time.Second),
+		Labels:         t.Labels,
+		Artifacts:      t.Artifacts,
+		Secrets:        toPbSecretRefs(t.Secrets),
+		Owner:          t.Owner,
+	}
+	for _, p := range t.Params {
+		out.Params = append(out.Params, &pb.TemplateParam{Name: p.Name, Default: p.Default, Required: p.Required, Description: p.Description})
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"main/core"
+	"main/logging"
+	pb "main/proto"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// block until the requested jobs finish, a timeout is reported in the response rather than as an error
+func (s *server) Wait(ctx context.Context, in *pb.WaitRequest) (*pb.WaitResponse, error) {
+	logging.FromContext(ctx).Debug("received wait request", "job_ids", in.Ids, "mode", in.Mode, "timeout_seconds", in.TimeoutSeconds)
+	mode := in.Mode
+	if mode == "" {
+		mode = core.WaitAll
+	}
+	if err := core.ValidateWaitMode(mode); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if registry != nil {
+		return waitOnWorkers(ctx, in, mode)
+	}
+	waitCtx := ctx
+	if in.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, time.Duration(in.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+	statuses, err := jobDispatcher.WaitJobs(waitCtx, in.Ids, mode)
+	timedOut := false
+	if err != nil {
+		// only our own timeout is a result, the caller's deadline or cancellation is an error
+		if !errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+			return nil, statusError(err)
+		}
+		timedOut = true
+	}
+	res := &pb.WaitResponse{TimedOut: timedOut}
+	for _, jobStatus := range statuses {
+		res.JobStatusList = append(res.JobStatusList, toPbJobStatus(jobStatus))
+	}
+	return res, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"main/core"
+	"main/logging"
+	pb "main/proto"
+)
+
+func (s *server) Watch(in *pb.ListRequest, stream pb.JobManager_WatchServer) error {
+	logging.FromContext(stream.Context()).Info("received watch request", "selector", in.Selector)
+	if registry != nil {
+		return watchOnWorkers(in, stream)
+	}
+	sel, err := core.ParseSelector(in.Selector)
+	if err != nil {
+		return statusError(err)
+	}
+	events := make(chan core.WatchEvent)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- jobDispatcher.Watch(stream.Context(), sel, events)
+	}()
+	for event := range events {
+		err := stream.Send(&pb.WatchEvent{Type: event.Type, JobStatus: toPbJobStatus(event.Status)})
+		if err != nil {
+			return err
+		}
+	}
+	if err := <-errChan; err != nil {
+		return statusError(err)
+	}
+	return nil
+}